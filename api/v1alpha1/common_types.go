@@ -19,6 +19,21 @@ const (
 	// ConditionGitRepoReachable is True if the Git repository is reachable
 	ConditionGitRepoReachable ConditionType = "GitRepoReachable"
 
+	// ConditionContextDirFound is True if the context directory exists in the Git repository
+	ConditionContextDirFound ConditionType = "ContextDirFound"
+
+	// ConditionWebhookRegistered is True if the webhook of the Git repository is registered on the Git provider
+	ConditionWebhookRegistered ConditionType = "WebhookRegistered"
+
+	// ConditionCommitStatusReported is True if the outcome of the commit is reported as a commit status
+	ConditionCommitStatusReported ConditionType = "CommitStatusReported"
+
+	// ConditionDeploymentReported is True if the deployment of the commit is recorded on the Git provider
+	ConditionDeploymentReported ConditionType = "DeploymentReported"
+
+	// ConditionPreviewsSynced is True if the previews match the open pull requests
+	ConditionPreviewsSynced ConditionType = "PreviewsSynced"
+
 	// ConditionOperatorDegraded is True if the operator is in a degraded state
 	ConditionOperatorDegraded ConditionType = "OperatorDegraded"
 
@@ -31,6 +46,27 @@ const (
 	// ReasonSecretResourceNotFound indicates the secret resource is not found
 	ReasonSecretResourceNotFound ConditionReason = "SecretResourceNotFound"
 
+	// ReasonImportStrategyNotDetected indicates no import strategy matches the files of the context directory
+	ReasonImportStrategyNotDetected ConditionReason = "ImportStrategyNotDetected"
+
+	// ReasonWebhookRegistered indicates the webhook is registered on the Git provider
+	ReasonWebhookRegistered ConditionReason = "WebhookRegistered"
+
+	// ReasonWebhookURLNotConfigured indicates the operator has no public URL to register webhooks with
+	ReasonWebhookURLNotConfigured ConditionReason = "WebhookURLNotConfigured"
+
+	// ReasonCommitStatusReported indicates the commit status is set on the Git provider
+	ReasonCommitStatusReported ConditionReason = "CommitStatusReported"
+
+	// ReasonDeploymentReported indicates the deployment is recorded on the Git provider
+	ReasonDeploymentReported ConditionReason = "DeploymentReported"
+
+	// ReasonPreviewsSynced indicates the previews match the open pull requests
+	ReasonPreviewsSynced ConditionReason = "PreviewsSynced"
+
+	// ReasonReconciling indicates a new commit, or a repository reachable again, is being reconciled
+	ReasonReconciling ConditionReason = "Reconciling"
+
 	// ReasonInit indicates the resource is initializing
 	ReasonInit ConditionReason = "Init"
 
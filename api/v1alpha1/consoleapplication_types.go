@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,8 +30,87 @@ type Git struct {
 	ContextDir      string `json:"contextDir,omitempty"`
 	Reference       string `json:"reference,omitempty"`
 	SourceSecretRef string `json:"sourceSecretRef,omitempty"`
+
+	// WebhookSecretRef is the secret, in the namespace of the ConsoleApplication,
+	// whose WebHookSecretKey key authenticates the push events of the repository.
+	// Push events are ignored without it.
+	WebhookSecretRef string `json:"webhookSecretRef,omitempty"`
+
+	// RegisterWebhook creates a webhook on the Git provider delivering the push
+	// events of the repository to the operator. Its secret is generated when
+	// WebhookSecretRef is not set.
+	RegisterWebhook bool `json:"registerWebhook,omitempty"`
+
+	// PollInterval is how often the Git reference is resolved to detect new
+	// commits, for repositories that cannot deliver push events. Polls are
+	// spread with jitter, and never more frequent than MinPollInterval.
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+
+	// ReportCommitStatus sets a commit status on the Git provider with the
+	// validation of the source of each commit the ConsoleApplication is
+	// reconciled from. The credentials of SourceSecretRef must be allowed to
+	// set commit statuses.
+	ReportCommitStatus bool `json:"reportCommitStatus,omitempty"`
+
+	// ReportDeployment records each commit rolled out by the Deployment named
+	// after the ConsoleApplication as a deployment to the environment named
+	// after its namespace, on Github or Gitlab. The credentials of
+	// SourceSecretRef must be allowed to create deployments.
+	ReportDeployment bool `json:"reportDeployment,omitempty"`
 }
 
+// CommitSHAAnnotation is set on the pod template of the Deployment named after
+// a ConsoleApplication to the commit its pods run.
+const CommitSHAAnnotation = "apps.console.dev/commit-sha"
+
+// Preview creates a preview ConsoleApplication for each open pull request, or
+// merge request on Gitlab, targeting the Git reference. The previews are
+// created in the namespace of the ConsoleApplication, named after it with the
+// number of their pull request as suffix.
+type Preview struct {
+	// Enabled creates the previews. The pull requests are listed again on
+	// their webhook events, when the webhook of the repository is registered,
+	// and every PollInterval, else DefaultPreviewResync.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// AllowForks creates the previews of the pull requests opened from forks
+	// too. They build the code of the fork author, so they are created without
+	// the SourceSecretRef and WebhookSecretRef of the ConsoleApplication, and
+	// do not report commit statuses.
+	AllowForks bool `json:"allowForks,omitempty"`
+}
+
+// DefaultPreviewResync is how often the pull requests are listed again when
+// the ConsoleApplication sets no poll interval.
+const DefaultPreviewResync = 10 * time.Minute
+
+// PreviewFinalizer deletes the previews before the ConsoleApplication is deleted.
+const PreviewFinalizer = "apps.console.dev/preview"
+
+const (
+	// PreviewOfLabel is the name of the ConsoleApplication a preview was created for.
+	PreviewOfLabel = "apps.console.dev/preview-of"
+
+	// PullRequestLabel is the number of the pull request a preview was created for.
+	PullRequestLabel = "apps.console.dev/pull-request"
+
+	// WebhookSecretLabel marks the webhook secrets, the only Secrets watched
+	// outside of the operator namespace. Generated webhook secrets are labelled
+	// with the name of their ConsoleApplication, other ones may have any value.
+	WebhookSecretLabel = "apps.console.dev/webhook-secret"
+)
+
+// MinPollInterval is the shortest interval the Git reference is polled at.
+const MinPollInterval = time.Minute
+
+// WebhookSecretKey is the key of the webhook secret, as in the webhook
+// secrets of OpenShift BuildConfigs.
+const WebhookSecretKey = "WebHookSecretKey"
+
+// WebhookFinalizer deletes the webhook registered on the Git provider before
+// the ConsoleApplication is deleted.
+const WebhookFinalizer = "apps.console.dev/git-webhook"
+
 type BuildConfiguration struct {
 	BuilderImage BuilderImage `json:"builderImage,omitempty"`
 	BuildOption  string       `json:"buildOption,omitempty"`
@@ -57,6 +138,20 @@ type DeploymentConfiguration struct {
 	Expose       Expose `json:"expose,omitempty"`
 }
 
+const (
+	// ImportStrategyAuto picks the import strategy detected in the context directory.
+	ImportStrategyAuto = "auto"
+
+	// ImportStrategyBuilderImage builds the sources with a Source-to-Image builder image.
+	ImportStrategyBuilderImage = "builder-image"
+
+	// ImportStrategyDockerfile builds the Dockerfile of the context directory.
+	ImportStrategyDockerfile = "dockerfile"
+
+	// ImportStrategyDevfile builds the application described by the devfile of the context directory.
+	ImportStrategyDevfile = "devfile"
+)
+
 // ConsoleApplicationSpec defines the desired state of ConsoleApplication
 type ConsoleApplicationSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -68,6 +163,9 @@ type ConsoleApplicationSpec struct {
 	ImportStrategy          string                  `json:"importStrategy,omitempty"`
 	BuildConfiguration      BuildConfiguration      `json:"buildConfiguration,omitempty"`
 	DeploymentConfiguration DeploymentConfiguration `json:"deploymentConfiguration,omitempty"`
+
+	// Preview creates a preview ConsoleApplication for each open pull request.
+	Preview Preview `json:"preview,omitempty"`
 }
 
 // ConsoleApplicationStatus defines the observed state of ConsoleApplication
@@ -75,6 +173,217 @@ type ConsoleApplicationStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Git records the revision the Git reference resolved to.
+	Git GitStatus `json:"git,omitempty"`
+
+	// Detection records the import strategies detected in the context directory.
+	Detection *DetectionStatus `json:"detection,omitempty"`
+
+	// LastTrigger records the latest commit pushed to the Git reference. The
+	// ConsoleApplication is reconciled again from it.
+	LastTrigger *GitTrigger `json:"lastTrigger,omitempty"`
+
+	// Webhook is the webhook registered on the Git provider.
+	Webhook *WebhookStatus `json:"webhook,omitempty"`
+
+	// CommitStatus is the last commit status set on the Git provider.
+	CommitStatus *CommitStatusReport `json:"commitStatus,omitempty"`
+
+	// Deployment is the last deployment record created on the Git provider.
+	Deployment *DeploymentReport `json:"deployment,omitempty"`
+
+	// LastPullRequestEvent records the latest pull request event of the
+	// repository. The previews are synced again from it.
+	LastPullRequestEvent *PullRequestEvent `json:"lastPullRequestEvent,omitempty"`
+
+	// Previews are the preview ConsoleApplications of the open pull requests.
+	Previews []PreviewStatus `json:"previews,omitempty"`
+}
+
+// PreviewStatus is the preview ConsoleApplication of an open pull request.
+type PreviewStatus struct {
+	// Number identifies the pull request in its repository.
+	Number int `json:"number"`
+
+	// URL is the page of the pull request.
+	URL string `json:"url,omitempty"`
+
+	// Name is the name of the preview ConsoleApplication.
+	Name string `json:"name"`
+
+	// HeadRef is the branch the pull request is opened from.
+	HeadRef string `json:"headRef"`
+
+	// HeadSHA is the latest commit of the pull request when it was last listed.
+	HeadSHA string `json:"headSHA,omitempty"`
+
+	// CommentedURL is the URL of the preview commented on the pull request.
+	CommentedURL string `json:"commentedURL,omitempty"`
+}
+
+// PullRequestEvent is a pull request opened, updated or closed.
+type PullRequestEvent struct {
+	// Number identifies the pull request in its repository.
+	Number int `json:"number"`
+
+	// Action is what happened to the pull request, as named by the Git provider.
+	Action string `json:"action,omitempty"`
+
+	// DeliveryID identifies the webhook delivery reporting the event.
+	DeliveryID string `json:"deliveryID,omitempty"`
+
+	// Time is when the event was reported.
+	Time metav1.Time `json:"time"`
+}
+
+// CommitStatusReport is a commit status set on the Git provider.
+type CommitStatusReport struct {
+	// CommitSHA is the commit the status was set on.
+	CommitSHA string `json:"commitSHA"`
+
+	// State is the outcome reported by the status.
+	//+kubebuilder:validation:Enum=pending;success;failure
+	State string `json:"state"`
+
+	// Description is the summary of the outcome.
+	Description string `json:"description,omitempty"`
+
+	// TargetURL is the page the status links to, the URL of the Route of the application.
+	TargetURL string `json:"targetURL,omitempty"`
+}
+
+// WebhookStatus is a webhook registered on the Git provider.
+type WebhookStatus struct {
+	// ID identifies the webhook on the Git provider.
+	ID string `json:"id"`
+
+	// GitURL is the repository the webhook was registered on.
+	GitURL string `json:"gitURL"`
+
+	// ConfigHash is the digest of the URL, secret and events of the webhook,
+	// which is updated when they change.
+	ConfigHash string `json:"configHash,omitempty"`
+
+	// ObservedGeneration is the generation of the ConsoleApplication the
+	// webhook was last synced with.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+const (
+	// TriggerSourceWebhook is a commit reported by a push event.
+	TriggerSourceWebhook = "Webhook"
+
+	// TriggerSourcePoll is a commit found by polling the Git reference.
+	TriggerSourcePoll = "Poll"
+
+	// TriggerSourcePullRequest is the head commit of a pull request, recorded
+	// on its preview.
+	TriggerSourcePullRequest = "PullRequest"
+)
+
+// DeploymentReport is a deployment record created on the Git provider.
+type DeploymentReport struct {
+	// ID identifies the deployment on the Git provider.
+	ID string `json:"id"`
+
+	// GitURL is the repository the deployment was created on.
+	GitURL string `json:"gitURL"`
+
+	// CommitSHA is the deployed commit.
+	CommitSHA string `json:"commitSHA"`
+
+	// Environment is the environment the commit is deployed to.
+	Environment string `json:"environment"`
+
+	// State is the last state set on the deployment, empty until one is set.
+	//+kubebuilder:validation:Enum=in_progress;success;failure
+	State string `json:"state,omitempty"`
+
+	// EnvironmentURL is the URL of the Route of the application.
+	EnvironmentURL string `json:"environmentURL,omitempty"`
+}
+
+// GitTrigger is a new commit of the Git reference.
+type GitTrigger struct {
+	// Source is how the commit was reported.
+	//+kubebuilder:validation:Enum=Webhook;Poll;PullRequest
+	Source string `json:"source"`
+
+	// CommitSHA is the commit the reference was updated to.
+	CommitSHA string `json:"commitSHA"`
+
+	// DeliveryID identifies the webhook delivery reporting the commit.
+	DeliveryID string `json:"deliveryID,omitempty"`
+
+	// Time is when the commit was reported.
+	Time metav1.Time `json:"time"`
+}
+
+// GitStatus is the Git revision a ConsoleApplication builds from.
+type GitStatus struct {
+	// Reference is the Git reference as given in the spec, or the default
+	// branch of the repository when the spec omits it.
+	Reference string `json:"reference,omitempty"`
+
+	// ReferenceType is the kind of reference: Branch, Tag or Commit.
+	//+kubebuilder:validation:Enum=Branch;Tag;Commit
+	ReferenceType string `json:"referenceType,omitempty"`
+
+	// CommitSHA is the commit the reference pointed at when it was last resolved.
+	CommitSHA string `json:"commitSHA,omitempty"`
+
+	// ObservedTrigger is the last trigger handled when the reference was last
+	// resolved. A trigger recorded since is pending, whatever commit it reports.
+	ObservedTrigger *GitTrigger `json:"observedTrigger,omitempty"`
+}
+
+// DetectionStatus is the outcome of the build type detection in the context directory.
+type DetectionStatus struct {
+	// ImportStrategy is the import strategy picked when the spec asks for "auto".
+	ImportStrategy string `json:"importStrategy,omitempty"`
+
+	// BuilderImage is the builder ImageStream picked when the spec asks for "auto".
+	BuilderImage string `json:"builderImage,omitempty"`
+
+	// Candidates are the detected import strategies, best match first.
+	Candidates []DetectedStrategy `json:"candidates,omitempty"`
+
+	// RuntimeVersion records the builder image tag selected when the spec
+	// leaves it empty.
+	RuntimeVersion *RuntimeVersionStatus `json:"runtimeVersion,omitempty"`
+}
+
+// RuntimeVersionStatus is the runtime version requested by the repository and
+// the builder image tag selected for it.
+type RuntimeVersionStatus struct {
+	// Version is the version or version range requested by the repository,
+	// e.g. ">=18". It is empty when the repository requests none.
+	Version string `json:"version,omitempty"`
+
+	// File is the file the version was read from, e.g. "package.json".
+	File string `json:"file,omitempty"`
+
+	// BuilderImageTag is the selected tag of the builder ImageStream.
+	BuilderImageTag string `json:"builderImageTag,omitempty"`
+
+	// Reason explains why the tag was selected, or why none could be.
+	Reason string `json:"reason,omitempty"`
+}
+
+// DetectedStrategy is an import strategy matching files of the context directory.
+type DetectedStrategy struct {
+	// ImportStrategy is the detected import strategy.
+	ImportStrategy string `json:"importStrategy"`
+
+	// BuilderImage is the name of the builder ImageStream, for the builder-image strategy.
+	BuilderImage string `json:"builderImage,omitempty"`
+
+	// Files are the files of the context directory that matched.
+	Files []string `json:"files,omitempty"`
+
+	// Score ranks the candidates, the highest being the best match.
+	Score int32 `json:"score"`
 }
 
 //+kubebuilder:object:root=true
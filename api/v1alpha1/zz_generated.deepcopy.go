@@ -61,6 +61,21 @@ func (in *BuilderImage) DeepCopy() *BuilderImage {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommitStatusReport) DeepCopyInto(out *CommitStatusReport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommitStatusReport.
+func (in *CommitStatusReport) DeepCopy() *CommitStatusReport {
+	if in == nil {
+		return nil
+	}
+	out := new(CommitStatusReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsoleApplication) DeepCopyInto(out *ConsoleApplication) {
 	*out = *in
@@ -123,9 +138,10 @@ func (in *ConsoleApplicationList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsoleApplicationSpec) DeepCopyInto(out *ConsoleApplicationSpec) {
 	*out = *in
-	out.Git = in.Git
+	in.Git.DeepCopyInto(&out.Git)
 	in.BuildConfiguration.DeepCopyInto(&out.BuildConfiguration)
 	in.DeploymentConfiguration.DeepCopyInto(&out.DeploymentConfiguration)
+	out.Preview = in.Preview
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleApplicationSpec.
@@ -148,6 +164,42 @@ func (in *ConsoleApplicationStatus) DeepCopyInto(out *ConsoleApplicationStatus)
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.Git.DeepCopyInto(&out.Git)
+	if in.Detection != nil {
+		in, out := &in.Detection, &out.Detection
+		*out = new(DetectionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastTrigger != nil {
+		in, out := &in.LastTrigger, &out.LastTrigger
+		*out = new(GitTrigger)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(WebhookStatus)
+		**out = **in
+	}
+	if in.CommitStatus != nil {
+		in, out := &in.CommitStatus, &out.CommitStatus
+		*out = new(CommitStatusReport)
+		**out = **in
+	}
+	if in.Deployment != nil {
+		in, out := &in.Deployment, &out.Deployment
+		*out = new(DeploymentReport)
+		**out = **in
+	}
+	if in.LastPullRequestEvent != nil {
+		in, out := &in.LastPullRequestEvent, &out.LastPullRequestEvent
+		*out = new(PullRequestEvent)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Previews != nil {
+		in, out := &in.Previews, &out.Previews
+		*out = make([]PreviewStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleApplicationStatus.
@@ -160,6 +212,53 @@ func (in *ConsoleApplicationStatus) DeepCopy() *ConsoleApplicationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DetectedStrategy) DeepCopyInto(out *DetectedStrategy) {
+	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DetectedStrategy.
+func (in *DetectedStrategy) DeepCopy() *DetectedStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(DetectedStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DetectionStatus) DeepCopyInto(out *DetectionStatus) {
+	*out = *in
+	if in.Candidates != nil {
+		in, out := &in.Candidates, &out.Candidates
+		*out = make([]DetectedStrategy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RuntimeVersion != nil {
+		in, out := &in.RuntimeVersion, &out.RuntimeVersion
+		*out = new(RuntimeVersionStatus)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DetectionStatus.
+func (in *DetectionStatus) DeepCopy() *DetectionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DetectionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeploymentConfiguration) DeepCopyInto(out *DeploymentConfiguration) {
 	*out = *in
@@ -181,6 +280,21 @@ func (in *DeploymentConfiguration) DeepCopy() *DeploymentConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentReport) DeepCopyInto(out *DeploymentReport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentReport.
+func (in *DeploymentReport) DeepCopy() *DeploymentReport {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Env) DeepCopyInto(out *Env) {
 	*out = *in
@@ -219,6 +333,11 @@ func (in *Expose) DeepCopy() *Expose {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Git) DeepCopyInto(out *Git) {
 	*out = *in
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Git.
@@ -230,3 +349,115 @@ func (in *Git) DeepCopy() *Git {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitStatus) DeepCopyInto(out *GitStatus) {
+	*out = *in
+	if in.ObservedTrigger != nil {
+		in, out := &in.ObservedTrigger, &out.ObservedTrigger
+		*out = new(GitTrigger)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitStatus.
+func (in *GitStatus) DeepCopy() *GitStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GitStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitTrigger) DeepCopyInto(out *GitTrigger) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitTrigger.
+func (in *GitTrigger) DeepCopy() *GitTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(GitTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Preview) DeepCopyInto(out *Preview) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Preview.
+func (in *Preview) DeepCopy() *Preview {
+	if in == nil {
+		return nil
+	}
+	out := new(Preview)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreviewStatus) DeepCopyInto(out *PreviewStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreviewStatus.
+func (in *PreviewStatus) DeepCopy() *PreviewStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PreviewStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PullRequestEvent) DeepCopyInto(out *PullRequestEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PullRequestEvent.
+func (in *PullRequestEvent) DeepCopy() *PullRequestEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(PullRequestEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuntimeVersionStatus) DeepCopyInto(out *RuntimeVersionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuntimeVersionStatus.
+func (in *RuntimeVersionStatus) DeepCopy() *RuntimeVersionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RuntimeVersionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookStatus) DeepCopyInto(out *WebhookStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookStatus.
+func (in *WebhookStatus) DeepCopy() *WebhookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
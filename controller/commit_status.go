@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// routeGVK is the OpenShift Route exposing the application.
+var routeGVK = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+
+// CommitStatusReconciler reports the validation of the source of the commit of
+// the ConsoleApplications opting in with spec.git.reportCommitStatus as a
+// commit status on their Git provider: pending while the commit is reconciled,
+// then success or failure, following the Ready condition. Nothing is built or
+// deployed yet, so the status only claims that the source was validated.
+type CommitStatusReconciler struct {
+	client.Client
+}
+
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get
+
+// Reconcile sets the commit status of the ConsoleApplication when its outcome changed.
+func (r *CommitStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	consoleApplication := &appsv1alpha1.ConsoleApplication{}
+	if err := r.Get(ctx, req.NamespacedName, consoleApplication); err != nil {
+		if apierrors.IsNotFound(err) {
+			return NoRequeue()
+		}
+		return RequeueOnError(err)
+	}
+	original := consoleApplication.DeepCopy()
+	if !consoleApplication.Spec.Git.ReportCommitStatus || !consoleApplication.DeletionTimestamp.IsZero() {
+		if meta.RemoveStatusCondition(&consoleApplication.Status.Conditions,
+			appsv1alpha1.ConditionCommitStatusReported.String()) {
+			return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+		}
+		return NoRequeue()
+	}
+
+	status, ok := commitStatus(consoleApplication)
+	if !ok {
+		// No commit to report on yet
+		return NoRequeue()
+	}
+	status.TargetURL = applicationURL(ctx, r.Client, consoleApplication)
+	sha := consoleApplication.Status.Git.CommitSHA
+	report := &appsv1alpha1.CommitStatusReport{
+		CommitSHA:   sha,
+		State:       string(status.State),
+		Description: status.Description,
+		TargetURL:   status.TargetURL,
+	}
+	if previous := consoleApplication.Status.CommitStatus; previous != nil && *previous == *report {
+		return NoRequeue()
+	}
+
+	credentials, err := sourceCredentials(ctx, r.Client, consoleApplication)
+	if err != nil {
+		SetCommitStatusCondition(consoleApplication, metav1.ConditionFalse,
+			appsv1alpha1.ReasonSecretResourceNotFound.String(), err.Error())
+		return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+	}
+	gs := gitservice.New(consoleApplication.Spec.Git.Url, consoleApplication.Spec.Git.Reference, credentials, logger)
+	if err := gs.SetCommitStatus(ctx, sha, status); err != nil {
+		reason := gitservice.ReasonForError(err)
+		SetCommitStatusCondition(consoleApplication, metav1.ConditionFalse, reason.String(),
+			gitservice.ErrorMessage(reason, err))
+		if err := r.patchStatus(ctx, consoleApplication, original); err != nil {
+			return RequeueOnError(err)
+		}
+		return retryGitError(err)
+	}
+	logger.Info("Commit status set on the Git provider", "commitSHA", sha, "state", status.State)
+	consoleApplication.Status.CommitStatus = report
+	SetCommitStatusCondition(consoleApplication, metav1.ConditionTrue,
+		appsv1alpha1.ReasonCommitStatusReported.String(), "Commit "+sha+" reported as "+string(status.State))
+	return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+}
+
+// commitStatus returns the commit status reporting the validation of the
+// source of the ConsoleApplication, following its Ready condition, if its
+// commit is resolved. Failures to reach the repository are not reported: they
+// are not an outcome of the commit.
+func commitStatus(consoleApplication *appsv1alpha1.ConsoleApplication) (gitservice.CommitStatus, bool) {
+	conditions := consoleApplication.Status.Conditions
+	if consoleApplication.Status.Git.CommitSHA == "" ||
+		!meta.IsStatusConditionTrue(conditions, appsv1alpha1.ConditionGitRepoReachable.String()) {
+		return gitservice.CommitStatus{}, false
+	}
+	status := gitservice.CommitStatus{
+		State:       gitservice.CommitStatePending,
+		Context:     "console-application/source/" + consoleApplication.Namespace + "/" + consoleApplication.Name,
+		Description: "Validating the source",
+	}
+	ready := meta.FindStatusCondition(conditions, appsv1alpha1.ConditionReady.String())
+	if ready == nil {
+		return status, true
+	}
+	switch ready.Status {
+	case metav1.ConditionTrue:
+		// The message of the Ready condition would claim more than was done
+		status.State = gitservice.CommitStateSuccess
+		status.Description = "Source validated, nothing was built or deployed"
+	case metav1.ConditionFalse:
+		status.State = gitservice.CommitStateFailure
+		if ready.Message != "" {
+			status.Description = ready.Message
+		}
+	}
+	return status, true
+}
+
+// applicationURL returns the URL of the Route named after the ConsoleApplication,
+// or the empty string when there is none, e.g. outside of OpenShift.
+func applicationURL(ctx context.Context, reader client.Reader, consoleApplication *appsv1alpha1.ConsoleApplication) string {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(routeGVK)
+	err := reader.Get(ctx, client.ObjectKeyFromObject(consoleApplication), route)
+	if err != nil {
+		if !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			log.FromContext(ctx).Info("Cannot read the Route of the application: " + err.Error())
+		}
+		return ""
+	}
+	host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+	if host == "" {
+		ingresses, _, _ := unstructured.NestedSlice(route.Object, "status", "ingress")
+		if len(ingresses) > 0 {
+			if ingress, ok := ingresses[0].(map[string]any); ok {
+				host, _, _ = unstructured.NestedString(ingress, "host")
+			}
+		}
+	}
+	if host == "" {
+		return ""
+	}
+	scheme := "http://"
+	if _, found, _ := unstructured.NestedMap(route.Object, "spec", "tls"); found {
+		scheme = "https://"
+	}
+	path, _, _ := unstructured.NestedString(route.Object, "spec", "path")
+	return scheme + host + path
+}
+
+// patchStatus patches the status of the ConsoleApplication, failing on a
+// conflict with the status written by ConsoleApplicationReconciler.
+func (r *CommitStatusReconciler) patchStatus(
+	ctx context.Context, consoleApplication, original *appsv1alpha1.ConsoleApplication,
+) error {
+	patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+	return r.Status().Patch(ctx, consoleApplication, patch)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CommitStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("commitstatus").
+		For(&appsv1alpha1.ConsoleApplication{}).
+		Complete(r)
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+const statusSHA = "0123456789abcdef0123456789abcdef01234567"
+
+// fakeGithubStatuses serves the commit statuses API of the hello/world repository.
+type fakeGithubStatuses struct {
+	mu       sync.Mutex
+	statuses []map[string]any
+}
+
+func (f *fakeGithubStatuses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method != http.MethodPost || r.URL.Path != "/api/v3/repos/hello/world/statuses/"+statusSHA {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	status := map[string]any{}
+	_ = json.NewDecoder(r.Body).Decode(&status)
+	f.statuses = append(f.statuses, status)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+func TestCommitStatusReconciler(t *testing.T) {
+	statuses := &fakeGithubStatuses{}
+	registerFakeGithub(t, "github.status.example.com", statuses)
+
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{Git: appsv1alpha1.Git{
+			Url:                "https://github.status.example.com/hello/world",
+			ReportCommitStatus: true,
+		}},
+		Status: appsv1alpha1.ConsoleApplicationStatus{
+			Git: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: statusSHA},
+		},
+	}
+	SetGitServiceCondition(app, metav1.ConditionTrue, gitservice.ReasonSucceeded.String(), "")
+	SetReconciling(app, statusSHA)
+	route := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "hello", "namespace": "default"},
+		"spec":     map[string]any{"host": "hello.apps.example.com", "tls": map[string]any{"termination": "edge"}},
+	}}
+	route.SetGroupVersionKind(routeGVK)
+	c := newFakeClientBuilder(t).WithRESTMapper(newRouteMapper()).WithObjects(app, route).Build()
+	r := &CommitStatusReconciler{Client: c}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, statuses.statuses, 1)
+	assert.Equal(t, "pending", statuses.statuses[0]["state"])
+	assert.Equal(t, "console-application/source/default/hello", statuses.statuses[0]["context"])
+	assert.Equal(t, "https://hello.apps.example.com", statuses.statuses[0]["target_url"])
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, statuses.statuses, 1, "already reported")
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	SetSucceeded(app)
+	require.NoError(t, c.Status().Update(ctx, app))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, statuses.statuses, 2)
+	assert.Equal(t, "success", statuses.statuses[1]["state"])
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	assert.Equal(t, &appsv1alpha1.CommitStatusReport{
+		CommitSHA:   statusSHA,
+		State:       "success",
+		Description: "Source validated, nothing was built or deployed",
+		TargetURL:   "https://hello.apps.example.com",
+	}, app.Status.CommitStatus)
+	assert.True(t, meta.IsStatusConditionTrue(app.Status.Conditions,
+		appsv1alpha1.ConditionCommitStatusReported.String()))
+}
+
+func TestCommitStatus(t *testing.T) {
+	app := &appsv1alpha1.ConsoleApplication{ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"}}
+	_, ok := commitStatus(app)
+	assert.False(t, ok, "no commit")
+
+	app.Status.Git.CommitSHA = statusSHA
+	SetGitServiceCondition(app, metav1.ConditionFalse, gitservice.ReasonTimeout.String(), "")
+	SetFailed(app, gitservice.ReasonTimeout.String(), "Git Repository Not Reachable")
+	_, ok = commitStatus(app)
+	assert.False(t, ok, "not reachable")
+
+	SetGitServiceCondition(app, metav1.ConditionTrue, gitservice.ReasonSucceeded.String(), "")
+	SetReconciling(app, statusSHA)
+	status, ok := commitStatus(app)
+	require.True(t, ok)
+	assert.Equal(t, gitservice.CommitStatePending, status.State)
+
+	SetFailed(app, appsv1alpha1.ReasonImportStrategyNotDetected.String(), "Cannot detect the import strategy")
+	status, _ = commitStatus(app)
+	assert.Equal(t, gitservice.CommitStateFailure, status.State)
+	assert.Equal(t, "Cannot detect the import strategy", status.Description)
+
+	SetSucceeded(app)
+	status, _ = commitStatus(app)
+	assert.Equal(t, gitservice.CommitStateSuccess, status.State)
+	assert.Equal(t, "Source validated, nothing was built or deployed", status.Description)
+}
+
+func TestApplicationURL(t *testing.T) {
+	app := &appsv1alpha1.ConsoleApplication{ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"}}
+
+	noRoutes := newFakeClientBuilder(t).WithRESTMapper(meta.NewDefaultRESTMapper([]schema.GroupVersion{})).Build()
+	assert.Empty(t, applicationURL(context.Background(), noRoutes, app), "no Route API")
+
+	route := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "hello", "namespace": "default"},
+		"spec":     map[string]any{"path": "/api"},
+		"status":   map[string]any{"ingress": []any{map[string]any{"host": "hello.apps.example.com"}}},
+	}}
+	route.SetGroupVersionKind(routeGVK)
+	c := newFakeClientBuilder(t).WithRESTMapper(newRouteMapper()).WithObjects(route).Build()
+	assert.Equal(t, "http://hello.apps.example.com/api", applicationURL(context.Background(), c, app))
+}
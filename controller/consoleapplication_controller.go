@@ -22,7 +22,9 @@ import (
 
 	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -42,6 +44,7 @@ type ConsoleApplicationReconciler struct {
 //+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=image.openshift.io,resources=imagestreams,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -70,7 +73,7 @@ func (r *ConsoleApplicationReconciler) Reconcile(ctx context.Context, req ctrl.R
 
 	// Fetching the secret resource if specified in the CR
 	secretResourceName := consoleApplication.Spec.Git.SourceSecretRef
-	decodedSecret := ""
+	credentials := gitservice.Credentials{}
 	if secretResourceName != "" {
 		secret := &corev1.Secret{}
 		if err := r.Get(ctx, client.ObjectKey{
@@ -83,28 +86,127 @@ func (r *ConsoleApplicationReconciler) Reconcile(ctx context.Context, req ctrl.R
 			}
 			return NoRequeue()
 		}
-		// Decoding the secret data - Considering the Basic Auth secret
-		decodedSecret = string(secret.Data["password"])
+		// Decoding the secret data according to its type
+		var err error
+		if credentials, err = gitservice.CredentialsFromSecret(secret); err != nil {
+			reason := gitservice.ReasonForError(err)
+			message := gitservice.ErrorMessage(reason, err)
+			SetGitServiceCondition(consoleApplication, metav1.ConditionFalse, reason.String(), message)
+			SetFailed(consoleApplication, reason.String(), message)
+			if err := r.Status().Update(ctx, consoleApplication); err != nil {
+				return RequeueOnError(err)
+			}
+			return NoRequeue()
+		}
 	}
 
 	// Checking if the Git Repository is reachable
-	gs := gitservice.New(consoleApplication.Spec.Git.Url, consoleApplication.Spec.Git.Reference, decodedSecret, logger)
-	gStatus, gReason := gs.IsRepoReachable()
+	gs := gitservice.New(consoleApplication.Spec.Git.Url, consoleApplication.Spec.Git.Reference, credentials, logger)
+	if hasPendingTrigger(consoleApplication) {
+		// Resolving the reference again rather than reusing the result from before the push
+		logger.Info("New commit pushed: " + consoleApplication.Status.LastTrigger.CommitSHA)
+		gs.ForgetProbes()
+	}
+	if !isUrgentProbe(consoleApplication) {
+		// Leaving the end of the API quota to new and failing ConsoleApplications
+		if wait := gs.RateLimitHoldBack(); wait > 0 {
+			logger.Info("Git API quota is low, holding back the probe", "requeueAfter", wait)
+			return RequeueAfter(wait)
+		}
+	}
+	gStatus, gReason := gs.IsRepoReachable(ctx)
 	logger.Info("Git Repository Reachable: " + string(gStatus))
 
-	SetGitServiceCondition(consoleApplication, gStatus, gReason.String())
+	wasReachable := meta.IsStatusConditionTrue(consoleApplication.Status.Conditions,
+		appsv1alpha1.ConditionGitRepoReachable.String())
+	SetGitServiceCondition(consoleApplication, gStatus, gReason.String(), gs.Message())
+	if ref := gs.ResolvedRef(); ref != nil {
+		if ref.SHA != consoleApplication.Status.Git.CommitSHA || !wasReachable {
+			// The outcome of the previous commit, or of the failed probe, no longer holds
+			SetReconciling(consoleApplication, ref.SHA)
+		}
+		// Recording the exact revision the reference resolved to, and the trigger it was resolved for
+		consoleApplication.Status.Git = appsv1alpha1.GitStatus{
+			Reference:       ref.Name,
+			ReferenceType:   ref.Type.String(),
+			CommitSHA:       ref.SHA,
+			ObservedTrigger: consoleApplication.Status.LastTrigger.DeepCopy(),
+		}
+	}
 	if err := r.Status().Update(ctx, consoleApplication); err != nil {
 		return RequeueOnError(err)
 	}
 
 	if gStatus != metav1.ConditionTrue {
-		SetFailed(consoleApplication, gReason.String(), fmt.Sprintf("Git Repository Not Reachable: %s", gReason.String()))
+		SetFailed(consoleApplication, gReason.String(), fmt.Sprintf("Git Repository Not Reachable: %s", gs.Message()))
 		if err := r.Status().Update(ctx, consoleApplication); err != nil {
 			return RequeueOnError(err)
 		}
+		if wait := gs.RetryAfter(); wait > 0 {
+			// Probing again as soon as the rate limit resets
+			logger.Info("Git API rate limit exceeded", "requeueAfter", wait)
+			return RequeueAfter(wait)
+		}
+		if gitservice.IsTransient(gReason) {
+			// Timeouts and server errors are retried with the backoff of the work queue
+			return Requeue()
+		}
 		return NoRequeue()
 	}
 
+	// Checking the context directory before any build resource is created
+	cStatus, cReason, cMessage := gs.CheckContextDir(ctx, consoleApplication.Spec.Git.ContextDir)
+	logger.Info("Context directory found: " + string(cStatus))
+	SetContextDirCondition(consoleApplication, cStatus, cReason.String(), cMessage)
+	if cStatus == metav1.ConditionFalse {
+		SetFailed(consoleApplication, cReason.String(), cMessage)
+	}
+	if err := r.Status().Update(ctx, consoleApplication); err != nil {
+		return RequeueOnError(err)
+	}
+	if cStatus == metav1.ConditionFalse {
+		return NoRequeue()
+	}
+
+	// Detecting the import strategy from the files of the context directory
+	contextDir := consoleApplication.Spec.Git.ContextDir
+	files, err := contextDirFiles(ctx, gs, contextDir)
+	var detected *appsv1alpha1.DetectionStatus
+	if err == nil {
+		detected, err = detectImportStrategy(files, consoleApplication)
+	}
+	if err != nil && consoleApplication.Spec.ImportStrategy == appsv1alpha1.ImportStrategyAuto {
+		consoleApplication.Status.Detection = detected
+		SetFailed(consoleApplication, appsv1alpha1.ReasonImportStrategyNotDetected.String(),
+			fmt.Sprintf("Cannot detect the import strategy: %s", err.Error()))
+		if err := r.Status().Update(ctx, consoleApplication); err != nil {
+			return RequeueOnError(err)
+		}
+		return NoRequeue()
+	}
+	if err != nil {
+		// The detection only informs explicit import strategies
+		logger.Info("Cannot detect the import strategy: " + err.Error())
+		detected = &appsv1alpha1.DetectionStatus{}
+	}
+	consoleApplication.Status.Detection = detected
+
+	// Selecting the builder image tag matching the runtime version of the repository
+	builderImage := builderImageName(consoleApplication)
+	if builderImage != "" && consoleApplication.Spec.BuildConfiguration.BuilderImage.Image == "" {
+		runtimeVersion, err := selectBuilderImageTag(ctx, r, gs, contextDir, files, builderImage)
+		detected.RuntimeVersion = runtimeVersion
+		if err != nil {
+			// The selection only informs the build, e.g. the ImageStream may not exist on this cluster
+			logger.Info("Cannot select the builder image tag: " + runtimeVersion.Reason)
+		} else {
+			logger.Info("Builder image tag selected: " + builderImage + ":" + runtimeVersion.BuilderImageTag)
+		}
+	}
+	if err := r.Status().Update(ctx, consoleApplication); err != nil {
+		return RequeueOnError(err)
+	}
+
 	// Add the Strategy Service here: Return the list of resources config that needs to be created
 
 	logger.Info("All done!")
@@ -115,6 +217,25 @@ func (r *ConsoleApplicationReconciler) Reconcile(ctx context.Context, req ctrl.R
 	return NoRequeue()
 }
 
+// isUrgentProbe reports whether the Git repository must be probed regardless
+// of the remaining API quota: the ConsoleApplication is new or changed, or its
+// repository was not reachable, or a new commit was pushed.
+func isUrgentProbe(consoleApplication *appsv1alpha1.ConsoleApplication) bool {
+	condition := meta.FindStatusCondition(consoleApplication.Status.Conditions,
+		appsv1alpha1.ConditionGitRepoReachable.String())
+	return condition == nil || condition.Status != metav1.ConditionTrue ||
+		condition.ObservedGeneration != consoleApplication.Generation ||
+		hasPendingTrigger(consoleApplication)
+}
+
+// hasPendingTrigger reports whether a trigger was recorded since the Git
+// reference was last resolved. The commit it reports is not compared with the
+// resolved one: it may be an annotated tag, or already outdated.
+func hasPendingTrigger(consoleApplication *appsv1alpha1.ConsoleApplication) bool {
+	trigger := consoleApplication.Status.LastTrigger
+	return trigger != nil && !equality.Semantic.DeepEqual(trigger, consoleApplication.Status.Git.ObservedTrigger)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ConsoleApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
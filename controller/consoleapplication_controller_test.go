@@ -18,17 +18,30 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
 )
 
 var _ = Describe("ConsoleApplication Controller", func() {
@@ -83,3 +96,163 @@ var _ = Describe("ConsoleApplication Controller", func() {
 		})
 	})
 })
+
+const reconcileSHA = "3333333333333333333333333333333333333333"
+
+// fakeReconcileRepo serves the Github API of the hello/world repository: its
+// main branch has a Dockerfile at the root and an api directory.
+func fakeReconcileRepo(rateLimit http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range rateLimit {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch path := r.URL.Path; {
+		case path == "/api/v3/repos/hello/world":
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": "world", "default_branch": "main", "archived": false})
+		case path == "/api/v3/repos/hello/world/branches/main":
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": "main", "commit": map[string]any{"sha": reconcileSHA}})
+		case strings.TrimSuffix(path, "/") == "/api/v3/repos/hello/world/contents" && r.URL.Query().Get("ref") == reconcileSHA:
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"type": "file", "name": "Dockerfile", "path": "Dockerfile"},
+				{"type": "dir", "name": "api", "path": "api"},
+			})
+		case path == "/api/v3/repos/hello/world/contents/api" && r.URL.Query().Get("ref") == reconcileSHA:
+			_ = json.NewEncoder(w).Encode([]map[string]any{{"type": "file", "name": "README.md", "path": "api/README.md"}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestConsoleApplicationReconcile(t *testing.T) {
+	registerFakeGithub(t, "github.reconcile.example.com", fakeReconcileRepo(nil))
+
+	tests := []struct {
+		name      string
+		git       appsv1alpha1.Git
+		reason    string
+		reachable metav1.ConditionStatus
+		gitStatus appsv1alpha1.GitStatus
+		detected  string
+	}{
+		{
+			name:   "Missing secret",
+			git:    appsv1alpha1.Git{Reference: "main", SourceSecretRef: "missing"},
+			reason: appsv1alpha1.ReasonSecretResourceNotFound.String(),
+		},
+		{
+			name:      "Invalid secret",
+			git:       appsv1alpha1.Git{Reference: "main", SourceSecretRef: "invalid"},
+			reason:    gitservice.ReasonSecretKeyMissing.String(),
+			reachable: metav1.ConditionFalse,
+		},
+		{
+			name:      "Missing reference",
+			git:       appsv1alpha1.Git{Reference: "missing"},
+			reason:    gitservice.ReasonRepoNotFound.String(),
+			reachable: metav1.ConditionFalse,
+		},
+		{
+			name:      "Missing context directory",
+			git:       appsv1alpha1.Git{Reference: "main", ContextDir: "web"},
+			reason:    gitservice.ReasonContextDirNotFound.String(),
+			reachable: metav1.ConditionTrue,
+			gitStatus: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: reconcileSHA},
+		},
+		{
+			name:      "Detected",
+			git:       appsv1alpha1.Git{Reference: "main"},
+			reason:    appsv1alpha1.ReasonAllResourcesReady.String(),
+			reachable: metav1.ConditionTrue,
+			gitStatus: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: reconcileSHA},
+			detected:  appsv1alpha1.ImportStrategyDockerfile,
+		},
+		{
+			name:      "Nothing detected",
+			git:       appsv1alpha1.Git{Reference: "main", ContextDir: "api"},
+			reason:    appsv1alpha1.ReasonImportStrategyNotDetected.String(),
+			reachable: metav1.ConditionTrue,
+			gitStatus: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: reconcileSHA},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.git.Url = "https://github.reconcile.example.com/hello/world"
+			app := &appsv1alpha1.ConsoleApplication{
+				ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+				Spec:       appsv1alpha1.ConsoleApplicationSpec{Git: tt.git, ImportStrategy: appsv1alpha1.ImportStrategyAuto},
+			}
+			invalid := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "default"},
+				Type:       corev1.SecretTypeOpaque,
+				Data:       map[string][]byte{"unknown": []byte("s3cr3t")},
+			}
+			c := newFakeClientBuilder(t).WithObjects(app, invalid).Build()
+			r := &ConsoleApplicationReconciler{Client: c, Scheme: c.Scheme()}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+			result, err := r.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+			assert.Zero(t, result)
+
+			require.NoError(t, c.Get(context.Background(), req.NamespacedName, app))
+			ready := meta.FindStatusCondition(app.Status.Conditions, appsv1alpha1.ConditionReady.String())
+			require.NotNil(t, ready)
+			assert.Equal(t, tt.reason, ready.Reason, ready.Message)
+			if tt.reachable != "" {
+				assert.True(t, meta.IsStatusConditionPresentAndEqual(app.Status.Conditions,
+					appsv1alpha1.ConditionGitRepoReachable.String(), tt.reachable))
+			}
+			assert.Equal(t, tt.gitStatus, app.Status.Git)
+			if tt.detected != "" {
+				require.NotNil(t, app.Status.Detection)
+				assert.Equal(t, tt.detected, app.Status.Detection.ImportStrategy)
+			}
+		})
+	}
+}
+
+func TestConsoleApplicationReconcileHoldBack(t *testing.T) {
+	// The quota is below the reserve of the default 10%
+	rateLimit := http.Header{}
+	rateLimit.Set("X-RateLimit-Limit", "100")
+	rateLimit.Set("X-RateLimit-Remaining", "5")
+	rateLimit.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	registerFakeGithub(t, "github.holdback.example.com", fakeReconcileRepo(rateLimit))
+
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{Git: appsv1alpha1.Git{
+			Url:       "https://github.holdback.example.com/hello/world",
+			Reference: "main",
+		}},
+	}
+	c := newFakeClientBuilder(t).WithObjects(app).Build()
+	r := &ConsoleApplicationReconciler{Client: c, Scheme: c.Scheme()}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+	result, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Zero(t, result, "the first probe is urgent")
+
+	result, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Greater(t, result.RequeueAfter, 59*time.Minute, "held back until the quota resets")
+	assert.LessOrEqual(t, result.RequeueAfter, time.Hour)
+
+	// A new commit is probed regardless of the quota
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, app))
+	app.Status.LastTrigger = &appsv1alpha1.GitTrigger{
+		Source:    appsv1alpha1.TriggerSourceWebhook,
+		CommitSHA: reconcileSHA,
+		Time:      metav1.NewTime(time.Now()),
+	}
+	require.NoError(t, c.Status().Update(context.Background(), app))
+	result, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Zero(t, result)
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, app))
+	assert.Equal(t, app.Status.LastTrigger, app.Status.Git.ObservedTrigger)
+}
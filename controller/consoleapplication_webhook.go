@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// defaultingTimeout bounds the lookup of the default branch, well within the
+// 10 seconds the API server waits for the webhook.
+const defaultingTimeout = 5 * time.Second
+
+// log is for logging in this package.
+var consoleapplicationlog = logf.Log.WithName("consoleapplication-resource")
+
+//+kubebuilder:webhook:path=/mutate-apps-console-dev-v1alpha1-consoleapplication,mutating=true,failurePolicy=ignore,sideEffects=None,groups=apps.console.dev,resources=consoleapplications,verbs=create,versions=v1alpha1,name=mconsoleapplication.kb.io,admissionReviewVersions=v1
+
+// ConsoleApplicationDefaulter defaults the Git reference of new ConsoleApplications
+// to the default branch of their repository, so that a Git URL is enough.
+type ConsoleApplicationDefaulter struct {
+	// Reader reads the source secret of the ConsoleApplication.
+	Reader client.Reader
+}
+
+var _ webhook.CustomDefaulter = &ConsoleApplicationDefaulter{}
+
+// SetupWebhookWithManager registers the webhooks of ConsoleApplication with the manager.
+func (d *ConsoleApplicationDefaulter) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&appsv1alpha1.ConsoleApplication{}).
+		WithDefaulter(d).
+		Complete()
+}
+
+// Default implements webhook.CustomDefaulter. Defaulting is best effort: when
+// the default branch cannot be looked up within defaultingTimeout, the
+// reference is left empty and the controller reports the failure in the status.
+func (d *ConsoleApplicationDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	consoleApplication, ok := obj.(*appsv1alpha1.ConsoleApplication)
+	if !ok {
+		return fmt.Errorf("expected a ConsoleApplication but got a %T", obj)
+	}
+	git := &consoleApplication.Spec.Git
+	if git.Url == "" || git.Reference != "" {
+		return nil
+	}
+	logger := consoleapplicationlog.WithValues("name", consoleApplication.Name, "namespace", consoleApplication.Namespace)
+	logger.Info("default", "url", git.Url)
+
+	ctx, cancel := context.WithTimeout(ctx, defaultingTimeout)
+	defer cancel()
+	credentials, err := sourceCredentials(ctx, d.Reader, consoleApplication)
+	if err != nil {
+		logger.Error(err, "Cannot use the source secret, leaving the Git reference empty")
+		return nil
+	}
+	branch, err := gitservice.New(git.Url, "", credentials, logger).DefaultBranch(ctx)
+	if err != nil {
+		logger.Error(err, "Cannot detect the default branch, leaving the Git reference empty")
+		return nil
+	}
+	git.Reference = branch
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+)
+
+func TestConsoleApplicationDefaulter(t *testing.T) {
+	registerFakeGithub(t, "github.defaulter.example.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/hello/world" || r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": "world", "default_branch": "trunk"})
+	}))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"token": []byte("s3cr3t")},
+	}
+	defaulter := &ConsoleApplicationDefaulter{Reader: fake.NewClientBuilder().WithObjects(secret).Build()}
+
+	tests := []struct {
+		name string
+		git  appsv1alpha1.Git
+		want string
+	}{
+		{"Default branch", appsv1alpha1.Git{Url: "https://github.defaulter.example.com/hello/world", SourceSecretRef: "token"}, "trunk"},
+		{"Reference kept", appsv1alpha1.Git{Url: "https://github.defaulter.example.com/hello/world", Reference: "v1.0.0"}, "v1.0.0"},
+		{"Missing secret", appsv1alpha1.Git{Url: "https://github.defaulter.example.com/hello/world", SourceSecretRef: "missing"}, ""},
+		{"Missing repository", appsv1alpha1.Git{Url: "https://github.defaulter.example.com/hello/missing", SourceSecretRef: "token"}, ""},
+		{"No URL", appsv1alpha1.Git{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consoleApplication := &appsv1alpha1.ConsoleApplication{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+				Spec:       appsv1alpha1.ConsoleApplicationSpec{Git: tt.git},
+			}
+			require.NoError(t, defaulter.Default(context.Background(), consoleApplication))
+			assert.Equal(t, tt.want, consoleApplication.Spec.Git.Reference)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	"github.com/openshift-console/console-application-operator/pkg/detection"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// BuilderImageNamespace is the namespace of the builder ImageStreams shipped with OpenShift.
+const BuilderImageNamespace = "openshift"
+
+// imageStreamGVK is the kind of the builder images, read as unstructured
+// objects to avoid depending on the OpenShift API types.
+var imageStreamGVK = schema.GroupVersionKind{Group: "image.openshift.io", Version: "v1", Kind: "ImageStream"}
+
+// contextDirFiles returns the names of the files directly in the context directory.
+func contextDirFiles(ctx context.Context, gs *gitservice.GitService, contextDir string) ([]string, error) {
+	entries, err := gs.ListDirectory(ctx, contextDir)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			err = fmt.Errorf("the files of the repository cannot be listed: %w", err)
+		}
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type == gitservice.EntryTypeFile {
+			files = append(files, entry.Name)
+		}
+	}
+	return files, nil
+}
+
+// detectImportStrategy ranks the import strategies matching the files of the
+// context directory. When the spec asks for the "auto" import strategy, the
+// best candidate is picked and an error is returned if there is none.
+func detectImportStrategy(
+	files []string, consoleApplication *appsv1alpha1.ConsoleApplication,
+) (*appsv1alpha1.DetectionStatus, error) {
+	status := &appsv1alpha1.DetectionStatus{}
+	for _, candidate := range detection.Detect(files) {
+		status.Candidates = append(status.Candidates, appsv1alpha1.DetectedStrategy{
+			ImportStrategy: candidate.Strategy.String(),
+			BuilderImage:   candidate.BuilderImage,
+			Files:          candidate.Files,
+			Score:          int32(candidate.Score),
+		})
+	}
+	if consoleApplication.Spec.ImportStrategy == appsv1alpha1.ImportStrategyAuto {
+		if len(status.Candidates) == 0 {
+			return status, errors.New("no devfile, Dockerfile or known source files found in the context directory")
+		}
+		status.ImportStrategy = status.Candidates[0].ImportStrategy
+		status.BuilderImage = status.Candidates[0].BuilderImage
+	}
+	return status, nil
+}
+
+// builderImageName returns the name of the builder ImageStream to build with,
+// from the spec or from the detection. It is empty unless the spec asks for the
+// builder image strategy, or for the "auto" one and a builder image was detected.
+func builderImageName(consoleApplication *appsv1alpha1.ConsoleApplication) string {
+	name := consoleApplication.Spec.BuildConfiguration.BuilderImage.Name
+	detected := consoleApplication.Status.Detection
+	switch consoleApplication.Spec.ImportStrategy {
+	case appsv1alpha1.ImportStrategyBuilderImage:
+		if name != "" || detected == nil {
+			return name
+		}
+		// The best builder image candidate, as the spec asks for a builder image
+		// even if a devfile or Dockerfile ranks higher
+		for _, candidate := range detected.Candidates {
+			if candidate.BuilderImage != "" {
+				return candidate.BuilderImage
+			}
+		}
+		return ""
+	case appsv1alpha1.ImportStrategyAuto:
+		if detected == nil || detected.ImportStrategy != appsv1alpha1.ImportStrategyBuilderImage {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+		return detected.BuilderImage
+	default:
+		return ""
+	}
+}
+
+// selectBuilderImageTag selects the tag of the builderImage ImageStream
+// matching the runtime version requested by the files of the context
+// directory. The returned status records the reason of the choice, or of the
+// failure along with the error.
+func selectBuilderImageTag(
+	ctx context.Context, reader client.Reader, gs *gitservice.GitService,
+	contextDir string, files []string, builderImage string,
+) (*appsv1alpha1.RuntimeVersionStatus, error) {
+	status := &appsv1alpha1.RuntimeVersionStatus{}
+	hint, err := detection.DetectVersion(builderImage, files, func(name string) ([]byte, error) {
+		return gs.GetFile(ctx, path.Join(contextDir, name))
+	})
+	if err != nil {
+		status.Reason = err.Error()
+		return status, err
+	}
+	if hint != nil {
+		status.Version = hint.Constraint
+		status.File = hint.File
+	}
+
+	tags, err := builderImageTags(ctx, reader, builderImage)
+	if err != nil {
+		status.Reason = fmt.Sprintf("Cannot read the %s ImageStream: %s", builderImage, err.Error())
+		return status, err
+	}
+	tag, reason, err := detection.SelectTag(builderImage, hint, tags)
+	if err != nil {
+		status.Reason = err.Error()
+		return status, err
+	}
+	status.BuilderImageTag = tag
+	status.Reason = reason
+	return status, nil
+}
+
+// builderImageTags returns the tags of a builder ImageStream, except the ones
+// hidden from the OpenShift console.
+func builderImageTags(ctx context.Context, reader client.Reader, name string) ([]string, error) {
+	imageStream := &unstructured.Unstructured{}
+	imageStream.SetGroupVersionKind(imageStreamGVK)
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: BuilderImageNamespace, Name: name}, imageStream); err != nil {
+		return nil, err
+	}
+	specTags, _, err := unstructured.NestedSlice(imageStream.Object, "spec", "tags")
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, specTag := range specTags {
+		tag, ok := specTag.(map[string]any)
+		if !ok {
+			continue
+		}
+		tagName, _, _ := unstructured.NestedString(tag, "name")
+		labels, _, _ := unstructured.NestedString(tag, "annotations", "tags")
+		if tagName == "" || strings.Contains(","+labels+",", ",hidden,") {
+			continue
+		}
+		tags = append(tags, tagName)
+	}
+	return tags, nil
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+)
+
+func TestBuilderImageTags(t *testing.T) {
+	imageStream := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "nodejs", "namespace": BuilderImageNamespace},
+		"spec": map[string]any{"tags": []any{
+			map[string]any{"name": "16-ubi8", "annotations": map[string]any{"tags": "builder,nodejs,hidden"}},
+			map[string]any{"name": "18-ubi8", "annotations": map[string]any{"tags": "builder,nodejs"}},
+			map[string]any{"name": "latest"},
+		}},
+	}}
+	imageStream.SetGroupVersionKind(imageStreamGVK)
+	reader := fake.NewClientBuilder().WithObjects(imageStream).Build()
+
+	tags, err := builderImageTags(context.Background(), reader, "nodejs")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"18-ubi8", "latest"}, tags)
+
+	_, err = builderImageTags(context.Background(), reader, "golang")
+	assert.Error(t, err)
+}
+
+func TestBuilderImageName(t *testing.T) {
+	detected := &appsv1alpha1.DetectionStatus{
+		ImportStrategy: appsv1alpha1.ImportStrategyBuilderImage,
+		BuilderImage:   "nodejs",
+	}
+	tests := []struct {
+		name     string
+		strategy string
+		spec     string
+		detected *appsv1alpha1.DetectionStatus
+		want     string
+	}{
+		{"Spec", appsv1alpha1.ImportStrategyBuilderImage, "golang", detected, "golang"},
+		{"Auto", appsv1alpha1.ImportStrategyAuto, "", detected, "nodejs"},
+		{"Auto with a spec", appsv1alpha1.ImportStrategyAuto, "golang", detected, "golang"},
+		{"Auto without detection", appsv1alpha1.ImportStrategyAuto, "golang", nil, ""},
+		{"No strategy", "", "golang", detected, ""},
+		{"Auto Dockerfile", appsv1alpha1.ImportStrategyAuto, "golang",
+			&appsv1alpha1.DetectionStatus{ImportStrategy: appsv1alpha1.ImportStrategyDockerfile}, ""},
+		{"Dockerfile", appsv1alpha1.ImportStrategyDockerfile, "golang", detected, ""},
+		{"Builder image without a name", appsv1alpha1.ImportStrategyBuilderImage, "", &appsv1alpha1.DetectionStatus{
+			Candidates: []appsv1alpha1.DetectedStrategy{
+				{ImportStrategy: appsv1alpha1.ImportStrategyDockerfile},
+				{ImportStrategy: appsv1alpha1.ImportStrategyBuilderImage, BuilderImage: "python"},
+			},
+		}, "python"},
+		{"Builder image without a name nor detection", appsv1alpha1.ImportStrategyBuilderImage, "", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consoleApplication := &appsv1alpha1.ConsoleApplication{}
+			consoleApplication.Spec.ImportStrategy = tt.strategy
+			consoleApplication.Spec.BuildConfiguration.BuilderImage.Name = tt.spec
+			consoleApplication.Status.Detection = tt.detected
+			assert.Equal(t, tt.want, builderImageName(consoleApplication))
+		})
+	}
+}
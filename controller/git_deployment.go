@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// GitDeploymentReconciler records the commits of the ConsoleApplications
+// opting in with spec.git.reportDeployment as deployments on their Git
+// provider, to the environment named after their namespace. A deployment is
+// only created once the Deployment named after the ConsoleApplication rolls
+// out the commit, as told by the CommitSHAAnnotation of its pod template, and
+// moved through in_progress, success and failure with the rollout. The
+// operator does not create the Deployment itself yet.
+type GitDeploymentReconciler struct {
+	client.Client
+}
+
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+
+// Reconcile creates the deployment of the commit of the ConsoleApplication,
+// or updates its state when the outcome changed.
+func (r *GitDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	consoleApplication := &appsv1alpha1.ConsoleApplication{}
+	if err := r.Get(ctx, req.NamespacedName, consoleApplication); err != nil {
+		if apierrors.IsNotFound(err) {
+			return NoRequeue()
+		}
+		return RequeueOnError(err)
+	}
+	original := consoleApplication.DeepCopy()
+	if !consoleApplication.Spec.Git.ReportDeployment || !consoleApplication.DeletionTimestamp.IsZero() {
+		// The deployments already recorded are left as history
+		if meta.RemoveStatusCondition(&consoleApplication.Status.Conditions,
+			appsv1alpha1.ConditionDeploymentReported.String()) {
+			return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+		}
+		return NoRequeue()
+	}
+
+	status, ok, err := r.rolloutStatus(ctx, consoleApplication)
+	if err != nil {
+		return RequeueOnError(err)
+	}
+	if !ok {
+		// Nothing rolls out the commit yet
+		return NoRequeue()
+	}
+	gitStatus := consoleApplication.Status.Git
+	deployment := gitservice.Deployment{
+		Ref:         gitStatus.Reference,
+		Tag:         gitStatus.ReferenceType == gitservice.RefTypeTag.String(),
+		SHA:         gitStatus.CommitSHA,
+		Environment: consoleApplication.Namespace,
+	}
+	report := &appsv1alpha1.DeploymentReport{
+		GitURL:         consoleApplication.Spec.Git.Url,
+		CommitSHA:      deployment.SHA,
+		Environment:    deployment.Environment,
+		State:          string(status.State),
+		EnvironmentURL: status.EnvironmentURL,
+	}
+	id := ""
+	if previous := consoleApplication.Status.Deployment; previous != nil && previous.GitURL == report.GitURL &&
+		previous.CommitSHA == report.CommitSHA && previous.Environment == report.Environment {
+		// Updating the deployment of the commit rather than creating another one
+		id = previous.ID
+		report.ID = id
+		if *previous == *report {
+			return NoRequeue()
+		}
+	}
+
+	credentials, err := sourceCredentials(ctx, r.Client, consoleApplication)
+	if err != nil {
+		SetDeploymentCondition(consoleApplication, metav1.ConditionFalse,
+			appsv1alpha1.ReasonSecretResourceNotFound.String(), err.Error())
+		return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+	}
+	gs := gitservice.New(consoleApplication.Spec.Git.Url, consoleApplication.Spec.Git.Reference, credentials, logger)
+	report.ID, err = gs.ReportDeployment(ctx, id, deployment, status)
+	if err != nil {
+		if report.ID != "" {
+			// The deployment was created but its state not set: setting it on the next attempt
+			report.State, report.EnvironmentURL = "", ""
+			consoleApplication.Status.Deployment = report
+		}
+		reason := gitservice.ReasonForError(err)
+		SetDeploymentCondition(consoleApplication, metav1.ConditionFalse, reason.String(),
+			gitservice.ErrorMessage(reason, err))
+		if err := r.patchStatus(ctx, consoleApplication, original); err != nil {
+			return RequeueOnError(err)
+		}
+		return retryGitError(err)
+	}
+	logger.Info("Deployment recorded on the Git provider", "id", report.ID, "commitSHA", report.CommitSHA,
+		"state", report.State)
+	consoleApplication.Status.Deployment = report
+	SetDeploymentCondition(consoleApplication, metav1.ConditionTrue, appsv1alpha1.ReasonDeploymentReported.String(),
+		"Commit "+report.CommitSHA+" deployed to "+report.Environment+": "+report.State)
+	return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+}
+
+// rolloutStatus returns the state of the deployment of the commit of the
+// ConsoleApplication: the state of the rollout of the Deployment named after
+// it. It is not ok while the Deployment does not run the commit.
+func (r *GitDeploymentReconciler) rolloutStatus(
+	ctx context.Context, consoleApplication *appsv1alpha1.ConsoleApplication,
+) (gitservice.DeploymentStatus, bool, error) {
+	sha := consoleApplication.Status.Git.CommitSHA
+	if sha == "" {
+		return gitservice.DeploymentStatus{}, false, nil
+	}
+	workload := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(consoleApplication), workload); err != nil {
+		return gitservice.DeploymentStatus{}, false, client.IgnoreNotFound(err)
+	}
+	if workload.Spec.Template.Annotations[appsv1alpha1.CommitSHAAnnotation] != sha {
+		return gitservice.DeploymentStatus{}, false, nil
+	}
+	switch {
+	case rolloutFailed(workload):
+		return gitservice.DeploymentStatus{
+			State:       gitservice.DeploymentStateFailure,
+			Description: "The rollout exceeded its progress deadline",
+		}, true, nil
+	case rolloutComplete(workload):
+		return gitservice.DeploymentStatus{
+			State:          gitservice.DeploymentStateSuccess,
+			Description:    "Rolled out",
+			EnvironmentURL: applicationURL(ctx, r.Client, consoleApplication),
+		}, true, nil
+	default:
+		return gitservice.DeploymentStatus{
+			State:       gitservice.DeploymentStateInProgress,
+			Description: "Rolling out",
+		}, true, nil
+	}
+}
+
+// rolloutComplete reports whether all the replicas of the Deployment run its
+// latest template and are available, as kubectl rollout status does.
+func rolloutComplete(workload *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if workload.Spec.Replicas != nil {
+		replicas = *workload.Spec.Replicas
+	}
+	status := workload.Status
+	return status.ObservedGeneration >= workload.Generation &&
+		status.UpdatedReplicas == replicas && status.Replicas == replicas && status.AvailableReplicas == replicas
+}
+
+// rolloutFailed reports whether the rollout of the Deployment exceeded its
+// progress deadline.
+func rolloutFailed(workload *appsv1.Deployment) bool {
+	for _, condition := range workload.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+// applicationOfDeployment returns the ConsoleApplication named after a
+// Deployment annotated with the commit it runs.
+func applicationOfDeployment(_ context.Context, obj client.Object) []reconcile.Request {
+	workload, ok := obj.(*appsv1.Deployment)
+	if !ok || workload.Spec.Template.Annotations[appsv1alpha1.CommitSHAAnnotation] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(workload)}}
+}
+
+// patchStatus patches the status of the ConsoleApplication, failing on a
+// conflict with the status written by ConsoleApplicationReconciler.
+func (r *GitDeploymentReconciler) patchStatus(
+	ctx context.Context, consoleApplication, original *appsv1alpha1.ConsoleApplication,
+) error {
+	patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+	return r.Status().Patch(ctx, consoleApplication, patch)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GitDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gitdeployment").
+		For(&appsv1alpha1.ConsoleApplication{}).
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(applicationOfDeployment)).
+		Complete(r)
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// fakeGithubDeployments serves the deployments API of the hello/world repository.
+type fakeGithubDeployments struct {
+	mu          sync.Mutex
+	deployments []map[string]any
+	// states are the states set on each deployment, by ID.
+	states map[string][]string
+}
+
+func (f *fakeGithubDeployments) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body := map[string]any{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	id, isStatus := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v3/repos/hello/world/deployments/"),
+		"/statuses")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v3/repos/hello/world/deployments":
+		f.deployments = append(f.deployments, body)
+		body["id"] = len(f.deployments)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(body)
+	case r.Method == http.MethodPost && isStatus:
+		f.states[id] = append(f.states[id], body["state"].(string))
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(body)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGitDeploymentReconciler(t *testing.T) {
+	const newSHA = "89abcdef0123456789abcdef0123456789abcdef"
+	deployments := &fakeGithubDeployments{states: map[string][]string{}}
+	registerFakeGithub(t, "github.deployment.example.com", deployments)
+
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "hello-dev"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{Git: appsv1alpha1.Git{
+			Url:              "https://github.deployment.example.com/hello/world",
+			ReportDeployment: true,
+		}},
+		Status: appsv1alpha1.ConsoleApplicationStatus{
+			Git: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: statusSHA},
+		},
+	}
+	SetGitServiceCondition(app, metav1.ConditionTrue, gitservice.ReasonSucceeded.String(), "")
+	SetSucceeded(app)
+	c := newFakeClientBuilder(t).WithObjects(app).Build()
+	r := &GitDeploymentReconciler{Client: c}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, deployments.deployments, "nothing rolls out the commit")
+
+	// The Deployment rolls out the commit
+	workload := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "hello-dev"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{appsv1alpha1.CommitSHAAnnotation: statusSHA}},
+		}},
+	}
+	require.NoError(t, c.Create(ctx, workload))
+	assert.Equal(t, []reconcile.Request{req}, applicationOfDeployment(ctx, workload))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, deployments.deployments, 1)
+	assert.Equal(t, statusSHA, deployments.deployments[0]["ref"])
+	assert.Equal(t, "hello-dev", deployments.deployments[0]["environment"])
+	assert.Equal(t, []string{"in_progress"}, deployments.states["1"])
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, deployments.states["1"], 1, "already reported")
+
+	workload.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	require.NoError(t, c.Status().Update(ctx, workload))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, deployments.deployments, 1, "same commit")
+	assert.Equal(t, []string{"in_progress", "success"}, deployments.states["1"])
+
+	// A new commit is only recorded once the Deployment rolls it out
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	app.Status.Git.CommitSHA = newSHA
+	SetReconciling(app, newSHA)
+	require.NoError(t, c.Status().Update(ctx, app))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, deployments.deployments, 1, "the Deployment runs the previous commit")
+
+	workload.Spec.Template.Annotations[appsv1alpha1.CommitSHAAnnotation] = newSHA
+	require.NoError(t, c.Update(ctx, workload))
+	workload.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded",
+	}}
+	require.NoError(t, c.Status().Update(ctx, workload))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, deployments.deployments, 2, "new commit")
+	assert.Equal(t, []string{"failure"}, deployments.states["2"])
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	assert.Equal(t, &appsv1alpha1.DeploymentReport{
+		ID:          "2",
+		GitURL:      "https://github.deployment.example.com/hello/world",
+		CommitSHA:   newSHA,
+		Environment: "hello-dev",
+		State:       "failure",
+	}, app.Status.Deployment)
+	assert.True(t, meta.IsStatusConditionTrue(app.Status.Conditions,
+		appsv1alpha1.ConditionDeploymentReported.String()))
+}
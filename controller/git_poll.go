@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// pollJitter is the largest fraction of the poll interval added to it, so that
+// the ConsoleApplications polled together drift apart.
+const pollJitter = 0.2
+
+// GitPollReconciler polls the Git reference of the ConsoleApplications setting
+// spec.git.pollInterval. A new commit is recorded in status.lastTrigger, which
+// reconciles the ConsoleApplication again, as a push event would.
+type GitPollReconciler struct {
+	client.Client
+
+	mu sync.Mutex
+	// polls are the next polls of the ConsoleApplications. They are only kept
+	// in memory: after a restart, the first polls are spread over an interval.
+	polls map[types.NamespacedName]pollSchedule
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+}
+
+// pollSchedule is the next poll of a ConsoleApplication.
+type pollSchedule struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// Reconcile polls the Git reference when it is due, and requeues the
+// ConsoleApplication for its next poll.
+func (r *GitPollReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	consoleApplication := &appsv1alpha1.ConsoleApplication{}
+	if err := r.Get(ctx, req.NamespacedName, consoleApplication); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.unschedule(req.NamespacedName)
+			return NoRequeue()
+		}
+		return RequeueOnError(err)
+	}
+	interval := pollInterval(consoleApplication)
+	if interval == 0 || !consoleApplication.DeletionTimestamp.IsZero() {
+		r.unschedule(req.NamespacedName)
+		return NoRequeue()
+	}
+
+	now := r.clock()
+	if next := r.schedule(req.NamespacedName, interval, now); now.Before(next) {
+		return RequeueAfter(next.Sub(now))
+	}
+	wait := interval + rand.N(time.Duration(float64(interval)*pollJitter))
+	if holdBack := r.poll(ctx, consoleApplication); holdBack > wait {
+		wait = holdBack
+	}
+	r.mu.Lock()
+	r.polls[req.NamespacedName] = pollSchedule{interval: interval, next: now.Add(wait)}
+	r.mu.Unlock()
+	return RequeueAfter(wait)
+}
+
+// poll resolves the Git reference and records a new commit. It returns how
+// long to wait for the API quota of the Git host, if it is low or exhausted.
+func (r *GitPollReconciler) poll(ctx context.Context, consoleApplication *appsv1alpha1.ConsoleApplication) time.Duration {
+	logger := log.FromContext(ctx)
+	previous := consoleApplication.Status.Git
+	if previous.CommitSHA == "" || previous.ReferenceType == gitservice.RefTypeCommit.String() {
+		// The reference is not resolved yet, or never moves
+		return 0
+	}
+	credentials, err := sourceCredentials(ctx, r.Client, consoleApplication)
+	if err != nil {
+		logger.Info("Cannot poll the Git reference: " + err.Error())
+		return 0
+	}
+	gs := gitservice.New(consoleApplication.Spec.Git.Url, consoleApplication.Spec.Git.Reference, credentials, logger)
+	if wait := gs.RateLimitHoldBack(); wait > 0 {
+		logger.Info("Git API quota is low, holding back the poll", "requeueAfter", wait)
+		return wait
+	}
+	if status, reason := gs.IsRepoReachable(ctx); status != metav1.ConditionTrue {
+		// Failures are reported by ConsoleApplicationReconciler
+		logger.Info("Cannot poll the Git reference", "reason", reason, "message", gs.Message())
+		return gs.RetryAfter()
+	}
+	sha := gs.ResolvedRef().SHA
+	trigger := consoleApplication.Status.LastTrigger
+	if sha == previous.CommitSHA || (trigger != nil && trigger.CommitSHA == sha) {
+		return 0
+	}
+
+	original := consoleApplication.DeepCopy()
+	consoleApplication.Status.LastTrigger = &appsv1alpha1.GitTrigger{
+		Source:    appsv1alpha1.TriggerSourcePoll,
+		CommitSHA: sha,
+		Time:      metav1.NewTime(r.clock()),
+	}
+	if err := r.Status().Patch(ctx, consoleApplication, client.MergeFrom(original)); err != nil {
+		// The commit is found again by the next poll
+		logger.Error(err, "Cannot record the new commit")
+		return 0
+	}
+	logger.Info("New commit found by polling: " + sha)
+	return 0
+}
+
+// schedule returns the next poll of the ConsoleApplication. The first poll,
+// and the first after the interval changed, happen at a random time within
+// the interval, so that ConsoleApplications do not poll all at once.
+func (r *GitPollReconciler) schedule(key types.NamespacedName, interval time.Duration, now time.Time) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.polls == nil {
+		r.polls = map[types.NamespacedName]pollSchedule{}
+	}
+	scheduled, ok := r.polls[key]
+	if !ok || scheduled.interval != interval {
+		scheduled = pollSchedule{interval: interval, next: now.Add(rand.N(interval))}
+		r.polls[key] = scheduled
+	}
+	return scheduled.next
+}
+
+// unschedule forgets the next poll of the ConsoleApplication.
+func (r *GitPollReconciler) unschedule(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.polls, key)
+}
+
+func (r *GitPollReconciler) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// pollInterval returns the poll interval of the ConsoleApplication, at least
+// MinPollInterval, or 0 if it is not polled.
+func pollInterval(consoleApplication *appsv1alpha1.ConsoleApplication) time.Duration {
+	interval := consoleApplication.Spec.Git.PollInterval
+	if interval == nil || interval.Duration <= 0 {
+		return 0
+	}
+	return max(interval.Duration, appsv1alpha1.MinPollInterval)
+}
+
+// SetupWithManager sets up the controller with the Manager. Only changes of
+// the spec reschedule the polls.
+func (r *GitPollReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gitpoll").
+		For(&appsv1alpha1.ConsoleApplication{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
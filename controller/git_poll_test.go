@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+)
+
+func TestGitPollReconciler(t *testing.T) {
+	const (
+		oldSHA = "1111111111111111111111111111111111111111"
+		newSHA = "2222222222222222222222222222222222222222"
+	)
+	registerFakeGithub(t, "github.poll.example.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/hello/world":
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": "world", "default_branch": "main"})
+		case "/api/v3/repos/hello/world/branches/main":
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": "main", "commit": map[string]any{"sha": newSHA}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{Git: appsv1alpha1.Git{
+			Url:          "https://github.poll.example.com/hello/world",
+			Reference:    "main",
+			PollInterval: &metav1.Duration{Duration: 10 * time.Minute},
+		}},
+		Status: appsv1alpha1.ConsoleApplicationStatus{
+			Git: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: oldSHA},
+		},
+	}
+	c := newFakeClientBuilder(t).WithObjects(app).Build()
+	now := time.Unix(1700000000, 0)
+	r := &GitPollReconciler{Client: c, now: func() time.Time { return now }}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+	result, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Less(t, result.RequeueAfter, 10*time.Minute, "the first poll is spread within the interval")
+
+	now = now.Add(10 * time.Minute)
+	result, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.RequeueAfter, 10*time.Minute)
+	assert.LessOrEqual(t, result.RequeueAfter, 12*time.Minute)
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, app))
+	assert.Equal(t, &appsv1alpha1.GitTrigger{
+		Source:    appsv1alpha1.TriggerSourcePoll,
+		CommitSHA: newSHA,
+		Time:      metav1.NewTime(now),
+	}, app.Status.LastTrigger)
+}
+
+func TestPollSchedule(t *testing.T) {
+	r := &GitPollReconciler{}
+	key := types.NamespacedName{Namespace: "default", Name: "hello"}
+	now := time.Unix(1700000000, 0)
+
+	next := r.schedule(key, time.Hour, now)
+	assert.False(t, next.Before(now))
+	assert.True(t, next.Before(now.Add(time.Hour)))
+	assert.Equal(t, next, r.schedule(key, time.Hour, now.Add(time.Minute)), "already scheduled")
+
+	rescheduled := r.schedule(key, time.Minute, now)
+	assert.True(t, rescheduled.Before(now.Add(time.Minute)), "the interval changed")
+
+	r.unschedule(key)
+	assert.Empty(t, r.polls)
+}
+
+func TestPollInterval(t *testing.T) {
+	app := &appsv1alpha1.ConsoleApplication{}
+	assert.Zero(t, pollInterval(app))
+
+	app.Spec.Git.PollInterval = &metav1.Duration{Duration: time.Second}
+	assert.Equal(t, appsv1alpha1.MinPollInterval, pollInterval(app))
+
+	app.Spec.Git.PollInterval = &metav1.Duration{Duration: 5 * time.Minute}
+	assert.Equal(t, 5*time.Minute, pollInterval(app))
+}
+
+func TestHasPendingTrigger(t *testing.T) {
+	app := &appsv1alpha1.ConsoleApplication{}
+	app.Status.Git.CommitSHA = statusSHA
+	assert.False(t, hasPendingTrigger(app))
+
+	// The trigger of an annotated tag reports the tag object, not the resolved commit
+	trigger := appsv1alpha1.GitTrigger{
+		Source:    appsv1alpha1.TriggerSourceWebhook,
+		CommitSHA: "0123456789abcdef0123456789abcdef01234567",
+		Time:      metav1.NewTime(time.Unix(1700000000, 0)),
+	}
+	app.Status.LastTrigger = trigger.DeepCopy()
+	assert.True(t, hasPendingTrigger(app))
+
+	app.Status.Git.ObservedTrigger = trigger.DeepCopy()
+	assert.False(t, hasPendingTrigger(app), "handled")
+
+	app.Status.LastTrigger.DeliveryID = "delivery-2"
+	assert.True(t, hasPendingTrigger(app), "another trigger within the same second")
+}
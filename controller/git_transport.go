@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// ClusterProxyName is the name of the cluster-wide Proxy object of OpenShift.
+const ClusterProxyName = "cluster"
+
+// proxyGVK is the kind of the cluster-wide proxy, read as an unstructured
+// object to avoid depending on the OpenShift API types.
+var proxyGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "Proxy"}
+
+// gitTransportRequest is the only request of GitTransportReconciler, which
+// always loads the settings of every host.
+var gitTransportRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "git-transport"}}
+
+// GitTransportReconciler loads the CA bundles and client certificates of the
+// Git hosts, and the cluster-wide proxy, into the transports of GitService.
+type GitTransportReconciler struct {
+	// Reader reads the ConfigMaps, Secrets and Proxy. It does not need a cache,
+	// so that the settings can be loaded before the manager starts.
+	Reader client.Reader
+
+	// Namespace is the operator namespace, holding the ConfigMaps and Secrets
+	// referenced by the hosts.
+	Namespace string
+
+	// Hosts are the Git hosts of the operator configuration.
+	Hosts []gitservice.HostConfig
+}
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=config.openshift.io,resources=proxies,verbs=get;list;watch
+
+// Reconcile loads the transport settings again whenever a ConfigMap, Secret
+// or Proxy they are read from changes.
+func (r *GitTransportReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	if err := r.Load(ctx); err != nil {
+		return RequeueOnError(err)
+	}
+	return NoRequeue()
+}
+
+// Load sets the TLS material of every host and the cluster-wide proxy. A host
+// whose material cannot be read keeps its previous settings.
+func (r *GitTransportReconciler) Load(ctx context.Context) error {
+	var errs []error
+	for _, host := range r.Hosts {
+		config, err := r.transportConfig(ctx, host)
+		if err == nil {
+			err = gitservice.SetHostTransport(host.Host, config)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("host %s: %w", host.Host, err))
+		}
+	}
+
+	proxy, err := clusterProxy(ctx, r.Reader)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("cluster proxy: %w", err))
+	} else {
+		gitservice.SetClusterProxy(proxy)
+	}
+	return errors.Join(errs...)
+}
+
+// transportConfig reads the CA bundle and client certificate of host.
+func (r *GitTransportReconciler) transportConfig(
+	ctx context.Context, host gitservice.HostConfig,
+) (gitservice.TransportConfig, error) {
+	config := gitservice.TransportConfig{}
+	if host.CABundle != nil {
+		configMap := &corev1.ConfigMap{}
+		key := client.ObjectKey{Namespace: r.Namespace, Name: host.CABundle.Name}
+		if err := r.Reader.Get(ctx, key, configMap); err != nil {
+			return config, err
+		}
+		if data, ok := configMap.Data[host.CABundle.Key]; ok {
+			config.CABundle = []byte(data)
+		} else {
+			config.CABundle = configMap.BinaryData[host.CABundle.Key]
+		}
+		if len(config.CABundle) == 0 {
+			return config, fmt.Errorf("configmap %q has no %q key", host.CABundle.Name, host.CABundle.Key)
+		}
+	}
+	if host.ClientCertificateSecret != nil {
+		secret := &corev1.Secret{}
+		key := client.ObjectKey{Namespace: r.Namespace, Name: host.ClientCertificateSecret.Name}
+		if err := r.Reader.Get(ctx, key, secret); err != nil {
+			return config, err
+		}
+		config.ClientCertificate = secret.Data[corev1.TLSCertKey]
+		config.ClientKey = secret.Data[corev1.TLSPrivateKeyKey]
+		if len(config.ClientCertificate) == 0 || len(config.ClientKey) == 0 {
+			return config, fmt.Errorf("secret %q must have the %q and %q keys",
+				secret.Name, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+		}
+	}
+	return config, nil
+}
+
+// clusterProxy reads the proxy settings enforced by the cluster-wide Proxy
+// object. Clusters without it, like plain Kubernetes, have no proxy settings.
+func clusterProxy(ctx context.Context, reader client.Reader) (gitservice.ProxyConfig, error) {
+	proxy := &unstructured.Unstructured{}
+	proxy.SetGroupVersionKind(proxyGVK)
+	if err := reader.Get(ctx, client.ObjectKey{Name: ClusterProxyName}, proxy); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return gitservice.ProxyConfig{}, nil
+		}
+		return gitservice.ProxyConfig{}, err
+	}
+	// The status holds the settings in effect, including the defaults added to noProxy.
+	httpProxy, _, _ := unstructured.NestedString(proxy.Object, "status", "httpProxy")
+	httpsProxy, _, _ := unstructured.NestedString(proxy.Object, "status", "httpsProxy")
+	noProxy, _, _ := unstructured.NestedString(proxy.Object, "status", "noProxy")
+	return gitservice.ProxyConfig{HTTPProxy: httpProxy, HTTPSProxy: httpsProxy, NoProxy: noProxy}, nil
+}
+
+// isReferenced reports whether obj is one of the ConfigMaps, Secrets or Proxy
+// the transport settings are read from.
+func (r *GitTransportReconciler) isReferenced(obj client.Object) bool {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		for _, host := range r.Hosts {
+			if host.CABundle != nil && obj.GetNamespace() == r.Namespace && obj.GetName() == host.CABundle.Name {
+				return true
+			}
+		}
+	case *corev1.Secret:
+		for _, host := range r.Hosts {
+			if host.ClientCertificateSecret != nil && obj.GetNamespace() == r.Namespace &&
+				obj.GetName() == host.ClientCertificateSecret.Name {
+				return true
+			}
+		}
+	default:
+		return obj.GetName() == ClusterProxyName
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager. ConfigMaps are
+// only watched when a host has a CA bundle, and the Proxy on clusters serving
+// its kind.
+func (r *GitTransportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	enqueue := handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{gitTransportRequest}
+	})
+	referenced := builder.WithPredicates(predicate.NewPredicateFuncs(r.isReferenced))
+	// Secrets are already cached for the source secrets of the ConsoleApplications.
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("gittransport").
+		Watches(&corev1.Secret{}, enqueue, referenced)
+	for _, host := range r.Hosts {
+		if host.CABundle != nil {
+			b = b.Watches(&corev1.ConfigMap{}, enqueue, referenced)
+			break
+		}
+	}
+	_, err := mgr.GetRESTMapper().RESTMapping(proxyGVK.GroupKind(), proxyGVK.Version)
+	switch {
+	case err == nil:
+		proxy := &unstructured.Unstructured{}
+		proxy.SetGroupVersionKind(proxyGVK)
+		b = b.Watches(proxy, enqueue, referenced)
+	case meta.IsNoMatchError(err):
+		mgr.GetLogger().Info("The cluster has no Proxy object, using the proxy environment variables")
+	default:
+		return err
+	}
+	return b.Complete(r)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+func TestTransportConfig(t *testing.T) {
+	reader := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "corporate-ca", Namespace: "operators"},
+			Data:       map[string]string{"ca-bundle.crt": "-----BEGIN CERTIFICATE-----"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "gitlab-client", Namespace: "operators"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+		},
+	).Build()
+	r := &GitTransportReconciler{Reader: reader, Namespace: "operators"}
+	host := gitservice.HostConfig{
+		Host: "gitlab.example.com",
+		CABundle: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "corporate-ca"},
+			Key:                  "ca-bundle.crt",
+		},
+		ClientCertificateSecret: &corev1.LocalObjectReference{Name: "gitlab-client"},
+	}
+
+	config, err := r.transportConfig(context.Background(), host)
+	require.NoError(t, err)
+	assert.Equal(t, gitservice.TransportConfig{
+		CABundle:          []byte("-----BEGIN CERTIFICATE-----"),
+		ClientCertificate: []byte("cert"),
+		ClientKey:         []byte("key"),
+	}, config)
+
+	host.CABundle.Key = "ca.crt"
+	_, err = r.transportConfig(context.Background(), host)
+	assert.Error(t, err, "missing key")
+
+	host.CABundle = nil
+	host.ClientCertificateSecret.Name = "missing"
+	_, err = r.transportConfig(context.Background(), host)
+	assert.Error(t, err, "missing secret")
+}
+
+func TestClusterProxy(t *testing.T) {
+	proxy, err := clusterProxy(context.Background(), fake.NewClientBuilder().Build())
+	require.NoError(t, err)
+	assert.Equal(t, gitservice.ProxyConfig{}, proxy, "no Proxy object")
+
+	object := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": ClusterProxyName},
+		"spec":     map[string]any{"httpsProxy": "http://proxy.example.com:3128"},
+		"status": map[string]any{
+			"httpProxy":  "http://proxy.example.com:3128",
+			"httpsProxy": "http://proxy.example.com:3128",
+			"noProxy":    ".cluster.local,.svc,10.0.0.0/16",
+		},
+	}}
+	object.SetGroupVersionKind(proxyGVK)
+	proxy, err = clusterProxy(context.Background(), fake.NewClientBuilder().WithObjects(object).Build())
+	require.NoError(t, err)
+	assert.Equal(t, gitservice.ProxyConfig{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "http://proxy.example.com:3128",
+		NoProxy:    ".cluster.local,.svc,10.0.0.0/16",
+	}, proxy)
+}
+
+func TestGitTransportIsReferenced(t *testing.T) {
+	r := &GitTransportReconciler{Namespace: "operators", Hosts: []gitservice.HostConfig{{
+		Host:                    "gitlab.example.com",
+		ClientCertificateSecret: &corev1.LocalObjectReference{Name: "gitlab-client"},
+	}}}
+	secret := func(namespace, name string) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+	assert.True(t, r.isReferenced(secret("operators", "gitlab-client")))
+	assert.False(t, r.isReferenced(secret("default", "gitlab-client")))
+	assert.False(t, r.isReferenced(secret("operators", "other")))
+	assert.False(t, r.isReferenced(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "gitlab-client"}}))
+}
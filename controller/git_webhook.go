@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+	gitwebhook "github.com/openshift-console/console-application-operator/pkg/git-webhook"
+)
+
+// GitWebhookReconciler registers the webhooks of the ConsoleApplications
+// opting in with spec.git.registerWebhook on their Git provider, and deletes
+// them through a finalizer.
+type GitWebhookReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// URL is the public URL of the webhook receiver of the operator. Webhooks
+	// are not registered without it.
+	URL string
+}
+
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch
+
+// Reconcile creates, updates or deletes the webhook of the ConsoleApplication.
+func (r *GitWebhookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	consoleApplication := &appsv1alpha1.ConsoleApplication{}
+	if err := r.Get(ctx, req.NamespacedName, consoleApplication); err != nil {
+		if apierrors.IsNotFound(err) {
+			return NoRequeue()
+		}
+		return RequeueOnError(err)
+	}
+	original := consoleApplication.DeepCopy()
+
+	if !consoleApplication.Spec.Git.RegisterWebhook || !consoleApplication.DeletionTimestamp.IsZero() {
+		if consoleApplication.Status.Webhook != nil {
+			if result, err := r.deleteWebhook(ctx, consoleApplication); err != nil || !result.IsZero() {
+				return result, err
+			}
+			consoleApplication.Status.Webhook = nil
+			meta.RemoveStatusCondition(&consoleApplication.Status.Conditions,
+				appsv1alpha1.ConditionWebhookRegistered.String())
+			if err := r.patchStatus(ctx, consoleApplication, original); err != nil {
+				return RequeueOnError(err)
+			}
+		}
+		if controllerutil.RemoveFinalizer(consoleApplication, appsv1alpha1.WebhookFinalizer) {
+			if err := r.Update(ctx, consoleApplication); err != nil {
+				return RequeueOnError(err)
+			}
+		}
+		return NoRequeue()
+	}
+
+	if r.URL == "" {
+		SetWebhookCondition(consoleApplication, metav1.ConditionFalse,
+			appsv1alpha1.ReasonWebhookURLNotConfigured.String(),
+			"The operator has no public webhook URL: set its --git-webhook-url flag")
+		return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+	}
+	if controllerutil.AddFinalizer(consoleApplication, appsv1alpha1.WebhookFinalizer) {
+		// Adding the finalizer before the webhook exists, so that it cannot leak
+		if err := r.Update(ctx, consoleApplication); err != nil {
+			return RequeueOnError(err)
+		}
+		original = consoleApplication.DeepCopy()
+	}
+
+	secret, err := r.webhookSecret(ctx, consoleApplication)
+	if err != nil {
+		SetWebhookCondition(consoleApplication, metav1.ConditionFalse,
+			appsv1alpha1.ReasonSecretResourceNotFound.String(), err.Error())
+		return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+	}
+	hook := gitservice.Webhook{URL: r.hookURL(consoleApplication), Secret: secret}
+	configHash := webhookConfigHash(hook)
+
+	status := consoleApplication.Status.Webhook
+	if status != nil && status.GitURL == consoleApplication.Spec.Git.Url && status.ConfigHash == configHash &&
+		status.ObservedGeneration == consoleApplication.Generation {
+		// The webhook is in sync with the spec
+		return NoRequeue()
+	}
+	if status != nil && status.GitURL != consoleApplication.Spec.Git.Url {
+		// The repository changed: deleting the webhook of the previous one
+		if result, err := r.deleteWebhook(ctx, consoleApplication); err != nil || !result.IsZero() {
+			return result, err
+		}
+		consoleApplication.Status.Webhook, status = nil, nil
+	}
+
+	credentials, err := sourceCredentials(ctx, r.Client, consoleApplication)
+	if err != nil {
+		SetWebhookCondition(consoleApplication, metav1.ConditionFalse,
+			appsv1alpha1.ReasonSecretResourceNotFound.String(), err.Error())
+		return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+	}
+	gs := gitservice.New(consoleApplication.Spec.Git.Url, consoleApplication.Spec.Git.Reference, credentials, logger)
+	id := ""
+	if status != nil {
+		id = status.ID
+	}
+	id, err = gs.SyncWebhook(ctx, id, hook)
+	if err != nil {
+		reason := gitservice.ReasonForError(err)
+		SetWebhookCondition(consoleApplication, metav1.ConditionFalse, reason.String(),
+			gitservice.ErrorMessage(reason, err))
+		if err := r.patchStatus(ctx, consoleApplication, original); err != nil {
+			return RequeueOnError(err)
+		}
+		return retryGitError(err)
+	}
+	logger.Info("Webhook registered on the Git provider", "id", id)
+	consoleApplication.Status.Webhook = &appsv1alpha1.WebhookStatus{
+		ID:                 id,
+		GitURL:             consoleApplication.Spec.Git.Url,
+		ConfigHash:         configHash,
+		ObservedGeneration: consoleApplication.Generation,
+	}
+	SetWebhookCondition(consoleApplication, metav1.ConditionTrue, appsv1alpha1.ReasonWebhookRegistered.String(),
+		"Push events are delivered to "+r.URL)
+	return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+}
+
+// deleteWebhook deletes the webhook of the status from its repository. A
+// webhook that cannot be deleted for good, e.g. because the credentials were
+// revoked, is left behind rather than blocking the ConsoleApplication.
+func (r *GitWebhookReconciler) deleteWebhook(
+	ctx context.Context, consoleApplication *appsv1alpha1.ConsoleApplication,
+) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	webhook := consoleApplication.Status.Webhook
+	credentials, err := sourceCredentials(ctx, r.Client, consoleApplication)
+	if err != nil {
+		logger.Info("Cannot delete the webhook from the Git provider: " + err.Error())
+		return NoRequeue()
+	}
+	gs := gitservice.New(webhook.GitURL, "", credentials, logger)
+	if err := gs.DeleteWebhook(ctx, webhook.ID); err != nil {
+		reason := gitservice.ReasonForError(err)
+		if gitservice.IsTransient(reason) || reason == gitservice.ReasonRateLimitExceeded {
+			return retryGitError(err)
+		}
+		logger.Info("Cannot delete the webhook from the Git provider, leaving it behind",
+			"id", webhook.ID, "reason", reason, "error", err.Error())
+		return NoRequeue()
+	}
+	logger.Info("Webhook deleted from the Git provider", "id", webhook.ID)
+	return NoRequeue()
+}
+
+// webhookSecret returns the secret of the webhook, generating it when the spec
+// does not reference one.
+func (r *GitWebhookReconciler) webhookSecret(
+	ctx context.Context, consoleApplication *appsv1alpha1.ConsoleApplication,
+) (string, error) {
+	name := gitwebhook.SecretName(consoleApplication)
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: consoleApplication.Namespace, Name: name}, secret)
+	switch {
+	case apierrors.IsNotFound(err) && consoleApplication.Spec.Git.WebhookSecretRef == "":
+		value := make([]byte, 32)
+		if _, err := rand.Read(value); err != nil {
+			return "", err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: consoleApplication.Namespace,
+				Labels:    map[string]string{appsv1alpha1.WebhookSecretLabel: consoleApplication.Name},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{appsv1alpha1.WebhookSecretKey: []byte(hex.EncodeToString(value))},
+		}
+		if err := ctrl.SetControllerReference(consoleApplication, secret, r.Scheme); err != nil {
+			return "", err
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return "", err
+		}
+	case err != nil:
+		return "", err
+	case consoleApplication.Spec.Git.WebhookSecretRef == "" && secret.Labels[appsv1alpha1.WebhookSecretLabel] == "":
+		// Labelling a secret generated without the label, so that its changes are watched
+		original := secret.DeepCopy()
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		secret.Labels[appsv1alpha1.WebhookSecretLabel] = consoleApplication.Name
+		if err := r.Patch(ctx, secret, client.MergeFrom(original)); err != nil {
+			return "", err
+		}
+	}
+	value := secret.Data[appsv1alpha1.WebhookSecretKey]
+	if len(value) == 0 {
+		return "", fmt.Errorf("secret %q has no %q key", name, appsv1alpha1.WebhookSecretKey)
+	}
+	return string(value), nil
+}
+
+// hookURL returns the URL the webhook of the ConsoleApplication delivers to.
+// The receiver ignores the query, which only makes the URL unique, as Github
+// rejects two webhooks with the same URL on a repository.
+func (r *GitWebhookReconciler) hookURL(consoleApplication *appsv1alpha1.ConsoleApplication) string {
+	query := url.Values{"consoleapplication": {consoleApplication.Namespace + "/" + consoleApplication.Name}}
+	return r.URL + "?" + query.Encode()
+}
+
+// webhookConfigHash returns the digest of the URL, secret and events of hook.
+func webhookConfigHash(hook gitservice.Webhook) string {
+	digest := sha256.Sum256([]byte(hook.URL + "\n" + hook.Secret + "\n" + gitservice.WebhookEventsVersion))
+	return hex.EncodeToString(digest[:])
+}
+
+// patchStatus patches the status of the ConsoleApplication, failing on a
+// conflict with the status written by ConsoleApplicationReconciler.
+func (r *GitWebhookReconciler) patchStatus(
+	ctx context.Context, consoleApplication, original *appsv1alpha1.ConsoleApplication,
+) error {
+	patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+	return r.Status().Patch(ctx, consoleApplication, patch)
+}
+
+// sourceCredentials returns the credentials of the source secret of the
+// ConsoleApplication, if any.
+func sourceCredentials(
+	ctx context.Context, reader client.Reader, consoleApplication *appsv1alpha1.ConsoleApplication,
+) (gitservice.Credentials, error) {
+	name := consoleApplication.Spec.Git.SourceSecretRef
+	if name == "" {
+		return gitservice.Credentials{}, nil
+	}
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: consoleApplication.Namespace, Name: name}, secret); err != nil {
+		return gitservice.Credentials{}, err
+	}
+	return gitservice.CredentialsFromSecret(secret)
+}
+
+// retryGitError retries a failed Git request when the rate limit resets, or
+// with the backoff of the work queue when the failure is transient.
+func retryGitError(err error) (ctrl.Result, error) {
+	if wait := gitservice.RetryAfter(err, time.Now()); wait > 0 {
+		return RequeueAfter(wait)
+	}
+	if gitservice.IsTransient(gitservice.ReasonForError(err)) {
+		return Requeue()
+	}
+	return NoRequeue()
+}
+
+// SetupWithManager sets up the controller with the Manager. The
+// ConsoleApplications are reconciled again when their webhook secret changes,
+// as long as it has the WebhookSecretLabel: the cache of the manager only
+// holds the labelled Secrets, see main.go.
+func (r *GitWebhookReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gitwebhook").
+		For(&appsv1alpha1.ConsoleApplication{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.applicationsOfSecret)).
+		Complete(r)
+}
+
+// applicationsOfSecret returns the ConsoleApplications registering a webhook
+// with the secret.
+func (r *GitWebhookReconciler) applicationsOfSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	apps := &appsv1alpha1.ConsoleApplicationList{}
+	if err := r.List(ctx, apps, client.InNamespace(secret.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, app := range apps.Items {
+		if app.Spec.Git.RegisterWebhook && gitwebhook.SecretName(&app) == secret.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&app)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// fakeGithubHooks serves the webhooks API of the hello/world repository.
+type fakeGithubHooks struct {
+	mu    sync.Mutex
+	next  int
+	hooks map[string]map[string]any
+}
+
+func (f *fakeGithubHooks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/repos/hello/world/hooks"), "/")
+	hook := map[string]any{}
+	if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+		_ = json.NewDecoder(r.Body).Decode(&hook)
+	}
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		f.next++
+		hook["id"] = f.next
+		f.hooks[strconv.Itoa(f.next)] = hook
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(hook)
+	case f.hooks[id] == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPatch:
+		f.hooks[id] = hook
+		_ = json.NewEncoder(w).Encode(hook)
+	case r.Method == http.MethodDelete:
+		delete(f.hooks, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestGitWebhookReconciler(t *testing.T) {
+	ctx := context.Background()
+	hooks := &fakeGithubHooks{hooks: map[string]map[string]any{}}
+	registerFakeGithub(t, "github.webhook.example.com", hooks)
+
+	c := newFakeClientBuilder(t).
+		WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "github", Namespace: "default"},
+				Type:       corev1.SecretTypeBasicAuth,
+				Data:       map[string][]byte{"password": []byte("s3cr3t")},
+			},
+			&appsv1alpha1.ConsoleApplication{
+				ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default", Generation: 1},
+				Spec: appsv1alpha1.ConsoleApplicationSpec{Git: appsv1alpha1.Git{
+					Url:             "https://github.webhook.example.com/hello/world",
+					SourceSecretRef: "github",
+					RegisterWebhook: true,
+				}},
+			},
+		).
+		Build()
+	r := &GitWebhookReconciler{Client: c, Scheme: c.Scheme(), URL: "https://hooks.example.com/"}
+	key := client.ObjectKey{Namespace: "default", Name: "hello"}
+	reconcile := func() *appsv1alpha1.ConsoleApplication {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+		app := &appsv1alpha1.ConsoleApplication{}
+		if err := c.Get(ctx, key, app); apierrors.IsNotFound(err) {
+			return nil
+		}
+		return app
+	}
+
+	app := reconcile()
+	assert.Contains(t, app.Finalizers, appsv1alpha1.WebhookFinalizer)
+	require.NotNil(t, app.Status.Webhook)
+	assert.Equal(t, "1", app.Status.Webhook.ID)
+	condition := meta.FindStatusCondition(app.Status.Conditions, appsv1alpha1.ConditionWebhookRegistered.String())
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "hello-git-webhook"}, secret))
+	assert.Equal(t, "hello", secret.Labels[appsv1alpha1.WebhookSecretLabel], "the generated secret is watched")
+	config := hooks.hooks["1"]["config"].(map[string]any)
+	assert.Equal(t, string(secret.Data[appsv1alpha1.WebhookSecretKey]), config["secret"])
+	assert.Equal(t, "https://hooks.example.com/?consoleapplication=default%2Fhello", config["url"])
+
+	// A new secret updates the webhook, and a generated secret without label is labelled
+	secret.Data[appsv1alpha1.WebhookSecretKey] = []byte("rotated")
+	secret.Labels = nil
+	require.NoError(t, c.Update(ctx, secret))
+	reconcile()
+	assert.Equal(t, "rotated", hooks.hooks["1"]["config"].(map[string]any)["secret"])
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+	assert.Equal(t, "hello", secret.Labels[appsv1alpha1.WebhookSecretLabel])
+
+	// A webhook registered with the push events only is updated with the new events
+	app = reconcile()
+	hooks.hooks["1"]["events"] = []any{"push"}
+	pushOnly := sha256.Sum256([]byte(config["url"].(string) + "\nrotated"))
+	app.Status.Webhook.ConfigHash = hex.EncodeToString(pushOnly[:])
+	require.NoError(t, c.Status().Update(ctx, app))
+	app = reconcile()
+	assert.Equal(t, []any{"push", "pull_request"}, hooks.hooks["1"]["events"])
+	assert.Equal(t, webhookConfigHash(gitservice.Webhook{URL: config["url"].(string), Secret: "rotated"}),
+		app.Status.Webhook.ConfigHash)
+
+	// The finalizer deletes the webhook
+	require.NoError(t, c.Delete(ctx, app))
+	assert.Nil(t, reconcile())
+	assert.Empty(t, hooks.hooks)
+}
+
+func TestGitWebhookReconcilerWithoutURL(t *testing.T) {
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{Git: appsv1alpha1.Git{
+			Url:             "https://github.com/hello/world",
+			RegisterWebhook: true,
+		}},
+	}
+	c := newFakeClientBuilder(t).WithObjects(app).Build()
+	r := &GitWebhookReconciler{Client: c, Scheme: c.Scheme()}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+	require.NoError(t, err)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(app), app))
+	assert.Empty(t, app.Finalizers)
+	condition := meta.FindStatusCondition(app.Status.Conditions, appsv1alpha1.ConditionWebhookRegistered.String())
+	require.NotNil(t, condition)
+	assert.Equal(t, appsv1alpha1.ReasonWebhookURLNotConfigured.String(), condition.Reason)
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// registerFakeGithub serves api as the API of the Github host, registered for
+// the duration of the test.
+func registerFakeGithub(t *testing.T, host string, api http.Handler) {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	require.NoError(t, gitservice.RegisterHost(gitservice.HostConfig{
+		Host: host, Type: gitservice.Github, APIURL: server.URL + "/api/v3/",
+	}))
+	t.Cleanup(func() { gitservice.UnregisterHost(host) })
+}
+
+// newFakeClientBuilder returns a builder of fake clients knowing the core and
+// ConsoleApplication kinds, and serving the status of the ConsoleApplications.
+func newFakeClientBuilder(t *testing.T) *fake.ClientBuilder {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, appsv1alpha1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&appsv1alpha1.ConsoleApplication{})
+}
+
+// newRouteMapper returns a REST mapper serving the ConsoleApplication and Route kinds.
+func newRouteMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1alpha1.GroupVersion.WithKind("ConsoleApplication"), meta.RESTScopeNamespace)
+	mapper.Add(routeGVK, meta.RESTScopeNamespace)
+	return mapper
+}
@@ -0,0 +1,353 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+	gitwebhook "github.com/openshift-console/console-application-operator/pkg/git-webhook"
+)
+
+// PreviewReconciler creates a preview ConsoleApplication for each open pull
+// request of the ConsoleApplications opting in with spec.preview.enabled. A
+// preview is a copy of the ConsoleApplication building from the head branch
+// of the pull request. Its URL is commented on the pull request once its Route
+// exists, and it is deleted when the pull request is closed or merged.
+type PreviewReconciler struct {
+	client.Client
+}
+
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get
+
+// Reconcile syncs the previews of the ConsoleApplication with the open pull
+// requests targeting its Git reference.
+func (r *PreviewReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	consoleApplication := &appsv1alpha1.ConsoleApplication{}
+	if err := r.Get(ctx, req.NamespacedName, consoleApplication); err != nil {
+		if apierrors.IsNotFound(err) {
+			return NoRequeue()
+		}
+		return RequeueOnError(err)
+	}
+	original := consoleApplication.DeepCopy()
+
+	if !consoleApplication.Spec.Preview.Enabled || !consoleApplication.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(consoleApplication, appsv1alpha1.PreviewFinalizer) &&
+			len(consoleApplication.Status.Previews) == 0 {
+			return NoRequeue()
+		}
+		if err := r.deletePreviews(ctx, consoleApplication, nil); err != nil {
+			return RequeueOnError(err)
+		}
+		consoleApplication.Status.Previews = nil
+		meta.RemoveStatusCondition(&consoleApplication.Status.Conditions,
+			appsv1alpha1.ConditionPreviewsSynced.String())
+		if err := r.patchStatus(ctx, consoleApplication, original); err != nil {
+			return RequeueOnError(err)
+		}
+		if controllerutil.RemoveFinalizer(consoleApplication, appsv1alpha1.PreviewFinalizer) {
+			if err := r.Update(ctx, consoleApplication); err != nil {
+				return RequeueOnError(err)
+			}
+		}
+		return NoRequeue()
+	}
+
+	base := consoleApplication.Status.Git.Reference
+	if base == "" {
+		// The reference is not resolved yet
+		return NoRequeue()
+	}
+	if controllerutil.AddFinalizer(consoleApplication, appsv1alpha1.PreviewFinalizer) {
+		// Adding the finalizer before the first preview exists, so that none can leak
+		if err := r.Update(ctx, consoleApplication); err != nil {
+			return RequeueOnError(err)
+		}
+		original = consoleApplication.DeepCopy()
+	}
+
+	credentials, err := sourceCredentials(ctx, r.Client, consoleApplication)
+	if err != nil {
+		SetPreviewCondition(consoleApplication, metav1.ConditionFalse,
+			appsv1alpha1.ReasonSecretResourceNotFound.String(), err.Error())
+		return RequeueOnError(r.patchStatus(ctx, consoleApplication, original))
+	}
+	gs := gitservice.New(consoleApplication.Spec.Git.Url, consoleApplication.Spec.Git.Reference, credentials, logger)
+	if wait := gs.RateLimitHoldBack(); wait > 0 {
+		logger.Info("Git API quota is low, holding back the previews", "requeueAfter", wait)
+		return RequeueAfter(wait)
+	}
+	pullRequests, err := gs.ListPullRequests(ctx, base)
+	if err != nil {
+		reason := gitservice.ReasonForError(err)
+		SetPreviewCondition(consoleApplication, metav1.ConditionFalse, reason.String(),
+			gitservice.ErrorMessage(reason, err))
+		if err := r.patchStatus(ctx, consoleApplication, original); err != nil {
+			return RequeueOnError(err)
+		}
+		return retryGitError(err)
+	}
+	sort.Slice(pullRequests, func(i, j int) bool { return pullRequests[i].Number < pullRequests[j].Number })
+
+	previous := map[int]appsv1alpha1.PreviewStatus{}
+	for _, preview := range consoleApplication.Status.Previews {
+		previous[preview.Number] = preview
+	}
+	open := map[int]bool{}
+	var previews []appsv1alpha1.PreviewStatus
+	var errs []error
+	for _, pullRequest := range pullRequests {
+		if isFork(consoleApplication, pullRequest) && !consoleApplication.Spec.Preview.AllowForks {
+			logger.V(1).Info("Pull request opened from a fork, skipped", "number", pullRequest.Number,
+				"repository", pullRequest.HeadRepoURL)
+			continue
+		}
+		open[pullRequest.Number] = true
+		preview, err := r.syncPreview(ctx, consoleApplication, pullRequest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pull request %d: %w", pullRequest.Number, err))
+			if status, ok := previous[pullRequest.Number]; ok {
+				previews = append(previews, status)
+			}
+			continue
+		}
+		status := appsv1alpha1.PreviewStatus{
+			Number:       pullRequest.Number,
+			URL:          pullRequest.URL,
+			Name:         preview.Name,
+			HeadRef:      pullRequest.HeadRef,
+			HeadSHA:      pullRequest.HeadSHA,
+			CommentedURL: previous[pullRequest.Number].CommentedURL,
+		}
+		if url := applicationURL(ctx, r.Client, preview); url != "" && url != status.CommentedURL {
+			if err := gs.CommentPullRequest(ctx, pullRequest.Number, previewComment(preview, url)); err != nil {
+				errs = append(errs, fmt.Errorf("pull request %d: %w", pullRequest.Number, err))
+			} else {
+				logger.Info("Preview URL commented on the pull request", "number", pullRequest.Number, "url", url)
+				status.CommentedURL = url
+			}
+		}
+		previews = append(previews, status)
+	}
+	if err := r.deletePreviews(ctx, consoleApplication, open); err != nil {
+		errs = append(errs, err)
+	}
+
+	consoleApplication.Status.Previews = previews
+	if err := errors.Join(errs...); err != nil {
+		SetPreviewCondition(consoleApplication, metav1.ConditionFalse, appsv1alpha1.ReasonReconcileFailed.String(),
+			err.Error())
+	} else {
+		SetPreviewCondition(consoleApplication, metav1.ConditionTrue, appsv1alpha1.ReasonPreviewsSynced.String(),
+			fmt.Sprintf("%d preview(s) of the pull requests targeting %s", len(previews), base))
+	}
+	if err := r.patchStatus(ctx, consoleApplication, original); err != nil {
+		return RequeueOnError(err)
+	}
+	// Listing the pull requests again for the events that were not delivered
+	return RequeueAfter(previewResync(consoleApplication))
+}
+
+// syncPreview creates or updates the preview of the pull request, and records
+// its head commit in the preview so that it is reconciled from it.
+func (r *PreviewReconciler) syncPreview(
+	ctx context.Context, consoleApplication *appsv1alpha1.ConsoleApplication, pullRequest gitservice.PullRequest,
+) (*appsv1alpha1.ConsoleApplication, error) {
+	logger := log.FromContext(ctx)
+	desired := newPreview(consoleApplication, pullRequest)
+	preview := &appsv1alpha1.ConsoleApplication{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), preview)
+	if apierrors.IsNotFound(err) {
+		if err := r.Create(ctx, desired); err != nil {
+			return nil, err
+		}
+		logger.Info("Preview created", "number", pullRequest.Number, "preview", client.ObjectKeyFromObject(desired))
+		return desired, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isPreviewOf(preview, consoleApplication) {
+		return nil, fmt.Errorf("ConsoleApplication %s already exists and is not a preview",
+			client.ObjectKeyFromObject(preview))
+	}
+	if !equality.Semantic.DeepEqual(preview.Spec, desired.Spec) ||
+		!equality.Semantic.DeepEqual(preview.Labels, desired.Labels) {
+		preview.Spec = desired.Spec
+		preview.Labels = desired.Labels
+		if err := r.Update(ctx, preview); err != nil {
+			return nil, err
+		}
+	}
+
+	// Bitbucket Cloud only lists abbreviated hashes, which the preview finds by itself
+	sha := pullRequest.HeadSHA
+	trigger := preview.Status.LastTrigger
+	if len(sha) == 40 && preview.Status.Git.CommitSHA != "" && sha != preview.Status.Git.CommitSHA &&
+		(trigger == nil || trigger.CommitSHA != sha) {
+		original := preview.DeepCopy()
+		preview.Status.LastTrigger = &appsv1alpha1.GitTrigger{
+			Source:    appsv1alpha1.TriggerSourcePullRequest,
+			CommitSHA: sha,
+			Time:      metav1.NewTime(time.Now()),
+		}
+		if err := r.Status().Patch(ctx, preview, client.MergeFrom(original)); err != nil {
+			return nil, err
+		}
+		logger.Info("New commit of the pull request: "+sha, "number", pullRequest.Number)
+	}
+	return preview, nil
+}
+
+// deletePreviews deletes the previews of the ConsoleApplication, but the ones
+// of the open pull requests.
+func (r *PreviewReconciler) deletePreviews(
+	ctx context.Context, consoleApplication *appsv1alpha1.ConsoleApplication, open map[int]bool,
+) error {
+	previews := &appsv1alpha1.ConsoleApplicationList{}
+	if err := r.List(ctx, previews, client.InNamespace(consoleApplication.Namespace),
+		client.MatchingLabels{appsv1alpha1.PreviewOfLabel: consoleApplication.Name}); err != nil {
+		return err
+	}
+	var errs []error
+	for i := range previews.Items {
+		preview := &previews.Items[i]
+		number, err := strconv.Atoi(preview.Labels[appsv1alpha1.PullRequestLabel])
+		if err == nil && open[number] {
+			continue
+		}
+		if err := r.Delete(ctx, preview); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+		log.FromContext(ctx).Info("Preview deleted", "number", number, "preview", client.ObjectKeyFromObject(preview))
+	}
+	return errors.Join(errs...)
+}
+
+// newPreview returns the preview of the pull request: a copy of the
+// ConsoleApplication building from the head branch, which neither registers
+// webhooks, records deployments nor creates previews itself. The previews of
+// forks do not get the secrets of the ConsoleApplication.
+func newPreview(
+	consoleApplication *appsv1alpha1.ConsoleApplication, pullRequest gitservice.PullRequest,
+) *appsv1alpha1.ConsoleApplication {
+	spec := consoleApplication.Spec.DeepCopy()
+	spec.Git.Url = pullRequest.HeadRepoURL
+	spec.Git.Reference = pullRequest.HeadRef
+	spec.Git.RegisterWebhook = false
+	spec.Git.ReportDeployment = false
+	// Sharing the webhook of the repository, which delivers the pushes to the head branch
+	spec.Git.WebhookSecretRef = gitwebhook.SecretName(consoleApplication)
+	if isFork(consoleApplication, pullRequest) {
+		// The fork is public or the preview cannot read it: the credentials of
+		// the team are not lent to the code of the fork author.
+		spec.Git.SourceSecretRef = ""
+		spec.Git.WebhookSecretRef = ""
+		spec.Git.ReportCommitStatus = false
+	}
+	spec.Preview = appsv1alpha1.Preview{}
+	return &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-pr-%d", consoleApplication.Name, pullRequest.Number),
+			Namespace: consoleApplication.Namespace,
+			Labels: map[string]string{
+				appsv1alpha1.PreviewOfLabel:   consoleApplication.Name,
+				appsv1alpha1.PullRequestLabel: strconv.Itoa(pullRequest.Number),
+			},
+		},
+		Spec: *spec,
+	}
+}
+
+// isFork reports whether the pull request is opened from another repository
+// than the one of the ConsoleApplication. Unparsable URLs are forks.
+func isFork(consoleApplication *appsv1alpha1.ConsoleApplication, pullRequest gitservice.PullRequest) bool {
+	head, err := gitservice.RepositoryKey(pullRequest.HeadRepoURL)
+	if err != nil {
+		return true
+	}
+	base, err := gitservice.RepositoryKey(consoleApplication.Spec.Git.Url)
+	return err != nil || head != base
+}
+
+// isPreviewOf reports whether preview was created for the ConsoleApplication.
+func isPreviewOf(preview, consoleApplication *appsv1alpha1.ConsoleApplication) bool {
+	return preview.Namespace == consoleApplication.Namespace &&
+		preview.Labels[appsv1alpha1.PreviewOfLabel] == consoleApplication.Name
+}
+
+// previewComment returns the comment announcing the URL of the preview.
+func previewComment(preview *appsv1alpha1.ConsoleApplication, url string) string {
+	return fmt.Sprintf("The preview of this pull request is available at %s\n\n"+
+		"It is served by the ConsoleApplication %s/%s, deleted when the pull request is closed or merged.",
+		url, preview.Namespace, preview.Name)
+}
+
+// previewResync returns how often the pull requests of the ConsoleApplication
+// are listed again: its poll interval, else DefaultPreviewResync.
+func previewResync(consoleApplication *appsv1alpha1.ConsoleApplication) time.Duration {
+	if interval := pollInterval(consoleApplication); interval > 0 {
+		return interval
+	}
+	return appsv1alpha1.DefaultPreviewResync
+}
+
+// patchStatus patches the status of the ConsoleApplication, failing on a
+// conflict with the status written by ConsoleApplicationReconciler.
+func (r *PreviewReconciler) patchStatus(
+	ctx context.Context, consoleApplication, original *appsv1alpha1.ConsoleApplication,
+) error {
+	patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+	return r.Status().Patch(ctx, consoleApplication, patch)
+}
+
+// previewTriggerChanged passes the updates resolving the Git reference to
+// another branch or recording a pull request event.
+var previewTriggerChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldApp, ok := e.ObjectOld.(*appsv1alpha1.ConsoleApplication)
+		if !ok {
+			return false
+		}
+		newApp, ok := e.ObjectNew.(*appsv1alpha1.ConsoleApplication)
+		if !ok {
+			return false
+		}
+		return oldApp.Status.Git.Reference != newApp.Status.Git.Reference ||
+			!equality.Semantic.DeepEqual(oldApp.Status.LastPullRequestEvent, newApp.Status.LastPullRequestEvent)
+	},
+}
+
+// SetupWithManager sets up the controller with the Manager. The previews are
+// synced again when the spec changes, the Git reference is resolved or a pull
+// request event is recorded, and periodically.
+func (r *PreviewReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("preview").
+		For(&appsv1alpha1.ConsoleApplication{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, previewTriggerChanged))).
+		Complete(r)
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// fakeGithubPullRequests serves the pull requests API of the hello/world repository.
+type fakeGithubPullRequests struct {
+	mu    sync.Mutex
+	pulls []map[string]any
+	// comments are the comments posted on pull request 1.
+	comments []string
+}
+
+func (f *fakeGithubPullRequests) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/repos/hello/world/pulls":
+		_ = json.NewEncoder(w).Encode(f.pulls)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v3/repos/hello/world/issues/1/comments":
+		body := map[string]any{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.comments = append(f.comments, body["body"].(string))
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(body)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPreviewReconciler(t *testing.T) {
+	const headSHA = "89abcdef0123456789abcdef0123456789abcdef"
+	pullRequests := &fakeGithubPullRequests{pulls: []map[string]any{{
+		"number": 1, "html_url": "https://github.preview.example.com/hello/world/pull/1",
+		"head": map[string]any{"ref": "feature", "sha": statusSHA,
+			"repo": map[string]any{"clone_url": "https://github.preview.example.com/hello/world.git"}},
+	}, {
+		"number": 2, "html_url": "https://github.preview.example.com/hello/world/pull/2",
+		"head": map[string]any{"ref": "main", "sha": statusSHA,
+			"repo": map[string]any{"clone_url": "https://github.preview.example.com/fork/world.git"}},
+	}}}
+	registerFakeGithub(t, "github.preview.example.com", pullRequests)
+
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "hello-dev"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{
+			Git: appsv1alpha1.Git{
+				Url:              "https://github.preview.example.com/hello/world",
+				RegisterWebhook:  true,
+				ReportDeployment: true,
+			},
+			Preview: appsv1alpha1.Preview{Enabled: true},
+		},
+		Status: appsv1alpha1.ConsoleApplicationStatus{
+			Git: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: statusSHA},
+		},
+	}
+	c := newFakeClientBuilder(t).WithRESTMapper(newRouteMapper()).WithObjects(app).Build()
+	r := &PreviewReconciler{Client: c}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+	previewKey := client.ObjectKey{Namespace: "hello-dev", Name: "hello-pr-1"}
+	ctx := context.Background()
+
+	result, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, appsv1alpha1.DefaultPreviewResync, result.RequeueAfter)
+	preview := &appsv1alpha1.ConsoleApplication{}
+	require.NoError(t, c.Get(ctx, previewKey, preview))
+	assert.Equal(t, map[string]string{
+		appsv1alpha1.PreviewOfLabel:   "hello",
+		appsv1alpha1.PullRequestLabel: "1",
+	}, preview.Labels)
+	assert.Equal(t, appsv1alpha1.Git{
+		Url:              "https://github.preview.example.com/hello/world.git",
+		Reference:        "feature",
+		WebhookSecretRef: "hello-git-webhook",
+	}, preview.Spec.Git)
+	assert.False(t, preview.Spec.Preview.Enabled)
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, client.ObjectKey{Namespace: "hello-dev", Name: "hello-pr-2"},
+		&appsv1alpha1.ConsoleApplication{})), "the fork is not allowed")
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	assert.True(t, controllerutil.ContainsFinalizer(app, appsv1alpha1.PreviewFinalizer))
+	assert.Equal(t, []appsv1alpha1.PreviewStatus{{
+		Number:  1,
+		URL:     "https://github.preview.example.com/hello/world/pull/1",
+		Name:    "hello-pr-1",
+		HeadRef: "feature",
+		HeadSHA: statusSHA,
+	}}, app.Status.Previews)
+	assert.True(t, meta.IsStatusConditionTrue(app.Status.Conditions, appsv1alpha1.ConditionPreviewsSynced.String()))
+	assert.Empty(t, pullRequests.comments, "no Route yet")
+
+	// The preview has a Route, and the pull request a new commit
+	route := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "hello-pr-1", "namespace": "hello-dev"},
+		"spec":     map[string]any{"host": "hello-pr-1.apps.example.com"},
+	}}
+	route.SetGroupVersionKind(routeGVK)
+	require.NoError(t, c.Create(ctx, route))
+	preview.Status.Git = appsv1alpha1.GitStatus{Reference: "feature", ReferenceType: "Branch", CommitSHA: statusSHA}
+	require.NoError(t, c.Status().Update(ctx, preview))
+	pullRequests.pulls[0]["head"].(map[string]any)["sha"] = headSHA
+	for range 2 {
+		_, err = r.Reconcile(ctx, req)
+		require.NoError(t, err)
+	}
+	require.Len(t, pullRequests.comments, 1, "commented once")
+	assert.Contains(t, pullRequests.comments[0], "http://hello-pr-1.apps.example.com")
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	assert.Equal(t, "http://hello-pr-1.apps.example.com", app.Status.Previews[0].CommentedURL)
+	require.NoError(t, c.Get(ctx, previewKey, preview))
+	require.NotNil(t, preview.Status.LastTrigger)
+	assert.Equal(t, appsv1alpha1.TriggerSourcePullRequest, preview.Status.LastTrigger.Source)
+	assert.Equal(t, headSHA, preview.Status.LastTrigger.CommitSHA)
+
+	// The pull request is merged
+	pullRequests.pulls = nil
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, previewKey, preview)))
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	assert.Empty(t, app.Status.Previews)
+
+	// The previews are disabled
+	app.Spec.Preview.Enabled = false
+	require.NoError(t, c.Update(ctx, app))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, req.NamespacedName, app))
+	assert.False(t, controllerutil.ContainsFinalizer(app, appsv1alpha1.PreviewFinalizer))
+	assert.Nil(t, meta.FindStatusCondition(app.Status.Conditions, appsv1alpha1.ConditionPreviewsSynced.String()))
+}
+
+func TestNewPreviewOfFork(t *testing.T) {
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "hello-dev"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{
+			Git: appsv1alpha1.Git{
+				Url:                "https://github.com/hello/world",
+				SourceSecretRef:    "github",
+				RegisterWebhook:    true,
+				ReportCommitStatus: true,
+			},
+			Preview: appsv1alpha1.Preview{Enabled: true, AllowForks: true},
+		},
+	}
+	preview := newPreview(app, gitservice.PullRequest{
+		Number: 7, HeadRepoURL: "https://github.com/Hello/World.git", HeadRef: "feature",
+	})
+	assert.Equal(t, appsv1alpha1.Git{
+		Url:                "https://github.com/Hello/World.git",
+		Reference:          "feature",
+		SourceSecretRef:    "github",
+		WebhookSecretRef:   "hello-git-webhook",
+		ReportCommitStatus: true,
+	}, preview.Spec.Git, "same repository")
+
+	preview = newPreview(app, gitservice.PullRequest{
+		Number: 8, HeadRepoURL: "https://github.com/fork/world.git", HeadRef: "feature",
+	})
+	assert.Equal(t, appsv1alpha1.Git{
+		Url:       "https://github.com/fork/world.git",
+		Reference: "feature",
+	}, preview.Spec.Git, "fork")
+}
+
+func TestPreviewReconcilerNotAPreview(t *testing.T) {
+	app := &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "hello-dev"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{
+			Git:     appsv1alpha1.Git{Url: "https://github.com/hello/world", RegisterWebhook: true},
+			Preview: appsv1alpha1.Preview{Enabled: true},
+		},
+	}
+	preview := newPreview(app, gitservice.PullRequest{
+		Number: 7, HeadRepoURL: "https://github.com/hello/world.git", HeadRef: "feature",
+	})
+	assert.Equal(t, client.ObjectKey{Namespace: "hello-dev", Name: "hello-pr-7"}, client.ObjectKeyFromObject(preview))
+	assert.True(t, isPreviewOf(preview, app))
+
+	other := &appsv1alpha1.ConsoleApplication{ObjectMeta: metav1.ObjectMeta{Name: "hello-pr-7", Namespace: "hello-dev"}}
+	c := newFakeClientBuilder(t).WithObjects(other).Build()
+	r := &PreviewReconciler{Client: c}
+	_, err := r.syncPreview(context.Background(), app, gitservice.PullRequest{Number: 7, HeadRef: "feature"})
+	assert.ErrorContains(t, err, "is not a preview")
+}
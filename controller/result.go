@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"time"
+
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -9,6 +11,11 @@ func Requeue() (ctrl.Result, error) {
 	return ctrl.Result{Requeue: true}, nil
 }
 
+// RequeueAfter triggers a object requeue after the given delay.
+func RequeueAfter(after time.Duration) (ctrl.Result, error) {
+	return ctrl.Result{RequeueAfter: after}, nil
+}
+
 // RequeueOnError triggers requeue when error is not nil.
 func RequeueOnError(err error) (ctrl.Result, error) {
 	return ctrl.Result{}, err
@@ -23,14 +23,49 @@ func SetDegraded(consoleApplication *appsv1alpha1.ConsoleApplication, reason, me
 	})
 }
 
-// SetGitServiceCondition sets the GitService condition with the provided status and reason.
-func SetGitServiceCondition(consoleApplication *appsv1alpha1.ConsoleApplication, status metav1.ConditionStatus, reason string) {
+// SetGitServiceCondition sets the GitService condition with the provided status and reason. The
+// message, when not empty, explains a failure and how to fix it.
+func SetGitServiceCondition(
+	consoleApplication *appsv1alpha1.ConsoleApplication, status metav1.ConditionStatus, reason, message string,
+) {
+	conditionMessage := fmt.Sprintf("Git Repository Reachable: %s", string(status))
+	if message != "" {
+		conditionMessage += ": " + message
+	}
 	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
 		Type:               appsv1alpha1.ConditionGitRepoReachable.String(),
 		Status:             status,
+		ObservedGeneration: consoleApplication.Generation,
 		Reason:             reason,
 		LastTransitionTime: metav1.NewTime(time.Now()),
-		Message:            fmt.Sprintf("Git Repository Reachable: %s", string(status)),
+		Message:            conditionMessage,
+	})
+}
+
+// SetContextDirCondition sets the ContextDirFound condition with the provided status, reason and message.
+func SetContextDirCondition(
+	consoleApplication *appsv1alpha1.ConsoleApplication, status metav1.ConditionStatus, reason, message string,
+) {
+	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionContextDirFound.String(),
+		Status:             status,
+		Reason:             reason,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
+	})
+}
+
+// SetWebhookCondition sets the WebhookRegistered condition with the provided status, reason and message.
+func SetWebhookCondition(
+	consoleApplication *appsv1alpha1.ConsoleApplication, status metav1.ConditionStatus, reason, message string,
+) {
+	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionWebhookRegistered.String(),
+		Status:             status,
+		ObservedGeneration: consoleApplication.Generation,
+		Reason:             reason,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
 	})
 }
 
@@ -45,6 +80,59 @@ func SetStarted(consoleApplication *appsv1alpha1.ConsoleApplication) {
 	})
 }
 
+// SetReconciling sets the Operator Ready condition to Unknown while the commit is reconciled.
+func SetReconciling(consoleApplication *appsv1alpha1.ConsoleApplication, commitSHA string) {
+	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionReady.String(),
+		Status:             metav1.ConditionUnknown,
+		Reason:             appsv1alpha1.ReasonReconciling.String(),
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            "Reconciling commit " + commitSHA,
+	})
+}
+
+// SetCommitStatusCondition sets the CommitStatusReported condition with the provided status, reason and message.
+func SetCommitStatusCondition(
+	consoleApplication *appsv1alpha1.ConsoleApplication, status metav1.ConditionStatus, reason, message string,
+) {
+	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionCommitStatusReported.String(),
+		Status:             status,
+		ObservedGeneration: consoleApplication.Generation,
+		Reason:             reason,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
+	})
+}
+
+// SetDeploymentCondition sets the DeploymentReported condition with the provided status, reason and message.
+func SetDeploymentCondition(
+	consoleApplication *appsv1alpha1.ConsoleApplication, status metav1.ConditionStatus, reason, message string,
+) {
+	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionDeploymentReported.String(),
+		Status:             status,
+		ObservedGeneration: consoleApplication.Generation,
+		Reason:             reason,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
+	})
+}
+
+// SetPreviewCondition sets the PreviewsSynced condition with the provided status, reason and message.
+func SetPreviewCondition(
+	consoleApplication *appsv1alpha1.ConsoleApplication, status metav1.ConditionStatus, reason, message string,
+) {
+	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionPreviewsSynced.String(),
+		Status:             status,
+		ObservedGeneration: consoleApplication.Generation,
+		Reason:             reason,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
+	})
+}
+
 // SetFailed sets the Operator Progressing and Application Ready conditions to False with the provided reason and message.
 func SetFailed(consoleApplication *appsv1alpha1.ConsoleApplication, reason, message string) {
 	meta.SetStatusCondition(&consoleApplication.Status.Conditions, metav1.Condition{
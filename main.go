@@ -17,18 +17,25 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -36,6 +43,8 @@ import (
 
 	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
 	"github.com/openshift-console/console-application-operator/controller"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+	gitwebhook "github.com/openshift-console/console-application-operator/pkg/git-webhook"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -57,6 +66,13 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var gitConfigPath string
+	var enableWebhooks bool
+	var gitRateLimitReserve int
+	var gitProbeCacheTTL time.Duration
+	var operatorNamespace string
+	var gitWebhookAddr string
+	var gitWebhookURL string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -66,6 +82,24 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&gitConfigPath, "git-config", "",
+		"Path to a file mapping self-hosted Git hosts to their provider type and API URL.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"If set, the mutating webhook defaulting the Git reference to the default branch is served")
+	flag.IntVar(&gitRateLimitReserve, "git-rate-limit-reserve", gitservice.DefaultRateLimitReserve,
+		"Percentage of the API quota of each Git host kept for new and failing ConsoleApplications. "+
+			"0 disables holding back the other probes.")
+	flag.DurationVar(&gitProbeCacheTTL, "git-probe-cache-ttl", gitservice.DefaultProbeCacheTTL,
+		"Duration the result of a Git repository probe is shared by the ConsoleApplications using the same "+
+			"repository, reference and credentials. 0 disables the cache.")
+	flag.StringVar(&operatorNamespace, "operator-namespace", os.Getenv("POD_NAMESPACE"),
+		"Namespace of the ConfigMaps and Secrets holding the CA bundles and client certificates of the Git hosts. "+
+			"Defaults to the POD_NAMESPACE environment variable.")
+	flag.StringVar(&gitWebhookAddr, "git-webhook-bind-address", ":8082",
+		"The address the Git push webhook endpoint binds to. Set this to '0' to disable it.")
+	flag.StringVar(&gitWebhookURL, "git-webhook-url", "",
+		"Public URL of the Git push webhook endpoint, registered on the Git providers for the ConsoleApplications "+
+			"setting spec.git.registerWebhook.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -74,6 +108,22 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	gitservice.SetRateLimitReserve(gitRateLimitReserve)
+	gitservice.SetProbeCacheTTL(gitProbeCacheTTL)
+	gitConfig := &gitservice.Config{}
+	if gitConfigPath != "" {
+		var err error
+		gitConfig, err = gitservice.LoadConfig(gitConfigPath)
+		if err != nil {
+			setupLog.Error(err, "unable to load Git configuration")
+			os.Exit(1)
+		}
+		if err := gitConfig.Register(); err != nil {
+			setupLog.Error(err, "unable to register Git hosts")
+			os.Exit(1)
+		}
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -94,8 +144,33 @@ func main() {
 		TLSOpts: tlsOpts,
 	})
 
+	// Secrets are read from the API server rather than cached, and only the
+	// webhook secrets are watched outside of the operator namespace, so that the
+	// operator does not list and watch every Secret of the cluster.
+	webhookSecrets, err := labels.NewRequirement(appsv1alpha1.WebhookSecretLabel, selection.Exists, nil)
+	if err != nil {
+		setupLog.Error(err, "unable to select the webhook secrets")
+		os.Exit(1)
+	}
+	secretNamespaces := map[string]cache.Config{
+		cache.AllNamespaces: {LabelSelector: labels.NewSelector().Add(*webhookSecrets)},
+	}
+	cacheOptions := cache.Options{ByObject: map[client.Object]cache.ByObject{
+		&corev1.Secret{}: {Namespaces: secretNamespaces},
+	}}
+	if operatorNamespace != "" {
+		// Only the ConfigMaps of the operator namespace are read.
+		cacheOptions.ByObject[&corev1.ConfigMap{}] = cache.ByObject{
+			Namespaces: map[string]cache.Config{operatorNamespace: {}},
+		}
+		// The client certificates of the Git hosts are watched there.
+		secretNamespaces[operatorNamespace] = cache.Config{}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache:  cacheOptions,
+		Client: client.Options{Cache: &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}}}},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -122,6 +197,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	gitTransports := &controller.GitTransportReconciler{
+		Reader:    mgr.GetAPIReader(),
+		Namespace: operatorNamespace,
+		Hosts:     gitConfig.Hosts,
+	}
+	// Loading the transport settings before the first Git request
+	if err := gitTransports.Load(context.Background()); err != nil {
+		setupLog.Error(err, "unable to load Git transport settings")
+	}
+	if err = gitTransports.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GitTransport")
+		os.Exit(1)
+	}
 	if err = (&controller.ConsoleApplicationReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
@@ -129,6 +217,50 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "ConsoleApplication")
 		os.Exit(1)
 	}
+	if err = (&controller.GitWebhookReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		URL:    gitWebhookURL,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GitWebhook")
+		os.Exit(1)
+	}
+	if err = (&controller.GitPollReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GitPoll")
+		os.Exit(1)
+	}
+	if err = (&controller.CommitStatusReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CommitStatus")
+		os.Exit(1)
+	}
+	if err = (&controller.GitDeploymentReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GitDeployment")
+		os.Exit(1)
+	}
+	if err = (&controller.PreviewReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Preview")
+		os.Exit(1)
+	}
+	if gitWebhookAddr != "0" {
+		if err = gitwebhook.NewReceiver(mgr.GetClient(), mgr.GetAPIReader(), gitWebhookAddr).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create Git webhook receiver")
+			os.Exit(1)
+		}
+	}
+	if enableWebhooks {
+		if err = (&controller.ConsoleApplicationDefaulter{Reader: mgr.GetAPIReader()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ConsoleApplication")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,114 @@
+// Package detection suggests how to build a repository from the files it
+// contains, like the import form of the OpenShift console does.
+package detection
+
+import (
+	"path"
+	"sort"
+)
+
+// Strategy is an import strategy of a ConsoleApplication.
+type Strategy string
+
+const (
+	// StrategyDevfile builds and runs the application described by a devfile
+	StrategyDevfile Strategy = "devfile"
+
+	// StrategyDockerfile builds the Dockerfile or Containerfile of the repository
+	StrategyDockerfile Strategy = "dockerfile"
+
+	// StrategyBuilderImage builds the sources with a Source-to-Image builder image
+	StrategyBuilderImage Strategy = "builder-image"
+)
+
+func (s Strategy) String() string {
+	return string(s)
+}
+
+// Candidate is an import strategy matching the files of a repository.
+type Candidate struct {
+	// Strategy is the import strategy.
+	Strategy Strategy
+
+	// BuilderImage is the name of the builder ImageStream, e.g. "nodejs". It is
+	// only set for StrategyBuilderImage.
+	BuilderImage string
+
+	// Files are the files that matched, in the order they were listed.
+	Files []string
+
+	// Score ranks the candidates, the highest being the best match.
+	Score int
+}
+
+// rule associates file name patterns with a strategy and builder image.
+type rule struct {
+	strategy     Strategy
+	builderImage string
+	// patterns are matched against file names with path.Match.
+	patterns []string
+}
+
+// Each strategy outweighs any number of matching files of the next one: a
+// devfile or Dockerfile describes the build explicitly, while builder images
+// are guessed from the language of the sources.
+var strategyScores = map[Strategy]int{
+	StrategyDevfile:      3000,
+	StrategyDockerfile:   2000,
+	StrategyBuilderImage: 1000,
+}
+
+// rules are listed by decreasing priority, which breaks ties between
+// candidates matching as many files.
+var rules = []rule{
+	{StrategyDevfile, "", []string{"devfile.yaml", ".devfile.yaml", "devfile.yml", ".devfile.yml"}},
+	{StrategyDockerfile, "", []string{"Dockerfile", "Containerfile"}},
+	{StrategyBuilderImage, "golang", []string{"go.mod", "main.go", "Gopkg.toml", "glide.yaml"}},
+	{StrategyBuilderImage, "java", []string{"pom.xml", "build.gradle", "build.gradle.kts", "gradlew", "mvnw"}},
+	{StrategyBuilderImage, "nodejs", []string{"package.json", "package-lock.json", "yarn.lock", "app.json",
+		"gulpfile.js", ".nvmrc"}},
+	{StrategyBuilderImage, "python", []string{"requirements.txt", "setup.py", "Pipfile", "pyproject.toml",
+		"wsgi.py", "app.py", ".python-version", "runtime.txt"}},
+	{StrategyBuilderImage, "ruby", []string{"Gemfile", "Rakefile", "config.ru"}},
+	{StrategyBuilderImage, "php", []string{"composer.json", "index.php"}},
+	{StrategyBuilderImage, "perl", []string{"cpanfile", "index.pl"}},
+	{StrategyBuilderImage, "dotnet", []string{"*.csproj", "*.fsproj", "*.sln", "global.json"}},
+	{StrategyBuilderImage, "httpd", []string{"index.html"}},
+}
+
+// Detect returns the candidate strategies for a directory holding files, best
+// match first. files are the names of the files directly in the directory.
+func Detect(files []string) []Candidate {
+	var candidates []Candidate
+	for _, r := range rules {
+		matched := r.match(files)
+		if len(matched) == 0 {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Strategy:     r.strategy,
+			BuilderImage: r.builderImage,
+			Files:        matched,
+			Score:        strategyScores[r.strategy] + len(matched),
+		})
+	}
+	// Stable sorting keeps the priority of the rules among equal scores.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// match returns the files matching one of the patterns of the rule.
+func (r rule) match(files []string) []string {
+	var matched []string
+	for _, file := range files {
+		for _, pattern := range r.patterns {
+			if ok, _ := path.Match(pattern, file); ok {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,65 @@
+package detection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []Candidate
+	}{
+		{
+			name:  "Node.js",
+			files: []string{"README.md", "package.json", "package-lock.json", "index.js"},
+			want: []Candidate{
+				{StrategyBuilderImage, "nodejs", []string{"package.json", "package-lock.json"}, 1002},
+			},
+		},
+		{
+			name:  "Dockerfile wins over the sources",
+			files: []string{"go.mod", "main.go", "Dockerfile"},
+			want: []Candidate{
+				{StrategyDockerfile, "", []string{"Dockerfile"}, 2001},
+				{StrategyBuilderImage, "golang", []string{"go.mod", "main.go"}, 1002},
+			},
+		},
+		{
+			name:  "Devfile",
+			files: []string{"devfile.yaml", "Containerfile", "pom.xml"},
+			want: []Candidate{
+				{StrategyDevfile, "", []string{"devfile.yaml"}, 3001},
+				{StrategyDockerfile, "", []string{"Containerfile"}, 2001},
+				{StrategyBuilderImage, "java", []string{"pom.xml"}, 1001},
+			},
+		},
+		{
+			name:  "Most matching files first",
+			files: []string{"index.html", "requirements.txt", "app.py", "package.json"},
+			want: []Candidate{
+				{StrategyBuilderImage, "python", []string{"requirements.txt", "app.py"}, 1002},
+				{StrategyBuilderImage, "nodejs", []string{"package.json"}, 1001},
+				{StrategyBuilderImage, "httpd", []string{"index.html"}, 1001},
+			},
+		},
+		{
+			name:  "Patterns",
+			files: []string{"hello.csproj"},
+			want: []Candidate{
+				{StrategyBuilderImage, "dotnet", []string{"hello.csproj"}, 1001},
+			},
+		},
+		{
+			name:  "Nothing detected",
+			files: []string{"README.md", "LICENSE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Detect(tt.files))
+		})
+	}
+}
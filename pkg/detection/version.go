@@ -0,0 +1,303 @@
+package detection
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VersionHint is a runtime version requested by a file of the repository.
+type VersionHint struct {
+	// Constraint is a version, e.g. "3.11.4", or a version range, e.g. ">=18".
+	Constraint string
+
+	// File is the file the hint was read from.
+	File string
+}
+
+// versionSource reads a version constraint from the content of a file. An
+// empty constraint means the file holds no usable hint.
+type versionSource struct {
+	file  string
+	parse func(content []byte) string
+}
+
+// versionSources are the files holding the runtime version of the sources of
+// a builder image, by decreasing priority.
+var versionSources = map[string][]versionSource{
+	"golang": {{"go.mod", parseGoMod}},
+	"nodejs": {{"package.json", parsePackageJSON}, {".nvmrc", parseNvmrc}},
+	"python": {{"runtime.txt", parseRuntimeTxt}, {".python-version", parseVersionFile}},
+	"java":   {{".java-version", parseJavaVersion}},
+}
+
+// DetectVersion returns the runtime version requested for builderImage by the
+// files of a directory, or nil if there is none. files are the names of the
+// files directly in the directory and readFile returns the content of one.
+func DetectVersion(builderImage string, files []string, readFile func(name string) ([]byte, error)) (*VersionHint, error) {
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file] = true
+	}
+	for _, source := range versionSources[builderImage] {
+		if !present[source.file] {
+			continue
+		}
+		content, err := readFile(source.file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", source.file, err)
+		}
+		constraint := source.parse(content)
+		if constraint == "" {
+			continue
+		}
+		if _, err := parseConstraint(constraint); err != nil {
+			continue
+		}
+		return &VersionHint{Constraint: constraint, File: source.file}, nil
+	}
+	return nil, nil
+}
+
+// parseGoMod returns the go directive of a go.mod file. It is the minimum Go
+// version of the module.
+func parseGoMod(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "go" {
+			return ">=" + fields[1]
+		}
+	}
+	return ""
+}
+
+// parsePackageJSON returns the node version range of the engines of a package.json file.
+func parsePackageJSON(content []byte) string {
+	var packageJSON struct {
+		Engines struct {
+			Node string `json:"node"`
+		} `json:"engines"`
+	}
+	if err := json.Unmarshal(content, &packageJSON); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(packageJSON.Engines.Node)
+}
+
+// parseNvmrc returns the node version of a .nvmrc file. Aliases like "lts/*"
+// are not versions and are ignored.
+func parseNvmrc(content []byte) string {
+	return strings.TrimPrefix(parseVersionFile(content), "v")
+}
+
+// parseRuntimeTxt returns the python version of a runtime.txt file, e.g. "python-3.11.4".
+func parseRuntimeTxt(content []byte) string {
+	return strings.TrimPrefix(parseVersionFile(content), "python-")
+}
+
+// parseJavaVersion returns the java version of a .java-version file, where
+// the legacy "1.8" notation stands for 8.
+func parseJavaVersion(content []byte) string {
+	return strings.TrimPrefix(parseVersionFile(content), "1.")
+}
+
+// parseVersionFile returns the first line of a file holding a version.
+func parseVersionFile(content []byte) string {
+	line, _, _ := strings.Cut(string(content), "\n")
+	return strings.TrimSpace(line)
+}
+
+// version is a dotted version, e.g. [3 11 4] for "3.11.4".
+type version []int
+
+// compare compares v with other over the components of v only, so that a
+// floating tag like "3.11" compares equal to any 3.11.x version.
+func (v version) compare(other version) int {
+	for i := range v {
+		if i >= len(other) {
+			return 0
+		}
+		if v[i] != other[i] {
+			if v[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// less orders versions, a shorter version being lower than its extensions.
+func (v version) less(other version) bool {
+	for i := range v {
+		if i >= len(other) {
+			return false
+		}
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return len(v) < len(other)
+}
+
+// parseVersion parses the numeric components of s, stopping at a wildcard like "x".
+func parseVersion(s string) (version, error) {
+	var v version
+	for _, part := range strings.Split(strings.TrimPrefix(s, "v"), ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		v = append(v, n)
+	}
+	if len(v) == 0 {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// comparison is a term of a version range, e.g. ">=18".
+type comparison struct {
+	operator string
+	version  version
+}
+
+// matches reports whether the tag version v satisfies the comparison.
+func (c comparison) matches(v version) bool {
+	cmp := v.compare(c.version)
+	switch c.operator {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "^":
+		return cmp >= 0 && v[0] == c.version[0]
+	case "~":
+		return cmp >= 0 && v.compare(c.version[:min(2, len(c.version))]) == 0
+	default:
+		return cmp == 0
+	}
+}
+
+// constraint is a version range, matched when all the comparisons of one of
+// its alternatives match.
+type constraint [][]comparison
+
+var (
+	operatorPattern   = regexp.MustCompile(`(>=|<=|>|<|=|\^|~)\s+`)
+	comparisonPattern = regexp.MustCompile(`^(>=|<=|>|<|=|\^|~)?(.+)$`)
+)
+
+// parseConstraint parses versions and the npm-like version ranges of
+// package.json engines, e.g. "18.x", "^20.1" or ">=18 <21 || 22".
+func parseConstraint(s string) (constraint, error) {
+	var c constraint
+	for _, alternative := range strings.Split(operatorPattern.ReplaceAllString(s, "$1"), "||") {
+		var comparisons []comparison
+		for _, term := range strings.Fields(alternative) {
+			match := comparisonPattern.FindStringSubmatch(term)
+			v, err := parseVersion(match[2])
+			if err != nil {
+				return nil, err
+			}
+			comparisons = append(comparisons, comparison{operator: match[1], version: v})
+		}
+		if len(comparisons) == 0 {
+			return nil, fmt.Errorf("invalid version range %q", s)
+		}
+		c = append(c, comparisons)
+	}
+	return c, nil
+}
+
+func (c constraint) matches(v version) bool {
+	for _, comparisons := range c {
+		matched := true
+		for _, comparison := range comparisons {
+			matched = matched && comparison.matches(v)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// tagVersionPattern matches the version of an ImageStream tag, e.g. "1.22" in
+// "1.22-ubi9" or "17" in "openjdk-17-ubi8", but not the "8" of "ubi8".
+var tagVersionPattern = regexp.MustCompile(`(?:^|[-_])v?(\d+(?:\.\d+)*)(?:$|[-_])`)
+
+// LatestTag is the tag used when the repository requests no runtime version.
+const LatestTag = "latest"
+
+// SelectTag returns the tag of the builderImage ImageStream best matching the
+// runtime version hint, with the reason of the choice. It is the highest
+// version matching the hint or, without a hint, the latest tag. An error is
+// returned when no tag matches.
+func SelectTag(builderImage string, hint *VersionHint, tags []string) (string, string, error) {
+	if hint == nil {
+		for _, tag := range tags {
+			if tag == LatestTag {
+				return tag, "No runtime version found in the repository, using the latest tag", nil
+			}
+		}
+	}
+
+	var c constraint
+	if hint != nil {
+		var err error
+		if c, err = parseConstraint(hint.Constraint); err != nil {
+			return "", "", err
+		}
+	}
+
+	type taggedVersion struct {
+		tag     string
+		version version
+	}
+	var candidates []taggedVersion
+	for _, tag := range tags {
+		match := tagVersionPattern.FindStringSubmatch(tag)
+		if match == nil {
+			continue
+		}
+		v, err := parseVersion(match[1])
+		if err != nil || (c != nil && !c.matches(v)) {
+			continue
+		}
+		candidates = append(candidates, taggedVersion{tag, v})
+	}
+	if len(candidates) == 0 {
+		if hint == nil {
+			return "", "", fmt.Errorf("the %s ImageStream has no versioned or latest tag", builderImage)
+		}
+		return "", "", fmt.Errorf("no tag of the %s ImageStream matches the version %s of %s",
+			builderImage, hint.Constraint, hint.File)
+	}
+
+	// Highest version first, then the newest base image name, e.g. "-ubi9" before "-ubi8"
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].version.less(candidates[j].version) != candidates[j].version.less(candidates[i].version) {
+			return candidates[j].version.less(candidates[i].version)
+		}
+		return candidates[i].tag > candidates[j].tag
+	})
+	tag := candidates[0].tag
+	if hint == nil {
+		return tag, "No runtime version found in the repository, using the highest version tag", nil
+	}
+	return tag, fmt.Sprintf("%s is the highest tag matching the version %s of %s", tag, hint.Constraint, hint.File), nil
+}
@@ -0,0 +1,163 @@
+package detection
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		builderImage string
+		files        map[string]string
+		want         *VersionHint
+	}{
+		{
+			name:         "go.mod",
+			builderImage: "golang",
+			files:        map[string]string{"go.mod": "module example.com/hello\n\ngo 1.22.0\n\ntoolchain go1.22.5\n"},
+			want:         &VersionHint{">=1.22.0", "go.mod"},
+		},
+		{
+			name:         "package.json engines before .nvmrc",
+			builderImage: "nodejs",
+			files:        map[string]string{"package.json": `{"engines": {"node": ">= 18"}}`, ".nvmrc": "v20.11.0\n"},
+			want:         &VersionHint{">= 18", "package.json"},
+		},
+		{
+			name:         ".nvmrc",
+			builderImage: "nodejs",
+			files:        map[string]string{"package.json": `{"name": "hello"}`, ".nvmrc": "v20.11.0\n"},
+			want:         &VersionHint{"20.11.0", ".nvmrc"},
+		},
+		{
+			name:         ".nvmrc alias",
+			builderImage: "nodejs",
+			files:        map[string]string{".nvmrc": "lts/*\n"},
+		},
+		{
+			name:         "runtime.txt",
+			builderImage: "python",
+			files:        map[string]string{"runtime.txt": "python-3.11.4\n", ".python-version": "3.9\n"},
+			want:         &VersionHint{"3.11.4", "runtime.txt"},
+		},
+		{
+			name:         ".python-version",
+			builderImage: "python",
+			files:        map[string]string{".python-version": "3.9\n"},
+			want:         &VersionHint{"3.9", ".python-version"},
+		},
+		{
+			name:         ".java-version",
+			builderImage: "java",
+			files:        map[string]string{".java-version": "1.8\n"},
+			want:         &VersionHint{"8", ".java-version"},
+		},
+		{
+			name:         "No version file",
+			builderImage: "ruby",
+			files:        map[string]string{"Gemfile": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []string
+			for name := range tt.files {
+				files = append(files, name)
+			}
+			got, err := DetectVersion(tt.builderImage, files, func(name string) ([]byte, error) {
+				return []byte(tt.files[name]), nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDetectVersionReadError(t *testing.T) {
+	_, err := DetectVersion("golang", []string{"go.mod"}, func(string) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	assert.ErrorContains(t, err, "cannot read go.mod: boom")
+}
+
+func TestSelectTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		hint    *VersionHint
+		tags    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "Minimum Go version",
+			image: "golang",
+			hint:  &VersionHint{">=1.21.3", "go.mod"},
+			tags:  []string{"1.20-ubi9", "1.21-ubi9", "1.22-ubi8", "1.22-ubi9", "latest"},
+			want:  "1.22-ubi9",
+		},
+		{
+			name:  "Caret range",
+			image: "nodejs",
+			hint:  &VersionHint{"^18.2", "package.json"},
+			tags:  []string{"16-ubi8", "18-ubi8", "18-ubi9-minimal", "20-ubi9", "latest"},
+			want:  "18-ubi9-minimal",
+		},
+		{
+			name:  "Range alternatives",
+			image: "nodejs",
+			hint:  &VersionHint{">=14 <17 || 18.x", "package.json"},
+			tags:  []string{"16-ubi8", "18-ubi8", "20-ubi9"},
+			want:  "18-ubi8",
+		},
+		{
+			name:  "Exact version on a floating tag",
+			image: "python",
+			hint:  &VersionHint{"3.11.4", "runtime.txt"},
+			tags:  []string{"3.9-ubi8", "3.11-ubi8", "3.12-ubi9", "latest"},
+			want:  "3.11-ubi8",
+		},
+		{
+			name:  "Tag with a prefix",
+			image: "java",
+			hint:  &VersionHint{"17", ".java-version"},
+			tags:  []string{"openjdk-11-ubi8", "openjdk-17-ubi8", "openjdk-21-ubi8"},
+			want:  "openjdk-17-ubi8",
+		},
+		{
+			name:  "Latest without a hint",
+			image: "ruby",
+			tags:  []string{"3.1-ubi8", "latest"},
+			want:  "latest",
+		},
+		{
+			name:  "Highest version without a hint nor latest tag",
+			image: "ruby",
+			tags:  []string{"3.1-ubi8", "3.3-ubi9"},
+			want:  "3.3-ubi9",
+		},
+		{
+			name:    "No matching tag",
+			image:   "nodejs",
+			hint:    &VersionHint{">=22", "package.json"},
+			tags:    []string{"18-ubi8", "20-ubi9", "latest"},
+			wantErr: "no tag of the nodejs ImageStream matches the version >=22 of package.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, reason, err := SelectTag(tt.image, tt.hint, tt.tags)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tag)
+			assert.NotEmpty(t, reason)
+		})
+	}
+}
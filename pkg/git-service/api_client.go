@@ -0,0 +1,212 @@
+package gitservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiClient is a minimal client for the REST APIs of providers without a Go SDK.
+type apiClient struct {
+	// baseURL is the API endpoint request paths are appended to, without trailing slash.
+	baseURL string
+
+	// httpClient sends the requests. http.DefaultClient is used when nil.
+	httpClient *http.Client
+
+	// header is added to every request, typically to carry credentials.
+	header http.Header
+
+	// untrusted is set for the servers that are not known Git providers. The
+	// messages of their error responses are left out of the errors, which are
+	// reported in the status, as they could be any internal service.
+	untrusted bool
+}
+
+func newAPIClient(baseURL string, httpClient *http.Client) *apiClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &apiClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+		header:     http.Header{},
+	}
+}
+
+// maxErrorMessage bounds the length of the error message of a provider kept in
+// a statusError.
+const maxErrorMessage = 200
+
+// statusError is returned by apiClient when the API answers with a non-2xx
+// status. The response body is never kept, only the error message of the
+// provider, see errorMessage.
+type statusError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Message    string
+}
+
+func (e *statusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// errorMessage returns the message of the JSON error response of a provider:
+// the "message" of Github, Gitlab, Gitea and Azure DevOps, the "error" of
+// Gitlab, the "error.message" of Bitbucket Cloud or the first "errors.message"
+// of Bitbucket Data Center. It returns the empty string for any other body.
+func errorMessage(data []byte) string {
+	var body struct {
+		Message json.RawMessage `json:"message"`
+		Error   json.RawMessage `json:"error"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return ""
+	}
+	var message string
+	var nested struct {
+		Message string `json:"message"`
+	}
+	switch {
+	case json.Unmarshal(body.Message, &message) == nil && message != "":
+	case json.Unmarshal(body.Error, &message) == nil && message != "":
+	case json.Unmarshal(body.Error, &nested) == nil && nested.Message != "":
+		message = nested.Message
+	case len(body.Errors) > 0:
+		message = body.Errors[0].Message
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > maxErrorMessage {
+		message = message[:maxErrorMessage] + "..."
+	}
+	return message
+}
+
+// do sends a request to path, which must already be escaped. body, if not nil,
+// is sent as JSON. The response is decoded into out when it is not nil; a
+// *[]byte receives the raw response body.
+func (c *apiClient) do(
+	ctx context.Context, method, path string, query url.Values, body, out any,
+) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := &statusError{StatusCode: resp.StatusCode, Method: method, URL: u}
+		if !c.untrusted {
+			err.Message = errorMessage(data)
+		}
+		return resp, err
+	}
+	switch out := out.(type) {
+	case nil:
+	case *[]byte:
+		*out = data
+	default:
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, out); err != nil {
+				return resp, err
+			}
+		}
+	}
+	return resp, nil
+}
+
+// get sends a GET request to path and decodes the response into out.
+func (c *apiClient) get(ctx context.Context, path string, query url.Values, out any) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, path, query, nil, out)
+}
+
+// basicAuth returns the value of an Authorization header for HTTP basic authentication.
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// escapePath escapes each segment of a slash-separated path.
+func escapePath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// apiError maps an unsuccessful API request onto a GitConditionReason, using
+// notFound as the reason of a 404. resp is nil when no response was received.
+func apiError(resp *http.Response, err error, notFound GitConditionReason) error {
+	if resp == nil {
+		return transportError(err)
+	}
+	if isRateLimited(resp) {
+		return rateLimitError(resp.Header, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized && isAuthenticated(resp.Request):
+		return newError(ReasonAuthenticationFailed, err)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return newError(ReasonAccessTokenRequired, err)
+	case resp.StatusCode == http.StatusForbidden && strings.Contains(strings.ToLower(err.Error()), "archived"):
+		// e.g. "Repository was archived so is read-only." on Github
+		return newError(ReasonRepoArchived, err)
+	case resp.StatusCode == http.StatusForbidden && !isAuthenticated(resp.Request):
+		// The server tells a private repository apart from a missing one, which
+		// most hide behind a 404 from anonymous users.
+		return newError(ReasonPrivateRepo, err)
+	case resp.StatusCode == http.StatusForbidden:
+		return newError(ReasonForbidden, err)
+	case resp.StatusCode == http.StatusNotFound:
+		return newError(notFound, err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return newError(ReasonServerError, err)
+	default:
+		return newError(ReasonRepoNotReachable, err)
+	}
+}
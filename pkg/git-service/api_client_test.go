@@ -0,0 +1,51 @@
+package gitservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorMessageOfResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"Github", `{"message":"Not Found","documentation_url":"https://docs.github.com"}`, "Not Found"},
+		{"Gitlab", `{"error":"insufficient_scope"}`, "insufficient_scope"},
+		{"Gitlab validation", `{"message":{"name":["has already been taken"]}}`, ""},
+		{"Bitbucket Cloud", `{"type":"error","error":{"message":"Repository not found"}}`, "Repository not found"},
+		{"Bitbucket Data Center", `{"errors":[{"message":"Project HELLO does not exist."}]}`,
+			"Project HELLO does not exist."},
+		{"HTML", "<html><body>internal admin page</body></html>", ""},
+		{"Other JSON", `{"password":"s3cr3t"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorMessage([]byte(tt.body)))
+		})
+	}
+}
+
+func TestUntrustedErrorResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"internal admin page"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := newAPIClient(server.URL, nil)
+	_, err := client.get(context.Background(), "/", nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403 Forbidden: internal admin page")
+
+	client.untrusted = true
+	_, err = client.get(context.Background(), "/", nil, nil)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "internal admin page")
+}
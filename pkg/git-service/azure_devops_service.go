@@ -0,0 +1,226 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	azureDevOpsHost       = "dev.azure.com"
+	azureDevOpsAPIURL     = "https://dev.azure.com"
+	azureDevOpsAPIVersion = "7.0"
+)
+
+func init() {
+	RegisterProvider(AzureDevOps, newAzureDevOpsProvider, azureDevOpsHost)
+}
+
+// azureDevOpsProvider talks to the Git REST API of Azure DevOps Services and
+// Azure DevOps Server. Repositories are addressed by organization (collection
+// on Azure DevOps Server), project and repository name.
+type azureDevOpsProvider struct {
+	client *apiClient
+	logger logr.Logger
+}
+
+func newAzureDevOpsProvider(opts ProviderOptions) (Provider, error) {
+	apiURL := opts.APIURL
+	if apiURL == "" {
+		apiURL = azureDevOpsAPIURL
+		if opts.Host != "" && opts.Host != azureDevOpsHost {
+			apiURL = opts.hostURL()
+		}
+	}
+	client := newAPIClient(apiURL, opts.HTTPClient)
+	// Personal access tokens are sent as the password. Azure DevOps ignores the
+	// username, blank unless the source secret sets one.
+	if token := opts.Credentials.accessToken(); token != "" {
+		client.header.Set("Authorization", basicAuth(opts.Credentials.Username, token))
+	}
+	return &azureDevOpsProvider{client: client, logger: opts.Logger}, nil
+}
+
+// ParseURL parses URLs of the form https://dev.azure.com/{org}/{project}/_git/{repo}.
+// The project may be omitted when it has the same name as the repository.
+func (p *azureDevOpsProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	repo := &Repository{Host: normalizeHost(u.Host)}
+	switch {
+	case len(segments) == 4 && segments[2] == "_git":
+		repo.Owner, repo.Project, repo.Name = segments[0], segments[1], segments[3]
+	case len(segments) == 3 && segments[1] == "_git":
+		repo.Owner, repo.Project, repo.Name = segments[0], segments[2], segments[2]
+	}
+	if repo.Owner == "" || repo.Project == "" || repo.Name == "" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	return repo, nil
+}
+
+func (p *azureDevOpsProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
+	}
+	p.logger.Info("Successfully reached Azure DevOps API!")
+	return nil
+}
+
+func (p *azureDevOpsProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	ref, err := resolveRef(ctx, reference,
+		func(ctx context.Context, branch string) (string, error) {
+			return p.refCommit(ctx, repo, "heads/"+branch)
+		},
+		func(ctx context.Context, tag string) (string, error) {
+			return p.refCommit(ctx, repo, "tags/"+tag)
+		},
+		func(ctx context.Context, sha string) (string, error) {
+			var commit struct {
+				CommitID string `json:"commitId"`
+			}
+			resp, err := p.client.get(ctx, p.repoPath(repo)+"/commits/"+url.PathEscape(sha), p.query(url.Values{}), &commit)
+			if err != nil {
+				return "", azureDevOpsError(resp, err, ReasonRepoNotFound)
+			}
+			return commit.CommitID, nil
+		},
+	)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Azure DevOps API")
+		return nil, err
+	}
+	return ref, nil
+}
+
+// refCommit returns the commit the reference name, e.g. "heads/main", points
+// at, peeling annotated tags.
+func (p *azureDevOpsProvider) refCommit(ctx context.Context, repo *Repository, name string) (string, error) {
+	var refs struct {
+		Value []struct {
+			Name           string `json:"name"`
+			ObjectID       string `json:"objectId"`
+			PeeledObjectID string `json:"peeledObjectId"`
+		} `json:"value"`
+	}
+	query := p.query(url.Values{"filter": {name}, "peelTags": {"true"}})
+	resp, err := p.client.get(ctx, p.repoPath(repo)+"/refs", query, &refs)
+	if err != nil {
+		return "", azureDevOpsError(resp, err, ReasonRepoNotFound)
+	}
+	// filter matches prefixes, so look for the exact reference name.
+	for _, ref := range refs.Value {
+		if ref.Name == "refs/"+name {
+			if ref.PeeledObjectID != "" {
+				return ref.PeeledObjectID, nil
+			}
+			return ref.ObjectID, nil
+		}
+	}
+	return "", newError(ReasonRepoNotFound, fmt.Errorf("reference %q not found in %s", name, repo.FullName()))
+}
+
+func (p *azureDevOpsProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	var repository struct {
+		DefaultBranch string `json:"defaultBranch"`
+	}
+	resp, err := p.client.get(ctx, p.repoPath(repo), p.query(url.Values{}), &repository)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Azure DevOps API")
+		return "", azureDevOpsError(resp, err, ReasonRepoNotFound)
+	}
+	// Empty repositories have no default branch yet.
+	if repository.DefaultBranch == "" {
+		return "", newError(ReasonRepoNotFound, fmt.Errorf("%s has no default branch", repo.FullName()))
+	}
+	return strings.TrimPrefix(repository.DefaultBranch, "refs/heads/"), nil
+}
+
+func (p *azureDevOpsProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	var content []byte
+	query := p.query(url.Values{
+		"path":                          {path},
+		"versionDescriptor.version":     {reference},
+		"versionDescriptor.versionType": {azureDevOpsVersionType(reference)},
+		"$format":                       {"octetStream"},
+	})
+	resp, err := p.client.get(ctx, p.repoPath(repo)+"/items", query, &content)
+	if err != nil {
+		return nil, azureDevOpsError(resp, err, ReasonFileNotFound)
+	}
+	if resp.StatusCode == http.StatusNonAuthoritativeInfo {
+		return nil, azureDevOpsError(resp, errors.New("sign-in page returned instead of the file"), ReasonFileNotFound)
+	}
+	return content, nil
+}
+
+func (p *azureDevOpsProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	var items struct {
+		Value []struct {
+			Path     string `json:"path"`
+			IsFolder bool   `json:"isFolder"`
+		} `json:"value"`
+	}
+	scopePath := "/" + strings.Trim(path, "/")
+	query := p.query(url.Values{
+		"scopePath":                     {scopePath},
+		"recursionLevel":                {"OneLevel"},
+		"versionDescriptor.version":     {reference},
+		"versionDescriptor.versionType": {azureDevOpsVersionType(reference)},
+	})
+	resp, err := p.client.get(ctx, p.repoPath(repo)+"/items", query, &items)
+	if err != nil {
+		return nil, azureDevOpsError(resp, err, ReasonFileNotFound)
+	}
+	if resp.StatusCode == http.StatusNonAuthoritativeInfo {
+		return nil, azureDevOpsError(resp, errors.New("sign-in page returned instead of the items"), ReasonFileNotFound)
+	}
+	// The scope itself comes first, followed by its children.
+	entries := []Entry{}
+	for _, item := range items.Value {
+		if item.Path == scopePath {
+			if !item.IsFolder {
+				return nil, notADirectory(path)
+			}
+			continue
+		}
+		entries = append(entries, newEntry(item.Path, item.IsFolder))
+	}
+	return entries, nil
+}
+
+// azureDevOpsVersionType returns the type of a version descriptor for
+// reference, which is either a full commit SHA or a branch name.
+func azureDevOpsVersionType(reference string) string {
+	if isFullCommitSHA(reference) {
+		return "commit"
+	}
+	return "branch"
+}
+
+func (p *azureDevOpsProvider) repoPath(repo *Repository) string {
+	return "/" + url.PathEscape(repo.Owner) + "/" + url.PathEscape(repo.Project) +
+		"/_apis/git/repositories/" + url.PathEscape(repo.Name)
+}
+
+// query adds the api-version parameter required by every Azure DevOps call.
+func (p *azureDevOpsProvider) query(values url.Values) url.Values {
+	values.Set("api-version", azureDevOpsAPIVersion)
+	return values
+}
+
+// azureDevOpsError maps an unsuccessful Azure DevOps response onto a
+// GitConditionReason. Azure DevOps answers unauthenticated calls with a
+// 203 and a sign-in page rather than a 401.
+func azureDevOpsError(resp *http.Response, err error, notFound GitConditionReason) error {
+	if resp != nil && resp.StatusCode == http.StatusNonAuthoritativeInfo {
+		return newError(ReasonAccessTokenRequired, err)
+	}
+	return apiError(resp, err, notFound)
+}
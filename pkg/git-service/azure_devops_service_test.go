@@ -0,0 +1,163 @@
+package gitservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const conformanceProject = "platform"
+
+// newFakeAzureDevOpsAPI serves the conformance repository, in the conformance
+// project, through the subset of the Azure DevOps Git REST API used by the
+// Azure DevOps provider. Like Azure DevOps, it answers unauthenticated
+// requests with a 203 and a sign-in page.
+func newFakeAzureDevOpsAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	repoPath := "/" + conformanceOwner + "/" + conformanceProject + "/_apis/git/repositories/" + conformanceName
+	mux.HandleFunc(repoPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"name": conformanceName, "defaultBranch": "refs/heads/" + conformanceBranch})
+	})
+	mux.HandleFunc(repoPath+"/refs", func(w http.ResponseWriter, r *http.Request) {
+		refs := []map[string]any{}
+		for _, name := range []string{"heads/" + conformanceBranch, "heads/" + conformanceBranch + "-old"} {
+			if strings.HasPrefix(name, r.URL.Query().Get("filter")) {
+				refs = append(refs, map[string]any{"name": "refs/" + name, "objectId": conformanceSHA})
+			}
+		}
+		if strings.HasPrefix("tags/"+conformanceTag, r.URL.Query().Get("filter")) {
+			ref := map[string]any{"name": "refs/tags/" + conformanceTag, "objectId": conformanceTagSHA}
+			if r.URL.Query().Get("peelTags") == "true" {
+				ref["peeledObjectId"] = conformanceSHA
+			}
+			refs = append(refs, ref)
+		}
+		writeJSON(w, map[string]any{"value": refs, "count": len(refs)})
+	})
+	mux.HandleFunc(repoPath+"/commits/"+conformanceSHA, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"commitId": conformanceSHA})
+	})
+	mux.HandleFunc(repoPath+"/items", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if scopePath := query.Get("scopePath"); scopePath != "" {
+			entries, isFile, ok := lookupConformanceTree(scopePath)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			items := []map[string]any{{"path": scopePath, "isFolder": !isFile}}
+			for _, entry := range entries {
+				items = append(items, map[string]any{"path": "/" + entry.Path, "isFolder": entry.Type == EntryTypeDir})
+			}
+			writeJSON(w, map[string]any{"value": items, "count": len(items)})
+			return
+		}
+		if query.Get("path") != conformanceFile || query.Get("versionDescriptor.version") != conformanceBranch {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(conformanceContent))
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != conformanceToken {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNonAuthoritativeInfo)
+			_, _ = w.Write([]byte("<html>Sign in</html>"))
+			return
+		}
+		if r.URL.Query().Get("api-version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAzureDevOpsProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeAzureDevOpsAPI(t)
+		provider, err := newAzureDevOpsProvider(ProviderOptions{
+			APIURL:      server.URL,
+			Credentials: testCredentials,
+			Logger:      testLogger,
+		})
+		require.NoError(t, err)
+		return providerFixture{
+			provider: provider,
+			gitURL: "https://dev.azure.com/" + conformanceOwner + "/" + conformanceProject +
+				"/_git/" + conformanceName,
+		}
+	})
+}
+
+func TestAzureDevOpsParseURL(t *testing.T) {
+	provider, err := newAzureDevOpsProvider(ProviderOptions{Logger: testLogger})
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		gitURL  string
+		want    *Repository
+		wantErr bool
+	}{
+		{
+			"Repository URL",
+			"https://dev.azure.com/org/project/_git/repo",
+			&Repository{Host: "dev.azure.com", Owner: "org", Project: "project", Name: "repo"},
+			false,
+		},
+		{
+			"Clone URL with user",
+			"https://org@dev.azure.com/org/My%20Project/_git/repo",
+			&Repository{Host: "dev.azure.com", Owner: "org", Project: "My Project", Name: "repo"},
+			false,
+		},
+		{
+			"Project named after the repository",
+			"https://dev.azure.com/org/_git/repo",
+			&Repository{Host: "dev.azure.com", Owner: "org", Project: "repo", Name: "repo"},
+			false,
+		},
+		{"Owner and repository only", "https://dev.azure.com/org/repo", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.gitURL)
+			require.NoError(t, err)
+			repo, err := provider.ParseURL(u)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, repo)
+			assert.Equal(t, tt.want.Owner+"/"+tt.want.Project+"/"+tt.want.Name, repo.FullName())
+		})
+	}
+}
+
+func TestAzureDevOpsSignInPage(t *testing.T) {
+	server := newFakeAzureDevOpsAPI(t)
+	provider, err := newAzureDevOpsProvider(ProviderOptions{APIURL: server.URL, Logger: testLogger})
+	require.NoError(t, err)
+	repo := &Repository{Owner: conformanceOwner, Project: conformanceProject, Name: conformanceName}
+
+	_, err = provider.ResolveRef(context.Background(), repo, conformanceBranch)
+	assert.Equal(t, ReasonAccessTokenRequired, ReasonForError(err))
+	_, err = provider.GetFile(context.Background(), repo, conformanceBranch, conformanceFile)
+	assert.Equal(t, ReasonAccessTokenRequired, ReasonForError(err))
+}
+
+func TestGitServiceCarriesProjectCoordinates(t *testing.T) {
+	gs := New("https://dev.azure.com/org/project/_git/repo", "main", Credentials{}, testLogger)
+	require.NotNil(t, gs.Repository())
+	assert.Equal(t, AzureDevOps, gs.gitType)
+	assert.Equal(t, "project", gs.Repository().Project)
+}
@@ -0,0 +1,658 @@
+package gitservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	bitbucketHost   = "bitbucket.org"
+	bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+)
+
+func init() {
+	RegisterProvider(Bitbucket, newBitbucketProvider, bitbucketHost)
+	// Bitbucket Data Center is always self-hosted, so it has no default host.
+	RegisterProvider(BitbucketServer, newBitbucketServerProvider)
+}
+
+// bitbucketProvider talks to the Bitbucket Cloud REST API 2.0.
+type bitbucketProvider struct {
+	client *apiClient
+	logger logr.Logger
+}
+
+func newBitbucketProvider(opts ProviderOptions) (Provider, error) {
+	apiURL := opts.APIURL
+	if apiURL == "" {
+		apiURL = bitbucketAPIURL
+	}
+	client := newAPIClient(apiURL, opts.HTTPClient)
+	opts.Credentials.setAuthorization(client, "Bearer")
+	return &bitbucketProvider{client: client, logger: opts.Logger}, nil
+}
+
+// ParseURL parses URLs of the form https://bitbucket.org/{workspace}/{repo_slug}.
+func (p *bitbucketProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	return &Repository{Host: normalizeHost(u.Host), Owner: segments[0], Name: segments[1]}, nil
+}
+
+func (p *bitbucketProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
+	}
+	p.logger.Info("Successfully reached Bitbucket API!")
+	return nil
+}
+
+func (p *bitbucketProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	ref, err := resolveRef(ctx, reference,
+		func(ctx context.Context, branch string) (string, error) {
+			return p.refCommit(ctx, repo, "/refs/branches/"+url.PathEscape(branch))
+		},
+		func(ctx context.Context, tag string) (string, error) {
+			return p.refCommit(ctx, repo, "/refs/tags/"+url.PathEscape(tag))
+		},
+		func(ctx context.Context, sha string) (string, error) {
+			var commit struct {
+				Hash string `json:"hash"`
+			}
+			resp, err := p.client.get(ctx, p.repoPath(repo)+"/commit/"+url.PathEscape(sha), nil, &commit)
+			if err != nil {
+				return "", apiError(resp, err, ReasonRepoNotFound)
+			}
+			return commit.Hash, nil
+		},
+	)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Bitbucket API")
+		return nil, err
+	}
+	return ref, nil
+}
+
+// refCommit returns the commit the branch or tag at path points at.
+func (p *bitbucketProvider) refCommit(ctx context.Context, repo *Repository, path string) (string, error) {
+	var ref struct {
+		Target struct {
+			Hash string `json:"hash"`
+		} `json:"target"`
+	}
+	resp, err := p.client.get(ctx, p.repoPath(repo)+path, nil, &ref)
+	if err != nil {
+		return "", apiError(resp, err, ReasonRepoNotFound)
+	}
+	return ref.Target.Hash, nil
+}
+
+func (p *bitbucketProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	var repository struct {
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	}
+	resp, err := p.client.get(ctx, p.repoPath(repo), nil, &repository)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Bitbucket API")
+		return "", apiError(resp, err, ReasonRepoNotFound)
+	}
+	return repository.MainBranch.Name, nil
+}
+
+func (p *bitbucketProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	var content []byte
+	resp, err := p.client.get(ctx,
+		p.repoPath(repo)+"/src/"+url.PathEscape(reference)+"/"+escapePath(path), nil, &content)
+	if err != nil {
+		return nil, apiError(resp, err, ReasonFileNotFound)
+	}
+	return content, nil
+}
+
+func (p *bitbucketProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	srcPath := p.repoPath(repo) + "/src/" + url.PathEscape(reference) + "/"
+	if path != "" {
+		// The src endpoint serves the content of files, so check the type first.
+		var meta struct {
+			Type string `json:"type"`
+		}
+		resp, err := p.client.get(ctx, srcPath+escapePath(path), url.Values{"format": {"meta"}}, &meta)
+		if err != nil {
+			return nil, apiError(resp, err, ReasonFileNotFound)
+		}
+		if meta.Type != "commit_directory" {
+			return nil, notADirectory(path)
+		}
+		srcPath += escapePath(path) + "/"
+	}
+
+	var entries []Entry
+	query := url.Values{"pagelen": {"100"}}
+	for srcPath != "" {
+		var page struct {
+			Values []struct {
+				Path string `json:"path"`
+				Type string `json:"type"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		resp, err := p.client.get(ctx, srcPath, query, &page)
+		if err != nil {
+			return nil, apiError(resp, err, ReasonFileNotFound)
+		}
+		for _, value := range page.Values {
+			entries = append(entries, newEntry(value.Path, value.Type == "commit_directory"))
+		}
+		if srcPath, query, err = p.nextPage(page.Next); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// nextPage returns the path and query, relative to the API root, of the next
+// page of a paginated response, or an empty path on the last page. The host of
+// the next page URL is ignored, so that the credentials are only ever sent to
+// the API root.
+func (p *bitbucketProvider) nextPage(next string) (string, url.Values, error) {
+	if next == "" {
+		return "", nil, nil
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		return "", nil, newError(ReasonRepoNotReachable, fmt.Errorf("invalid next page URL: %w", err))
+	}
+	base, err := url.Parse(p.client.baseURL)
+	if err != nil {
+		return "", nil, newError(ReasonRepoNotReachable, err)
+	}
+	path, ok := strings.CutPrefix(u.EscapedPath(), base.EscapedPath())
+	if !ok || !strings.HasPrefix(path, "/") {
+		return "", nil, newError(ReasonRepoNotReachable,
+			fmt.Errorf("the next page %s is outside of the API root %s", u.EscapedPath(), base.EscapedPath()))
+	}
+	return path, u.Query(), nil
+}
+
+func (p *bitbucketProvider) repoPath(repo *Repository) string {
+	return "/repositories/" + url.PathEscape(repo.Owner) + "/" + url.PathEscape(repo.Name)
+}
+
+func (p *bitbucketProvider) CreateWebhook(ctx context.Context, repo *Repository, hook Webhook) (string, error) {
+	var created struct {
+		UUID string `json:"uuid"`
+	}
+	resp, err := p.client.do(ctx, http.MethodPost, p.repoPath(repo)+"/hooks", nil, bitbucketHook(hook), &created)
+	if err != nil {
+		return "", apiError(resp, err, ReasonRepoNotFound)
+	}
+	return created.UUID, nil
+}
+
+func (p *bitbucketProvider) UpdateWebhook(ctx context.Context, repo *Repository, id string, hook Webhook) error {
+	resp, err := p.client.do(ctx, http.MethodPut, p.repoPath(repo)+"/hooks/"+url.PathEscape(id), nil,
+		bitbucketHook(hook), nil)
+	if err != nil {
+		return apiError(resp, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+func (p *bitbucketProvider) DeleteWebhook(ctx context.Context, repo *Repository, id string) error {
+	resp, err := p.client.do(ctx, http.MethodDelete, p.repoPath(repo)+"/hooks/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return apiError(resp, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+// bitbucketHook returns the body of a Bitbucket Cloud webhook sending the
+// push and pull request events, signed with the secret of hook.
+func bitbucketHook(hook Webhook) map[string]any {
+	return map[string]any{
+		"description": webhookName,
+		"url":         hook.URL,
+		"active":      true,
+		"events": []string{"repo:push", "pullrequest:created", "pullrequest:updated", "pullrequest:fulfilled",
+			"pullrequest:rejected"},
+		"secret": hook.Secret,
+	}
+}
+
+func (p *bitbucketProvider) SetCommitStatus(
+	ctx context.Context, repo *Repository, sha string, status CommitStatus,
+) error {
+	if status.TargetURL == "" {
+		// Bitbucket requires a link, the commit itself
+		status.TargetURL = "https://" + repo.Host + "/" + repo.FullName() + "/commits/" + sha
+	}
+	resp, err := p.client.do(ctx, http.MethodPost, p.repoPath(repo)+"/commit/"+url.PathEscape(sha)+"/statuses/build",
+		nil, bitbucketStatus(status), nil)
+	if err != nil {
+		return apiError(resp, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+// bitbucketLink is a link of the Bitbucket APIs.
+type bitbucketLink struct {
+	Href string `json:"href"`
+}
+
+func (p *bitbucketProvider) ListPullRequests(
+	ctx context.Context, repo *Repository, base string,
+) ([]PullRequest, error) {
+	path := p.repoPath(repo) + "/pullrequests"
+	query := url.Values{"state": {"OPEN"}, "pagelen": {"50"}, "q": {fmt.Sprintf("destination.branch.name=%q", base)}}
+	var pullRequests []PullRequest
+	for path != "" {
+		var page struct {
+			Values []struct {
+				ID    int    `json:"id"`
+				Title string `json:"title"`
+				Links struct {
+					HTML bitbucketLink `json:"html"`
+				} `json:"links"`
+				Source struct {
+					Branch struct {
+						Name string `json:"name"`
+					} `json:"branch"`
+					Commit struct {
+						Hash string `json:"hash"`
+					} `json:"commit"`
+					Repository *struct {
+						FullName string `json:"full_name"`
+					} `json:"repository"`
+				} `json:"source"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		resp, err := p.client.get(ctx, path, query, &page)
+		if err != nil {
+			return nil, apiError(resp, err, ReasonRepoNotFound)
+		}
+		for _, pr := range page.Values {
+			// The source repository of a pull request from a deleted fork is gone
+			if pr.Source.Repository == nil {
+				continue
+			}
+			pullRequests = append(pullRequests, PullRequest{
+				Number:      pr.ID,
+				Title:       pr.Title,
+				URL:         pr.Links.HTML.Href,
+				HeadRepoURL: "https://" + repo.Host + "/" + pr.Source.Repository.FullName,
+				HeadRef:     pr.Source.Branch.Name,
+				HeadSHA:     pr.Source.Commit.Hash,
+			})
+		}
+		if path, query, err = p.nextPage(page.Next); err != nil {
+			return nil, err
+		}
+	}
+	return pullRequests, nil
+}
+
+func (p *bitbucketProvider) CommentPullRequest(ctx context.Context, repo *Repository, number int, body string) error {
+	comment := map[string]any{"content": map[string]string{"raw": body}}
+	resp, err := p.client.do(ctx, http.MethodPost, p.repoPath(repo)+"/pullrequests/"+strconv.Itoa(number)+"/comments",
+		nil, comment, nil)
+	if err != nil {
+		return apiError(resp, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+// bitbucketStates are the Bitbucket build states of the commit states.
+var bitbucketStates = map[CommitState]string{
+	CommitStatePending: "INPROGRESS",
+	CommitStateSuccess: "SUCCESSFUL",
+	CommitStateFailure: "FAILED",
+}
+
+// bitbucketStatus returns the body of a Bitbucket build status, Cloud or Data
+// Center. Their key is limited to 40 characters, longer contexts are hashed.
+func bitbucketStatus(status CommitStatus) map[string]any {
+	key := status.Context
+	if len(key) > 40 {
+		digest := sha256.Sum256([]byte(key))
+		key = hex.EncodeToString(digest[:20])
+	}
+	return map[string]any{
+		"key":         key,
+		"name":        status.Context,
+		"state":       bitbucketStates[status.State],
+		"description": status.Description,
+		"url":         status.TargetURL,
+	}
+}
+
+// bitbucketServerProvider talks to the REST API 1.0 of Bitbucket Data Center.
+type bitbucketServerProvider struct {
+	client *apiClient
+	logger logr.Logger
+}
+
+func newBitbucketServerProvider(opts ProviderOptions) (Provider, error) {
+	apiURL := opts.APIURL
+	if apiURL == "" {
+		if opts.Host == "" {
+			return nil, errors.New("a host is required for Bitbucket Data Center")
+		}
+		apiURL = opts.hostURL() + "/rest/api/1.0"
+	}
+	client := newAPIClient(apiURL, opts.HTTPClient)
+	opts.Credentials.setAuthorization(client, "Bearer")
+	return &bitbucketServerProvider{client: client, logger: opts.Logger}, nil
+}
+
+// ParseURL parses clone URLs of the form https://{host}/scm/{project}/{repo}.git
+// and browse URLs of the form https://{host}/projects/{project}/repos/{repo}.
+// The owner of the repository is the project key.
+func (p *bitbucketServerProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	var project, name string
+	switch {
+	case len(segments) == 3 && segments[0] == "scm":
+		project, name = segments[1], segments[2]
+	case len(segments) >= 4 && segments[0] == "projects" && segments[2] == "repos":
+		project, name = segments[1], segments[3]
+	}
+	if project == "" || name == "" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	return &Repository{Host: normalizeHost(u.Host), Owner: project, Name: name}, nil
+}
+
+func (p *bitbucketServerProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
+	}
+	p.logger.Info("Successfully reached Bitbucket Data Center API!")
+	return nil
+}
+
+func (p *bitbucketServerProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	ref, err := resolveRef(ctx, reference,
+		func(ctx context.Context, branch string) (string, error) {
+			// filterText matches substrings, so look for the exact branch name
+			// through all the pages of matching branches.
+			query := url.Values{"filterText": {branch}, "details": {"false"}, "limit": {"1000"}}
+			for {
+				var page struct {
+					Values []struct {
+						DisplayID    string `json:"displayId"`
+						LatestCommit string `json:"latestCommit"`
+					} `json:"values"`
+					IsLastPage    bool `json:"isLastPage"`
+					NextPageStart int  `json:"nextPageStart"`
+				}
+				resp, err := p.client.get(ctx, p.repoPath(repo)+"/branches", query, &page)
+				if err != nil {
+					return "", apiError(resp, err, ReasonRepoNotFound)
+				}
+				for _, b := range page.Values {
+					if b.DisplayID == branch {
+						return b.LatestCommit, nil
+					}
+				}
+				if page.IsLastPage {
+					return "", newError(ReasonRepoNotFound,
+						fmt.Errorf("branch %q not found in %s", branch, repo.FullName()))
+				}
+				query.Set("start", strconv.Itoa(page.NextPageStart))
+			}
+		},
+		func(ctx context.Context, tag string) (string, error) {
+			var t struct {
+				LatestCommit string `json:"latestCommit"`
+			}
+			resp, err := p.client.get(ctx, p.repoPath(repo)+"/tags/"+url.PathEscape(tag), nil, &t)
+			if err != nil {
+				return "", apiError(resp, err, ReasonRepoNotFound)
+			}
+			return t.LatestCommit, nil
+		},
+		func(ctx context.Context, sha string) (string, error) {
+			var commit struct {
+				ID string `json:"id"`
+			}
+			resp, err := p.client.get(ctx, p.repoPath(repo)+"/commits/"+url.PathEscape(sha), nil, &commit)
+			if err != nil {
+				return "", apiError(resp, err, ReasonRepoNotFound)
+			}
+			return commit.ID, nil
+		},
+	)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Bitbucket Data Center API")
+		return nil, err
+	}
+	return ref, nil
+}
+
+func (p *bitbucketServerProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	var branch struct {
+		DisplayID string `json:"displayId"`
+	}
+	resp, err := p.client.get(ctx, p.repoPath(repo)+"/default-branch", nil, &branch)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Bitbucket Data Center API")
+		return "", apiError(resp, err, ReasonRepoNotFound)
+	}
+	return branch.DisplayID, nil
+}
+
+// IsArchived reads the archived flag of the repository, added in Bitbucket
+// Data Center 8.0 and absent, thus false, before. Bitbucket Cloud
+// repositories cannot be archived.
+func (p *bitbucketServerProvider) IsArchived(ctx context.Context, repo *Repository) (bool, error) {
+	var repository struct {
+		Archived bool `json:"archived"`
+	}
+	resp, err := p.client.get(ctx, p.repoPath(repo), nil, &repository)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Bitbucket Data Center API")
+		return false, apiError(resp, err, ReasonRepoNotFound)
+	}
+	return repository.Archived, nil
+}
+
+func (p *bitbucketServerProvider) GetFile(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]byte, error) {
+	var content []byte
+	query := url.Values{"at": {reference}}
+	resp, err := p.client.get(ctx, p.repoPath(repo)+"/raw/"+escapePath(path), query, &content)
+	if err != nil {
+		return nil, apiError(resp, err, ReasonFileNotFound)
+	}
+	return content, nil
+}
+
+func (p *bitbucketServerProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	var entries []Entry
+	query := url.Values{"at": {reference}, "limit": {"1000"}}
+	for {
+		var page struct {
+			Children *struct {
+				Values []struct {
+					Path struct {
+						ToString string `json:"toString"`
+					} `json:"path"`
+					Type string `json:"type"`
+				} `json:"values"`
+				IsLastPage    bool `json:"isLastPage"`
+				NextPageStart int  `json:"nextPageStart"`
+			} `json:"children"`
+		}
+		browsePath := p.repoPath(repo) + "/browse"
+		if path != "" {
+			browsePath += "/" + escapePath(path)
+		}
+		resp, err := p.client.get(ctx, browsePath, query, &page)
+		if err != nil {
+			return nil, apiError(resp, err, ReasonFileNotFound)
+		}
+		// Files are browsed as their lines rather than children.
+		if page.Children == nil {
+			return nil, notADirectory(path)
+		}
+		for _, value := range page.Children.Values {
+			// Children paths are relative to the browsed directory.
+			entries = append(entries, newEntry(path+"/"+value.Path.ToString, value.Type == "DIRECTORY"))
+		}
+		if page.Children.IsLastPage {
+			return entries, nil
+		}
+		query.Set("start", strconv.Itoa(page.Children.NextPageStart))
+	}
+}
+
+func (p *bitbucketServerProvider) repoPath(repo *Repository) string {
+	return "/projects/" + url.PathEscape(repo.Owner) + "/repos/" + url.PathEscape(repo.Name)
+}
+
+func (p *bitbucketServerProvider) CreateWebhook(ctx context.Context, repo *Repository, hook Webhook) (string, error) {
+	var created struct {
+		ID int `json:"id"`
+	}
+	resp, err := p.client.do(ctx, http.MethodPost, p.repoPath(repo)+"/webhooks", nil,
+		bitbucketServerHook(hook), &created)
+	if err != nil {
+		return "", apiError(resp, err, ReasonRepoNotFound)
+	}
+	return strconv.Itoa(created.ID), nil
+}
+
+func (p *bitbucketServerProvider) UpdateWebhook(ctx context.Context, repo *Repository, id string, hook Webhook) error {
+	resp, err := p.client.do(ctx, http.MethodPut, p.repoPath(repo)+"/webhooks/"+url.PathEscape(id), nil,
+		bitbucketServerHook(hook), nil)
+	if err != nil {
+		return apiError(resp, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+func (p *bitbucketServerProvider) DeleteWebhook(ctx context.Context, repo *Repository, id string) error {
+	resp, err := p.client.do(ctx, http.MethodDelete, p.repoPath(repo)+"/webhooks/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return apiError(resp, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+// bitbucketServerHook returns the body of a Bitbucket Data Center webhook
+// sending the reference and pull request changes, signed with the secret of hook.
+func bitbucketServerHook(hook Webhook) map[string]any {
+	return map[string]any{
+		"name":   webhookName,
+		"url":    hook.URL,
+		"active": true,
+		"events": []string{"repo:refs_changed", "pr:opened", "pr:from_ref_updated", "pr:merged", "pr:declined",
+			"pr:deleted"},
+		"configuration": map[string]string{"secret": hook.Secret},
+	}
+}
+
+func (p *bitbucketServerProvider) SetCommitStatus(
+	ctx context.Context, repo *Repository, sha string, status CommitStatus,
+) error {
+	if status.TargetURL == "" {
+		// Bitbucket requires a link, the commit itself
+		status.TargetURL = "https://" + repo.Host + "/projects/" + url.PathEscape(repo.Owner) + "/repos/" +
+			url.PathEscape(repo.Name) + "/commits/" + sha
+	}
+	resp, err := p.client.do(ctx, http.MethodPost, p.repoPath(repo)+"/commits/"+url.PathEscape(sha)+"/builds",
+		nil, bitbucketStatus(status), nil)
+	if err != nil {
+		return apiError(resp, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+func (p *bitbucketServerProvider) ListPullRequests(
+	ctx context.Context, repo *Repository, base string,
+) ([]PullRequest, error) {
+	var pullRequests []PullRequest
+	query := url.Values{"state": {"OPEN"}, "direction": {"INCOMING"}, "at": {"refs/heads/" + base}, "limit": {"100"}}
+	for {
+		var page struct {
+			Values []struct {
+				ID      int    `json:"id"`
+				Title   string `json:"title"`
+				FromRef struct {
+					DisplayID    string `json:"displayId"`
+					LatestCommit string `json:"latestCommit"`
+					Repository   struct {
+						Links struct {
+							Clone []struct {
+								Href string `json:"href"`
+								Name string `json:"name"`
+							} `json:"clone"`
+						} `json:"links"`
+					} `json:"repository"`
+				} `json:"fromRef"`
+				Links struct {
+					Self []bitbucketLink `json:"self"`
+				} `json:"links"`
+			} `json:"values"`
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart int  `json:"nextPageStart"`
+		}
+		resp, err := p.client.get(ctx, p.repoPath(repo)+"/pull-requests", query, &page)
+		if err != nil {
+			return nil, apiError(resp, err, ReasonRepoNotFound)
+		}
+		for _, pr := range page.Values {
+			pullRequest := PullRequest{
+				Number:  pr.ID,
+				Title:   pr.Title,
+				HeadRef: pr.FromRef.DisplayID,
+				HeadSHA: pr.FromRef.LatestCommit,
+			}
+			if len(pr.Links.Self) > 0 {
+				pullRequest.URL = pr.Links.Self[0].Href
+			}
+			for _, clone := range pr.FromRef.Repository.Links.Clone {
+				if clone.Name == "http" {
+					pullRequest.HeadRepoURL = clone.Href
+				}
+			}
+			if pullRequest.HeadRepoURL != "" {
+				pullRequests = append(pullRequests, pullRequest)
+			}
+		}
+		if page.IsLastPage {
+			return pullRequests, nil
+		}
+		query.Set("start", strconv.Itoa(page.NextPageStart))
+	}
+}
+
+func (p *bitbucketServerProvider) CommentPullRequest(
+	ctx context.Context, repo *Repository, number int, body string,
+) error {
+	resp, err := p.client.do(ctx, http.MethodPost,
+		p.repoPath(repo)+"/pull-requests/"+strconv.Itoa(number)+"/comments", nil, map[string]string{"text": body}, nil)
+	if err != nil {
+		return apiError(resp, err, ReasonRepoNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package gitservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeBitbucketAPI serves the conformance repository through the subset of
+// the Bitbucket Cloud REST API 2.0 used by the Bitbucket provider.
+func newFakeBitbucketAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	repoPath := "/2.0/repositories/" + conformanceOwner + "/" + conformanceName
+	mux.HandleFunc(repoPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"slug": conformanceName, "mainbranch": map[string]any{"name": conformanceBranch}})
+	})
+	mux.HandleFunc(repoPath+"/refs/branches/"+conformanceBranch, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"name":   conformanceBranch,
+			"target": map[string]any{"hash": conformanceSHA},
+		})
+	})
+	mux.HandleFunc(repoPath+"/refs/tags/"+conformanceTag, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"name":   conformanceTag,
+			"target": map[string]any{"hash": conformanceSHA},
+		})
+	})
+	mux.HandleFunc(repoPath+"/commit/"+conformanceSHA, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"hash": conformanceSHA})
+	})
+	mux.HandleFunc(repoPath+"/src/"+conformanceBranch+"/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, repoPath+"/src/"+conformanceBranch+"/")
+		entries, isFile, ok := lookupConformanceTree(path)
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case r.URL.Query().Get("format") == "meta":
+			metaType := "commit_directory"
+			if isFile {
+				metaType = "commit_file"
+			}
+			writeJSON(w, map[string]any{"path": path, "type": metaType})
+		case isFile:
+			_, _ = w.Write([]byte(conformanceContent))
+		default:
+			values := []map[string]any{}
+			for _, entry := range entries {
+				valueType := "commit_file"
+				if entry.Type == EntryTypeDir {
+					valueType = "commit_directory"
+				}
+				values = append(values, map[string]any{"path": entry.Path, "type": valueType})
+			}
+			writeJSON(w, map[string]any{"values": values})
+		}
+	})
+	server := httptest.NewServer(requireBearer(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newFakeBitbucketServerAPI serves the conformance repository through the subset
+// of the Bitbucket Data Center REST API 1.0 used by the Bitbucket Data Center provider.
+func newFakeBitbucketServerAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	repoPath := "/rest/api/1.0/projects/" + conformanceOwner + "/repos/" + conformanceName
+	mux.HandleFunc(repoPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"slug": conformanceName, "archived": false})
+	})
+	mux.HandleFunc(repoPath+"/default-branch", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"id": "refs/heads/" + conformanceBranch, "displayId": conformanceBranch})
+	})
+	mux.HandleFunc(repoPath+"/branches", func(w http.ResponseWriter, r *http.Request) {
+		// The branches matching filterText as a substring, one per page.
+		if r.URL.Query().Get("start") == "" {
+			writeJSON(w, map[string]any{
+				"values":        []map[string]any{{"displayId": conformanceBranch + "-old", "latestCommit": "0000000"}},
+				"isLastPage":    false,
+				"nextPageStart": 1,
+			})
+			return
+		}
+		writeJSON(w, map[string]any{
+			"values":     []map[string]any{{"displayId": conformanceBranch, "latestCommit": conformanceSHA}},
+			"isLastPage": true,
+		})
+	})
+	mux.HandleFunc(repoPath+"/tags/"+conformanceTag, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"id":           "refs/tags/" + conformanceTag,
+			"displayId":    conformanceTag,
+			"latestCommit": conformanceSHA,
+			"hash":         conformanceTagSHA,
+		})
+	})
+	mux.HandleFunc(repoPath+"/commits/"+conformanceSHA, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"id": conformanceSHA})
+	})
+	browse := func(w http.ResponseWriter, r *http.Request) {
+		dir := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, repoPath+"/browse"), "/")
+		entries, isFile, ok := lookupConformanceTree(dir)
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case isFile:
+			writeJSON(w, map[string]any{"lines": []map[string]any{{"text": strings.TrimSpace(conformanceContent)}}})
+		default:
+			values := []map[string]any{}
+			for _, entry := range entries {
+				valueType := "FILE"
+				if entry.Type == EntryTypeDir {
+					valueType = "DIRECTORY"
+				}
+				values = append(values, map[string]any{"path": map[string]any{"toString": entry.Name}, "type": valueType})
+			}
+			writeJSON(w, map[string]any{"children": map[string]any{"values": values, "isLastPage": true}})
+		}
+	}
+	mux.HandleFunc(repoPath+"/browse", browse)
+	mux.HandleFunc(repoPath+"/browse/", browse)
+	mux.HandleFunc(repoPath+"/raw/"+conformanceFile, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("at") != conformanceBranch {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(conformanceContent))
+	})
+	server := httptest.NewServer(requireBearer(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// requireBearer rejects requests without the conformance token as bearer token.
+func requireBearer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+conformanceToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestBitbucketProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeBitbucketAPI(t)
+		provider, err := newBitbucketProvider(ProviderOptions{
+			APIURL:      server.URL + "/2.0",
+			Credentials: testCredentials,
+			Logger:      testLogger,
+		})
+		require.NoError(t, err)
+		return providerFixture{
+			provider: provider,
+			gitURL:   "https://bitbucket.org/" + conformanceOwner + "/" + conformanceName + ".git",
+		}
+	})
+}
+
+func TestBitbucketServerProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeBitbucketServerAPI(t)
+		provider, err := newBitbucketServerProvider(ProviderOptions{
+			APIURL:      server.URL + "/rest/api/1.0",
+			Credentials: testCredentials,
+			Logger:      testLogger,
+		})
+		require.NoError(t, err)
+		return providerFixture{
+			provider: provider,
+			gitURL:   "https://bitbucket.example.com/scm/" + conformanceOwner + "/" + conformanceName + ".git",
+		}
+	})
+}
+
+func TestBitbucketServerParseURL(t *testing.T) {
+	provider, err := newBitbucketServerProvider(ProviderOptions{Host: "bitbucket.example.com", Logger: testLogger})
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		gitURL  string
+		project string
+		repo    string
+	}{
+		{"Clone URL", "https://bitbucket.example.com/scm/proj/repo.git", "proj", "repo"},
+		{"Browse URL", "https://bitbucket.example.com/projects/PROJ/repos/repo/browse", "PROJ", "repo"},
+		{"Personal repository", "https://bitbucket.example.com/scm/~jdoe/repo.git", "~jdoe", "repo"},
+		{"Missing repository", "https://bitbucket.example.com/scm/proj", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.gitURL)
+			require.NoError(t, err)
+			repo, err := provider.ParseURL(u)
+			if tt.repo == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.project, repo.Owner)
+			assert.Equal(t, tt.repo, repo.Name)
+		})
+	}
+}
+
+func TestBitbucketNextPage(t *testing.T) {
+	provider, err := newBitbucketProvider(ProviderOptions{APIURL: "https://api.bitbucket.org/2.0", Logger: testLogger})
+	require.NoError(t, err)
+	tests := []struct {
+		name  string
+		next  string
+		path  string
+		query url.Values
+		fails bool
+	}{
+		{name: "Last page"},
+		{
+			name:  "Next page",
+			next:  "https://api.bitbucket.org/2.0/repositories/hello/world/src/main/?pagelen=100&page=abc",
+			path:  "/repositories/hello/world/src/main/",
+			query: url.Values{"pagelen": {"100"}, "page": {"abc"}},
+		},
+		{
+			name:  "Other host",
+			next:  "https://attacker.example.com/2.0/repositories/hello/world/pullrequests?page=2",
+			path:  "/repositories/hello/world/pullrequests",
+			query: url.Values{"page": {"2"}},
+		},
+		{name: "Outside of the API root", next: "https://api.bitbucket.org/internal/repositories", fails: true},
+		{name: "Sibling of the API root", next: "https://api.bitbucket.org/2.0x/repositories", fails: true},
+		{name: "Invalid URL", next: "https://api.bitbucket.org/2.0/%zz", fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, query, err := provider.(*bitbucketProvider).nextPage(tt.next)
+			if tt.fails {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.path, path)
+			if tt.query != nil {
+				assert.Equal(t, tt.query, query)
+			}
+		})
+	}
+}
+
+func TestBitbucketStatusReasons(t *testing.T) {
+	tests := []struct {
+		status int
+		want   GitConditionReason
+	}{
+		{http.StatusUnauthorized, ReasonAccessTokenRequired},
+		{http.StatusForbidden, ReasonPrivateRepo},
+		{http.StatusNotFound, ReasonRepoNotFound},
+		{http.StatusTooManyRequests, ReasonRateLimitExceeded},
+		{http.StatusInternalServerError, ReasonServerError},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			registerTestHost(t, HostConfig{Host: "bitbucket.example.com", Type: Bitbucket, APIURL: server.URL})
+
+			gs := New("https://bitbucket.example.com/hello/world", "main", Credentials{}, testLogger)
+			status, reason := gs.IsRepoReachable(context.Background())
+			assert.Equal(t, "False", string(status))
+			assert.Equal(t, tt.want, reason)
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package gitservice
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultProbeCacheTTL is the default duration probe results are reused for,
+// see SetProbeCacheTTL.
+const DefaultProbeCacheTTL = 30 * time.Second
+
+// maxConditionalEntries bounds the number of responses kept for conditional requests.
+const maxConditionalEntries = 1000
+
+// maxProbeEntries bounds the number of probe results kept, the one expiring
+// first being dropped to make room for a new one.
+const maxProbeEntries = 10000
+
+// probeCache shares the results of the probes of a repository across all the
+// ConsoleApplications pointing at it. Concurrent probes of the same key are
+// collapsed into one.
+type probeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]probeEntry
+	// swept is when the expired entries were last dropped, see evict.
+	swept time.Time
+	group singleflight.Group
+	now   func() time.Time
+}
+
+// probeEntry is the result of a probe, valid until expires.
+type probeEntry struct {
+	value   any
+	expires time.Time
+}
+
+var probes = newProbeCache(DefaultProbeCacheTTL)
+
+func newProbeCache(ttl time.Duration) *probeCache {
+	return &probeCache{ttl: ttl, entries: map[string]probeEntry{}, now: time.Now}
+}
+
+// SetProbeCacheTTL sets the duration probe results are reused for. 0 disables
+// reusing them, concurrent probes of the same repository are still collapsed.
+func SetProbeCacheTTL(ttl time.Duration) {
+	probes.mu.Lock()
+	defer probes.mu.Unlock()
+	probes.ttl = ttl
+	probes.entries = map[string]probeEntry{}
+	probeCacheTTL.Set(ttl.Seconds())
+}
+
+// do returns the cached result of the probe identified by key, or runs probe
+// and caches its result when it succeeds. Errors are never cached.
+//
+// A probe shared by concurrent callers runs on a context detached from theirs
+// and bounded by timeout, 0 leaving it unbounded, so that a caller giving up
+// does not fail the others. The caller gives up waiting when ctx is done.
+func (c *probeCache) do(
+	ctx context.Context, key string, timeout time.Duration, probe func(ctx context.Context) (any, error),
+) (any, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && c.now().Before(entry.expires) {
+		c.mu.Unlock()
+		probeCacheRequests.WithLabelValues("hit").Inc()
+		return entry.value, nil
+	}
+	delete(c.entries, key)
+	c.mu.Unlock()
+
+	results := c.group.DoChan(key, func() (any, error) {
+		probeCtx := context.WithoutCancel(ctx)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			probeCtx, cancel = context.WithTimeout(probeCtx, timeout)
+			defer cancel()
+		}
+		value, err := probe(probeCtx)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.ttl > 0 {
+			now := c.now()
+			c.evict(now)
+			c.entries[key] = probeEntry{value: value, expires: now.Add(c.ttl)}
+		}
+		return value, nil
+	})
+	select {
+	case result := <-results:
+		if result.Shared {
+			probeCacheRequests.WithLabelValues("shared").Inc()
+		} else {
+			probeCacheRequests.WithLabelValues("miss").Inc()
+		}
+		return result.Val, result.Err
+	case <-ctx.Done():
+		return nil, transportError(ctx.Err())
+	}
+}
+
+// evict drops the expired entries, at most once per TTL, then the entry
+// expiring first if maxProbeEntries are still kept. The caller holds c.mu.
+func (c *probeCache) evict(now time.Time) {
+	if now.Sub(c.swept) >= c.ttl {
+		c.swept = now
+		for key, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+	}
+	if len(c.entries) < maxProbeEntries {
+		return
+	}
+	var first string
+	for key, entry := range c.entries {
+		if first == "" || entry.expires.Before(c.entries[first].expires) {
+			first = key
+		}
+	}
+	delete(c.entries, first)
+}
+
+// forget drops the results of the probes whose key starts with prefix.
+func (c *probeCache) forget(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// conditionalResponse is a response kept to revalidate it with If-None-Match.
+type conditionalResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// conditionalTransport sends GET requests with the ETag of the previous
+// response to the same URL. A 304 Not Modified, which does not count against
+// the Github rate limit, is answered with the kept response. One transport is
+// used per host and credentials, so responses are never shared across users.
+type conditionalTransport struct {
+	base http.RoundTripper
+
+	mu        sync.Mutex
+	responses map[string]conditionalResponse
+}
+
+func newConditionalTransport(base http.RoundTripper) *conditionalTransport {
+	return &conditionalTransport{base: base, responses: map[string]conditionalResponse{}}
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String()
+	t.mu.Lock()
+	cached, ok := t.responses[key]
+	t.mu.Unlock()
+	if ok {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	switch {
+	case ok && resp.StatusCode == http.StatusNotModified:
+		conditionalRequests.WithLabelValues("not_modified").Inc()
+		resp.Body.Close()
+		header := cached.header.Clone()
+		for name, values := range resp.Header {
+			// Fresh headers, like the remaining rate limit, win over the kept ones.
+			header[name] = values
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)),
+			Request:       req,
+		}, nil
+	case ok:
+		conditionalRequests.WithLabelValues("modified").Inc()
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	t.mu.Lock()
+	if _, exists := t.responses[key]; !exists && len(t.responses) >= maxConditionalEntries {
+		for evicted := range t.responses {
+			delete(t.responses, evicted)
+			break
+		}
+	}
+	t.responses[key] = conditionalResponse{etag: etag, header: resp.Header.Clone(), body: body}
+	t.mu.Unlock()
+	return resp, nil
+}
+
+const (
+	// transportIdleTTL is how long a shared transport is kept unused, e.g.
+	// once the credentials it was created for are rotated.
+	transportIdleTTL = time.Hour
+
+	// maxTransports bounds the number of shared transports, the least recently
+	// used one being dropped to make room for a new one.
+	maxTransports = 500
+)
+
+// sharedTransportEntry is a shared transport, and when it was last handed out.
+type sharedTransportEntry struct {
+	transport *conditionalTransport
+	lastUsed  time.Time
+}
+
+var (
+	transportsMu  sync.Mutex
+	transports    = map[string]*sharedTransportEntry{}
+	transportsNow = time.Now
+)
+
+// sharedTransport returns the conditional transport of key, kept across
+// GitService instances so that every reconcile benefits from the ETags. The
+// transports of a host are dropped when its TLS or proxy settings change, see
+// SetHostTransport and SetClusterProxy, and when they are idle, see
+// evictTransports.
+func sharedTransport(key string, opts ProviderOptions) *conditionalTransport {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	now := transportsNow()
+	entry, ok := transports[key]
+	if !ok {
+		evictTransports(now)
+		base := &retryTransport{timeout: opts.Timeout, retries: opts.Retries, base: hostTransport(opts.Host)}
+		entry = &sharedTransportEntry{transport: newConditionalTransport(base)}
+		transports[key] = entry
+	}
+	entry.lastUsed = now
+	return entry.transport
+}
+
+// evictTransports drops the transports unused for transportIdleTTL then, if
+// maxTransports are still kept, the least recently used one. The caller holds
+// transportsMu.
+func evictTransports(now time.Time) {
+	var oldest string
+	for key, entry := range transports {
+		if now.Sub(entry.lastUsed) > transportIdleTTL {
+			delete(transports, key)
+			continue
+		}
+		if oldest == "" || entry.lastUsed.Before(transports[oldest].lastUsed) {
+			oldest = key
+		}
+	}
+	if len(transports) >= maxTransports {
+		delete(transports, oldest)
+	}
+}
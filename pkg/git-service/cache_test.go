@@ -0,0 +1,236 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cache := newProbeCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	calls := 0
+	probe := func(context.Context) (any, error) {
+		calls++
+		return calls, nil
+	}
+
+	hits := testutil.ToFloat64(probeCacheRequests.WithLabelValues("hit"))
+	value, err := cache.do(ctx, "key", 0, probe)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	now = now.Add(59 * time.Second)
+	value, _ = cache.do(ctx, "key", 0, probe)
+	assert.Equal(t, 1, value, "within the TTL")
+	assert.Equal(t, hits+1, testutil.ToFloat64(probeCacheRequests.WithLabelValues("hit")))
+
+	value, _ = cache.do(ctx, "other", 0, probe)
+	assert.Equal(t, 2, value, "other key")
+
+	now = now.Add(time.Second)
+	value, _ = cache.do(ctx, "key", 0, probe)
+	assert.Equal(t, 3, value, "expired")
+}
+
+func TestProbeCacheErrorsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	cache := newProbeCache(time.Minute)
+	_, err := cache.do(ctx, "key", 0, func(context.Context) (any, error) { return nil, errors.New("boom") })
+	assert.Error(t, err)
+	value, err := cache.do(ctx, "key", 0, func(context.Context) (any, error) { return "ok", nil })
+	require.NoError(t, err)
+	assert.Equal(t, "ok", value)
+}
+
+func TestProbeCacheCollapsesConcurrentProbes(t *testing.T) {
+	ctx := context.Background()
+	cache := newProbeCache(0)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	probe := func(context.Context) (any, error) {
+		calls.Add(1)
+		<-release
+		return "main", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]any, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = cache.do(ctx, "key", 0, probe)
+		}()
+	}
+	// Leaving the goroutines time to join the in-flight probe
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, result := range results {
+		assert.Equal(t, "main", result)
+	}
+}
+
+func TestProbeCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cache := newProbeCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	probe := func(context.Context) (any, error) { return "main", nil }
+
+	_, _ = cache.do(ctx, "expired", 0, probe)
+	now = now.Add(time.Minute)
+	_, _ = cache.do(ctx, "key", 0, probe)
+	assert.NotContains(t, cache.entries, "expired", "expired entries are swept")
+
+	for i := len(cache.entries); i < maxProbeEntries; i++ {
+		now = now.Add(time.Millisecond)
+		cache.entries[fmt.Sprintf("key-%d", i)] = probeEntry{value: "main", expires: now.Add(time.Minute)}
+	}
+	_, _ = cache.do(ctx, "new", 0, probe)
+	assert.Len(t, cache.entries, maxProbeEntries)
+	assert.NotContains(t, cache.entries, "key", "the entry expiring first is dropped")
+	assert.Contains(t, cache.entries, "new")
+}
+
+func TestProbeCacheDetachesSharedProbes(t *testing.T) {
+	cache := newProbeCache(0)
+	started, release := make(chan struct{}), make(chan struct{})
+	probe := func(ctx context.Context) (any, error) {
+		close(started)
+		select {
+		case <-release:
+			return "main", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.do(first, "key", time.Minute, probe)
+		firstErr <- err
+	}()
+	<-started
+	second := make(chan any, 1)
+	go func() {
+		value, _ := cache.do(context.Background(), "key", time.Minute, probe)
+		second <- value
+	}()
+	// Leaving the second caller time to join the in-flight probe
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled, "the first caller gives up")
+	close(release)
+	assert.Equal(t, "main", <-second, "the shared probe outlives the first caller")
+
+	_, err := cache.do(context.Background(), "other", 10*time.Millisecond, func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the shared probe is bounded by the timeout")
+}
+
+func TestConditionalTransport(t *testing.T) {
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"name":"main"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newConditionalTransport(http.DefaultTransport)}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL + "/repos/hello/world/branches/main")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `{"name":"main"}`, string(body))
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "4999", resp.Header.Get("X-RateLimit-Remaining"))
+	}
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, int32(2), notModified.Load())
+}
+
+func TestSetProbeCacheTTL(t *testing.T) {
+	t.Cleanup(func() { SetProbeCacheTTL(DefaultProbeCacheTTL) })
+	SetProbeCacheTTL(2 * time.Minute)
+	assert.Equal(t, float64(120), testutil.ToFloat64(probeCacheTTL))
+}
+
+func TestProbeCacheForget(t *testing.T) {
+	ctx := context.Background()
+	cache := newProbeCache(time.Minute)
+	calls := 0
+	probe := func(context.Context) (any, error) {
+		calls++
+		return calls, nil
+	}
+	_, _ = cache.do(ctx, "github.com/hello/world#ref/main", 0, probe)
+	_, _ = cache.do(ctx, "github.com/hello/other#ref/main", 0, probe)
+
+	cache.forget("github.com/hello/world#")
+	value, _ := cache.do(ctx, "github.com/hello/world#ref/main", 0, probe)
+	assert.Equal(t, 3, value, "forgotten")
+	value, _ = cache.do(ctx, "github.com/hello/other#ref/main", 0, probe)
+	assert.Equal(t, 2, value, "other repository")
+}
+
+func TestSharedTransportEviction(t *testing.T) {
+	kept := transports
+	transports = map[string]*sharedTransportEntry{}
+	now := time.Now()
+	transportsNow = func() time.Time { return now }
+	t.Cleanup(func() {
+		transports = kept
+		transportsNow = time.Now
+	})
+	opts := ProviderOptions{Host: "git.example.com"}
+
+	first := sharedTransport("git.example.com/first", opts)
+	assert.Same(t, first, sharedTransport("git.example.com/first", opts), "the transport is shared")
+	now = now.Add(transportIdleTTL / 2)
+	sharedTransport("git.example.com/second", opts)
+	now = now.Add(transportIdleTTL/2 + time.Minute)
+	sharedTransport("git.example.com/third", opts)
+	assert.NotContains(t, transports, "git.example.com/first", "idle transports are dropped")
+	assert.Contains(t, transports, "git.example.com/second")
+
+	for i := len(transports); i < maxTransports; i++ {
+		now = now.Add(time.Second)
+		sharedTransport(fmt.Sprintf("git.example.com/%d", i), opts)
+	}
+	require.Len(t, transports, maxTransports)
+	sharedTransport("git.example.com/last", opts)
+	assert.Len(t, transports, maxTransports)
+	assert.NotContains(t, transports, "git.example.com/second", "the least recently used transport is dropped")
+	assert.Contains(t, transports, "git.example.com/third")
+}
@@ -0,0 +1,148 @@
+package gitservice
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// HostConfig maps a self-hosted Git host, such as a Github Enterprise Server or
+// a Gitlab self-managed instance, onto a registered provider type.
+type HostConfig struct {
+	// Host is the hostname used in Git URLs, e.g. "gitlab.example.com".
+	Host string `json:"host"`
+
+	// Type is the provider type serving the host, e.g. "github" or "gitlab".
+	Type GitProvider `json:"type"`
+
+	// APIURL is the base URL of the provider API. When empty, the provider
+	// derives its conventional endpoint from Host.
+	APIURL string `json:"apiURL,omitempty"`
+
+	// Timeout bounds each attempt of a request to the host. It defaults to
+	// DefaultRequestTimeout, 0 disables it.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Retries is the number of retries of a request failing transiently. It
+	// defaults to DefaultRequestRetries.
+	Retries *int `json:"retries,omitempty"`
+
+	// CABundle selects the key of a ConfigMap in the operator namespace holding
+	// the PEM certificates trusted for the host, in addition to the system ones.
+	CABundle *corev1.ConfigMapKeySelector `json:"caBundle,omitempty"`
+
+	// ClientCertificateSecret is a kubernetes.io/tls Secret in the operator
+	// namespace holding the client certificate presented to the host.
+	ClientCertificateSecret *corev1.LocalObjectReference `json:"clientCertificateSecret,omitempty"`
+
+	// Proxy overrides the cluster-wide proxy and the proxy environment
+	// variables for the host.
+	Proxy *ProxyConfig `json:"proxy,omitempty"`
+
+	// AllowInsecureCredentials sends the credentials of the source secrets to
+	// the plain http:// Git URLs of the host, where they can be eavesdropped.
+	// It is only meant for hosts reached over a trusted network.
+	AllowInsecureCredentials bool `json:"allowInsecureCredentials,omitempty"`
+}
+
+// requestTimeout returns the timeout of each attempt of a request to the host.
+func (h HostConfig) requestTimeout() time.Duration {
+	if h.Timeout == nil {
+		return DefaultRequestTimeout
+	}
+	return h.Timeout.Duration
+}
+
+// probeTimeout bounds a probe of a repository on the host, which is detached
+// from the contexts of its callers: the attempts of a request and the backoff
+// between them. It is 0, unbounded, when requests have no timeout.
+func (h HostConfig) probeTimeout() time.Duration {
+	timeout, retries := h.requestTimeout(), h.requestRetries()
+	if timeout == 0 {
+		return 0
+	}
+	return time.Duration(retries+1)*timeout + retryBaseDelay<<retries
+}
+
+// hostProbeTimeout returns the probeTimeout of host, or the default one of
+// the hosts without configuration.
+func hostProbeTimeout(host string) time.Duration {
+	config, _ := hostConfigFor(host)
+	return config.probeTimeout()
+}
+
+// requestRetries returns the number of retries of a request to the host.
+func (h HostConfig) requestRetries() int {
+	if h.Retries == nil {
+		return DefaultRequestRetries
+	}
+	return *h.Retries
+}
+
+// Config is the operator-level configuration of GitService.
+type Config struct {
+	// Hosts lists the self-hosted Git hosts known to the operator.
+	Hosts []HostConfig `json:"hosts,omitempty"`
+}
+
+// LoadConfig reads a YAML or JSON Config from path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("cannot parse Git configuration %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Register registers every host of the configuration.
+func (c *Config) Register() error {
+	for _, host := range c.Hosts {
+		if err := RegisterHost(host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterHost associates a self-hosted Git host with its provider type and API URL.
+func RegisterHost(host HostConfig) error {
+	if host.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if _, ok := lookupProvider(host.Type); !ok {
+		return fmt.Errorf("host %s: unsupported Git type %q", host.Host, host.Type)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name := normalizeHost(host.Host)
+	hostTypes[name] = host.Type
+	hostConfigs[name] = host
+	return nil
+}
+
+// UnregisterHost removes the host added by RegisterHost, and forgets the
+// probe results of its repositories.
+func UnregisterHost(host string) {
+	name := normalizeHost(host)
+	registryMu.Lock()
+	delete(hostTypes, name)
+	delete(hostConfigs, name)
+	registryMu.Unlock()
+	probes.forget(name + "/")
+}
+
+// hostConfigFor returns the configuration registered for host, if any.
+func hostConfigFor(host string) (HostConfig, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	config, ok := hostConfigs[normalizeHost(host)]
+	return config, ok
+}
@@ -0,0 +1,99 @@
+package gitservice
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "git.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+hosts:
+  - host: gitlab.example.com
+    type: gitlab
+  - host: github.example.com
+    type: github
+    apiURL: https://github.example.com/api/v3/
+    timeout: 30s
+    retries: 0
+`), 0o600))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	retries := 0
+	assert.Equal(t, []HostConfig{
+		{Host: "gitlab.example.com", Type: Gitlab},
+		{Host: "github.example.com", Type: Github, APIURL: "https://github.example.com/api/v3/",
+			Timeout: &metav1.Duration{Duration: 30 * time.Second}, Retries: &retries},
+	}, config.Hosts)
+
+	assert.Equal(t, DefaultRequestTimeout, config.Hosts[0].requestTimeout())
+	assert.Equal(t, DefaultRequestRetries, config.Hosts[0].requestRetries())
+	assert.Equal(t, 30*time.Second, config.Hosts[1].requestTimeout())
+	assert.Equal(t, 0, config.Hosts[1].requestRetries())
+}
+
+func TestLoadConfigTransport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "git.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+hosts:
+  - host: gitlab.example.com
+    type: gitlab
+    caBundle:
+      name: corporate-ca
+      key: ca-bundle.crt
+    clientCertificateSecret:
+      name: gitlab-client
+    proxy:
+      httpsProxy: http://proxy.example.com:3128
+      noProxy: .internal.example.com
+`), 0o600))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []HostConfig{{
+		Host: "gitlab.example.com",
+		Type: Gitlab,
+		CABundle: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "corporate-ca"},
+			Key:                  "ca-bundle.crt",
+		},
+		ClientCertificateSecret: &corev1.LocalObjectReference{Name: "gitlab-client"},
+		Proxy:                   &ProxyConfig{HTTPSProxy: "http://proxy.example.com:3128", NoProxy: ".internal.example.com"},
+	}}, config.Hosts)
+}
+
+func TestLoadConfigRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "git.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("hosts:\n  - hostname: gitlab.example.com\n"), 0o600))
+
+	_, err := LoadConfig(path)
+	assert.Error(t, err)
+}
+
+func TestRegisterHostRejectsUnknownType(t *testing.T) {
+	err := RegisterHost(HostConfig{Host: "git.example.com", Type: "svn"})
+	assert.Error(t, err)
+	assert.Equal(t, Unknown, identifyGitType("https://git.example.com/hello/world"))
+}
+
+func TestUnregisterHost(t *testing.T) {
+	require.NoError(t, RegisterHost(HostConfig{Host: "git.unregister.example.com", Type: Gitea}))
+	assert.Equal(t, Gitea, identifyGitType("https://git.unregister.example.com/hello/world"))
+	_, _ = probes.do(context.Background(), "git.unregister.example.com//hello/world#archived", 0,
+		func(context.Context) (any, error) { return false, nil })
+
+	UnregisterHost("git.unregister.example.com")
+	assert.Equal(t, Unknown, identifyGitType("https://git.unregister.example.com/hello/world"))
+	probes.mu.Lock()
+	defer probes.mu.Unlock()
+	assert.NotContains(t, probes.entries, "git.unregister.example.com//hello/world#archived")
+}
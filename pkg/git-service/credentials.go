@@ -0,0 +1,133 @@
+package gitservice
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// SecretTokenKey is the key of an access token in an Opaque source secret.
+	SecretTokenKey = "token"
+
+	// SecretKnownHostsKey is the key of the known_hosts entries in a source
+	// secret holding an SSH private key, as used by OpenShift builds.
+	SecretKnownHostsKey = "known_hosts"
+)
+
+// Credentials are used to authenticate against the Git provider.
+type Credentials struct {
+	// Token is an access token, sent the way the provider expects tokens.
+	Token string
+
+	// Username and Password are used for HTTP basic authentication.
+	Username string
+	Password string
+
+	// SSHPrivateKey is the PEM encoded private key used for Git SSH URLs.
+	SSHPrivateKey []byte
+
+	// KnownHosts holds the known_hosts entries the SSH host key is verified against.
+	KnownHosts []byte
+
+	// GithubApp is a Github App installation whose tokens authenticate the
+	// requests to Github.
+	GithubApp *GithubApp
+}
+
+// CredentialsFromSecret builds the credentials held by a source secret:
+//
+//   - kubernetes.io/basic-auth secrets provide a username and password. Without
+//     username, the password is used as an access token.
+//   - kubernetes.io/ssh-auth secrets provide an SSH private key and, optionally,
+//     known_hosts entries.
+//   - Opaque secrets provide a Github App, a "token", an "ssh-privatekey" or a
+//     "password" with an optional "username", looked up in this order. A Github
+//     App is made of a "github-app-id", a "github-app-installation-id" and a
+//     "github-app-private-key", and only authenticates to Github.
+//
+// The returned error carries ReasonSecretKeyMissing when a required key is
+// missing, ReasonInvalidSecretKey when a Github App key is malformed, or
+// ReasonUnsupportedSecretType for any other secret type.
+func CredentialsFromSecret(secret *corev1.Secret) (Credentials, error) {
+	switch secret.Type {
+	case corev1.SecretTypeBasicAuth:
+		if len(secret.Data[corev1.BasicAuthPasswordKey]) == 0 {
+			return Credentials{}, missingKeyError(secret, corev1.BasicAuthPasswordKey)
+		}
+		return basicAuthCredentials(secret), nil
+	case corev1.SecretTypeSSHAuth:
+		if len(secret.Data[corev1.SSHAuthPrivateKey]) == 0 {
+			return Credentials{}, missingKeyError(secret, corev1.SSHAuthPrivateKey)
+		}
+		return sshCredentials(secret), nil
+	case corev1.SecretTypeOpaque, "":
+		switch {
+		case hasGithubAppKey(secret):
+			return githubAppCredentials(secret)
+		case len(secret.Data[SecretTokenKey]) > 0:
+			return Credentials{Token: string(secret.Data[SecretTokenKey])}, nil
+		case len(secret.Data[corev1.SSHAuthPrivateKey]) > 0:
+			return sshCredentials(secret), nil
+		case len(secret.Data[corev1.BasicAuthPasswordKey]) > 0:
+			return basicAuthCredentials(secret), nil
+		}
+		return Credentials{}, newError(ReasonSecretKeyMissing, fmt.Errorf(
+			"secret %q of type %s must have a %q, %q or %q key", secret.Name, corev1.SecretTypeOpaque,
+			SecretTokenKey, corev1.SSHAuthPrivateKey, corev1.BasicAuthPasswordKey))
+	default:
+		return Credentials{}, newError(ReasonUnsupportedSecretType, fmt.Errorf(
+			"secret %q has unsupported type %s, use %s, %s or %s", secret.Name, secret.Type,
+			corev1.SecretTypeBasicAuth, corev1.SecretTypeSSHAuth, corev1.SecretTypeOpaque))
+	}
+}
+
+func basicAuthCredentials(secret *corev1.Secret) Credentials {
+	username := string(secret.Data[corev1.BasicAuthUsernameKey])
+	password := string(secret.Data[corev1.BasicAuthPasswordKey])
+	if username == "" {
+		return Credentials{Token: password}
+	}
+	return Credentials{Username: username, Password: password}
+}
+
+func sshCredentials(secret *corev1.Secret) Credentials {
+	return Credentials{
+		SSHPrivateKey: secret.Data[corev1.SSHAuthPrivateKey],
+		KnownHosts:    secret.Data[SecretKnownHostsKey],
+	}
+}
+
+func missingKeyError(secret *corev1.Secret, key string) error {
+	return newError(ReasonSecretKeyMissing,
+		fmt.Errorf("secret %q of type %s is missing the %q key", secret.Name, secret.Type, key))
+}
+
+// accessToken returns the token for APIs that only accept tokens. The password
+// is assumed to be a personal access token when no token is set.
+func (c Credentials) accessToken() string {
+	if c.Token != "" {
+		return c.Token
+	}
+	return c.Password
+}
+
+// authorization returns the value of the Authorization header for the
+// credentials, sending tokens with the given scheme, e.g. "Bearer".
+func (c Credentials) authorization(tokenScheme string) string {
+	switch {
+	case c.Token != "":
+		return tokenScheme + " " + c.Token
+	case c.Password != "":
+		return basicAuth(c.Username, c.Password)
+	default:
+		return ""
+	}
+}
+
+// setAuthorization adds the Authorization header for the credentials to client.
+func (c Credentials) setAuthorization(client *apiClient, tokenScheme string) {
+	if auth := c.authorization(tokenScheme); auth != "" {
+		client.header.Set("Authorization", auth)
+	}
+}
@@ -0,0 +1,91 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DeploymentState is the state of a deployment record.
+type DeploymentState string
+
+const (
+	// DeploymentStateInProgress reports that the commit is being deployed.
+	DeploymentStateInProgress DeploymentState = "in_progress"
+
+	// DeploymentStateSuccess reports that the commit is deployed.
+	DeploymentStateSuccess DeploymentState = "success"
+
+	// DeploymentStateFailure reports that the commit could not be deployed.
+	DeploymentStateFailure DeploymentState = "failure"
+)
+
+// Deployment is a deployment record of a commit to an environment, shown in
+// the Deployments view of Github or the Environments view of Gitlab.
+type Deployment struct {
+	// Ref is the branch or tag the commit was deployed from.
+	Ref string
+
+	// Tag tells whether Ref is a tag.
+	Tag bool
+
+	// SHA is the deployed commit.
+	SHA string
+
+	// Environment is the name of the environment the commit is deployed to.
+	Environment string
+}
+
+// DeploymentStatus is the state of a deployment record.
+type DeploymentStatus struct {
+	// State is the state of the deployment.
+	State DeploymentState
+
+	// Description is a short summary of the state.
+	Description string
+
+	// EnvironmentURL is the URL the deployed application is served at, if any.
+	EnvironmentURL string
+}
+
+// DeploymentProvider is implemented by the providers keeping deployment
+// records. Deployment IDs are opaque strings, whatever the provider uses.
+type DeploymentProvider interface {
+	// CreateDeployment creates a deployment record and returns its ID.
+	CreateDeployment(ctx context.Context, repo *Repository, deployment Deployment) (string, error)
+
+	// UpdateDeployment sets the state of the deployment with id. A missing
+	// deployment is reported with ReasonDeploymentNotFound.
+	UpdateDeployment(ctx context.Context, repo *Repository, id string, status DeploymentStatus) error
+}
+
+// ReportDeployment sets the state of the deployment record with id, or creates
+// it when id is empty or the record was deleted on the provider. It returns
+// the ID of the deployment record.
+func (g *GitService) ReportDeployment(
+	ctx context.Context, id string, deployment Deployment, status DeploymentStatus,
+) (string, error) {
+	if g.provider == nil {
+		return "", newError(g.reason, errors.New("the Git URL cannot be used"))
+	}
+	provider, ok := g.provider.(DeploymentProvider)
+	if !ok {
+		return "", newError(ReasonUnsupportedGitType, fmt.Errorf("%s repositories have no deployments", g.gitType))
+	}
+	if runes := []rune(status.Description); len(runes) > maxStatusDescription {
+		status.Description = string(runes[:maxStatusDescription-1]) + "…"
+	}
+	if id != "" {
+		err := provider.UpdateDeployment(ctx, g.repository, id, status)
+		if err == nil || ReasonForError(err) != ReasonDeploymentNotFound {
+			return id, err
+		}
+		g.logger.Info("Deployment deleted on the Git provider, creating it again", "id", id)
+	}
+	id, err := provider.CreateDeployment(ctx, g.repository, deployment)
+	if err != nil {
+		return "", err
+	}
+	g.logger.Info("Created deployment", "id", id, "environment", deployment.Environment)
+	return id, provider.UpdateDeployment(ctx, g.repository, id, status)
+}
@@ -0,0 +1,174 @@
+package gitservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDeployments is a fake of the deployments API of Github and Gitlab for
+// the hello/world repository.
+type fakeDeployments struct {
+	mu          sync.Mutex
+	next        int
+	deployments map[string]map[string]any
+	// states are the states set on each deployment, in order.
+	states map[string][]string
+	// environmentURL is the URL of the Gitlab environment.
+	environmentURL string
+}
+
+func newFakeDeployments() *fakeDeployments {
+	return &fakeDeployments{deployments: map[string]map[string]any{}, states: map[string][]string{}}
+}
+
+func (f *fakeDeployments) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body := map[string]any{}
+	if r.Method != http.MethodGet {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodPost &&
+		(path == "/api/v3/repos/hello/world/deployments" || path == "/api/v4/projects/hello/world/deployments"):
+		f.next++
+		id := strconv.Itoa(f.next)
+		body["id"] = f.next
+		f.deployments[id] = body
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]any{"id": f.next})
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/api/v3/repos/hello/world/deployments/"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v3/repos/hello/world/deployments/"), "/statuses")
+		if f.deployments[id] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.states[id] = append(f.states[id], body["state"].(string))
+		f.deployments[id]["environment_url"] = body["environment_url"]
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, body)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/api/v4/projects/hello/world/deployments/"):
+		id := strings.TrimPrefix(path, "/api/v4/projects/hello/world/deployments/")
+		if f.deployments[id] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]string{"message": "404 Not found"})
+			return
+		}
+		f.states[id] = append(f.states[id], body["status"].(string))
+		writeJSON(w, map[string]any{"id": f.deployments[id]["id"], "environment": map[string]any{
+			"id": 7, "name": f.deployments[id]["environment"], "external_url": f.environmentURL,
+		}})
+	case r.Method == http.MethodPut && path == "/api/v4/projects/hello/world/environments/7":
+		f.environmentURL = body["external_url"].(string)
+		writeJSON(w, map[string]any{"id": 7, "external_url": f.environmentURL})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestDeploymentProviders(t *testing.T) {
+	tests := []struct {
+		name string
+		// newProvider returns the provider under test talking to apiURL.
+		newProvider func(apiURL string) (Provider, error)
+		// wantStates are the states of an in progress then successful deployment on the provider.
+		wantStates []string
+		// environmentURL returns the environment URL of the deployment as stored by the fake.
+		environmentURL func(f *fakeDeployments, id string) any
+	}{
+		{
+			name: "Github",
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGithubProvider(ProviderOptions{APIURL: apiURL + "/api/v3/", Credentials: testCredentials,
+					Logger: testLogger})
+			},
+			wantStates: []string{"in_progress", "success"},
+			environmentURL: func(f *fakeDeployments, id string) any {
+				return f.deployments[id]["environment_url"]
+			},
+		},
+		{
+			name: "Gitlab",
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGitlabProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials, Logger: testLogger})
+			},
+			wantStates: []string{"running", "success"},
+			environmentURL: func(f *fakeDeployments, _ string) any {
+				return f.environmentURL
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			deployments := newFakeDeployments()
+			server := httptest.NewServer(deployments)
+			t.Cleanup(server.Close)
+			p, err := tt.newProvider(server.URL)
+			require.NoError(t, err)
+			provider, ok := p.(DeploymentProvider)
+			require.True(t, ok)
+			repo := &Repository{Owner: conformanceOwner, Name: conformanceName}
+
+			id, err := provider.CreateDeployment(ctx, repo, Deployment{
+				Ref: conformanceBranch, SHA: statusSHA, Environment: "hello-dev",
+			})
+			require.NoError(t, err)
+			require.Contains(t, deployments.deployments, id)
+			assert.Equal(t, "hello-dev", deployments.deployments[id]["environment"])
+
+			require.NoError(t, provider.UpdateDeployment(ctx, repo, id, DeploymentStatus{
+				State: DeploymentStateInProgress, Description: "Reconciling",
+			}))
+			require.NoError(t, provider.UpdateDeployment(ctx, repo, id, DeploymentStatus{
+				State: DeploymentStateSuccess, EnvironmentURL: "https://hello.apps.example.com",
+			}))
+			assert.Equal(t, tt.wantStates, deployments.states[id])
+			assert.Equal(t, "https://hello.apps.example.com", tt.environmentURL(deployments, id))
+
+			err = provider.UpdateDeployment(ctx, repo, "42", DeploymentStatus{State: DeploymentStateFailure})
+			assert.Equal(t, ReasonDeploymentNotFound, ReasonForError(err))
+		})
+	}
+}
+
+func TestReportDeployment(t *testing.T) {
+	ctx := context.Background()
+	deployments := newFakeDeployments()
+	server := httptest.NewServer(deployments)
+	t.Cleanup(server.Close)
+	registerTestHost(t, HostConfig{Host: "github.example.com", Type: Github, APIURL: server.URL + "/api/v3/"})
+	gs := New("https://github.example.com/hello/world", conformanceBranch, testCredentials, testLogger)
+	deployment := Deployment{Ref: conformanceBranch, SHA: statusSHA, Environment: "hello-dev"}
+
+	id, err := gs.ReportDeployment(ctx, "", deployment, DeploymentStatus{State: DeploymentStateInProgress})
+	require.NoError(t, err)
+	same, err := gs.ReportDeployment(ctx, id, deployment, DeploymentStatus{State: DeploymentStateFailure})
+	require.NoError(t, err)
+	assert.Equal(t, id, same, "updated")
+	assert.Equal(t, []string{"in_progress", "failure"}, deployments.states[id])
+
+	delete(deployments.deployments, id)
+	recreated, err := gs.ReportDeployment(ctx, id, deployment, DeploymentStatus{State: DeploymentStateSuccess})
+	require.NoError(t, err)
+	assert.NotEqual(t, id, recreated, "created again")
+	assert.Equal(t, []string{"success"}, deployments.states[recreated])
+}
+
+func TestReportDeploymentUnsupported(t *testing.T) {
+	server := newFakeSmartHTTPServer(t)
+	gs := New(server.URL+"/hello/world.git", conformanceBranch, testCredentials, testLogger)
+	_, err := gs.ReportDeployment(context.Background(), "", Deployment{SHA: statusSHA},
+		DeploymentStatus{State: DeploymentStateInProgress})
+	assert.Equal(t, ReasonUnsupportedGitType, ReasonForError(err))
+}
@@ -0,0 +1,147 @@
+package gitservice
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Error is returned by providers when a repository, reference or file cannot be
+// accessed. Reason is the condition reason reported for the failure.
+type Error struct {
+	Reason GitConditionReason
+	Err    error
+
+	// RetryAt is when a rate limited request can be retried.
+	RetryAt time.Time
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Reason.String()
+	}
+	return e.Reason.String() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError wraps err with the given reason.
+func newError(reason GitConditionReason, err error) error {
+	return &Error{Reason: reason, Err: err}
+}
+
+// ReasonForError returns the reason carried by err, or ReasonRepoNotReachable
+// if err was not returned by a provider.
+func ReasonForError(err error) GitConditionReason {
+	var gitErr *Error
+	if errors.As(err, &gitErr) {
+		return gitErr.Reason
+	}
+	return ReasonRepoNotReachable
+}
+
+// RetryAfter returns how long to wait before retrying the request that failed
+// with err, or zero if err is not a rate limit error.
+func RetryAfter(err error, now time.Time) time.Duration {
+	var gitErr *Error
+	if !errors.As(err, &gitErr) || gitErr.Reason != ReasonRateLimitExceeded {
+		return 0
+	}
+	if gitErr.RetryAt.IsZero() {
+		return defaultRetryAfter
+	}
+	return max(gitErr.RetryAt.Sub(now), 0)
+}
+
+// remediationHints tell users how to fix the cause of a failure.
+var remediationHints = map[GitConditionReason]string{
+	ReasonInvalidGitURL: "Check the Git URL, e.g. https://github.com/owner/repo.",
+	ReasonRepoNotFound: "Check the Git URL, and that the reference exists in the repository. " +
+		"Private repositories are not found without credentials either: " +
+		"add a source secret with credentials allowed to read it.",
+	ReasonPrivateRepo:         "The repository is private: add a source secret with credentials allowed to read it.",
+	ReasonRepoArchived:        "The repository is archived and read-only: unarchive it or use another repository.",
+	ReasonAccessTokenRequired: "Add a source secret with an access token allowed to read the repository.",
+	ReasonAuthenticationFailed: "The credentials of the source secret were rejected: " +
+		"check they are valid and have not expired.",
+	ReasonForbidden: "The credentials of the source secret are not allowed to access the repository: " +
+		"grant them read access to its contents.",
+	ReasonRateLimitExceeded: "The Git API rate limit is exhausted: the repository is probed again when it resets. " +
+		"Authenticated requests get a higher limit.",
+	ReasonServerError: "The Git server failed to answer: the repository is probed again later.",
+	ReasonDNSLookupFailed: "The Git host name cannot be resolved: " +
+		"check the Git URL and the DNS configuration of the cluster.",
+	ReasonTLSError: "The TLS certificate of the Git host cannot be verified: " +
+		"check the host serves a certificate signed by a trusted authority.",
+	ReasonTimeout: "The Git host did not answer in time: the repository is probed again later. " +
+		"Check the host is reachable from the cluster.",
+	ReasonRepoNotReachable: "Check the Git host is reachable from the cluster.",
+	ReasonSSHKeyRequired: "Add a kubernetes.io/ssh-auth source secret with an SSH private key " +
+		"allowed to read the repository.",
+	ReasonHostKeyVerificationFailed: "Add the SSH host key of the Git host to the known_hosts key of the source secret.",
+	ReasonSecretKeyMissing:          "Add the missing key to the source secret.",
+	ReasonInvalidSecretKey:          "Fix the malformed value of the source secret.",
+	ReasonUnsupportedSecretType:     "Use a kubernetes.io/basic-auth, kubernetes.io/ssh-auth or Opaque source secret.",
+	ReasonUnsupportedGitType:        "The Git server does not support this operation.",
+	ReasonCommitNotVerified:         "Reference a branch or tag pointing at the commit to have it verified.",
+	ReasonInsecureCredentials: "Use an https:// Git URL, or set allowInsecureCredentials on the host " +
+		"in the Git configuration file if it is reached over a trusted network.",
+}
+
+// RemediationHint returns how to fix the failure reported with reason, or the
+// empty string if there is nothing to fix.
+func RemediationHint(reason GitConditionReason) string {
+	return remediationHints[reason]
+}
+
+// IsTransient reports whether a failure reported with reason may go away by
+// itself, so that the request should be retried later.
+func IsTransient(reason GitConditionReason) bool {
+	return reason == ReasonServerError || reason == ReasonTimeout
+}
+
+// transportError maps an HTTP or SSH request that got no response onto a
+// GitConditionReason. Errors already carrying a reason, like the failure to
+// mint a Github App installation token, keep it.
+func transportError(err error) error {
+	var gitErr *Error
+	if errors.As(err, &gitErr) {
+		return err
+	}
+	var (
+		dnsErr           *net.DNSError
+		verificationErr  *tls.CertificateVerificationError
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidCert      x509.CertificateInvalidError
+		recordHeaderErr  tls.RecordHeaderError
+		netErr           net.Error
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return newError(ReasonDNSLookupFailed, err)
+	case errors.As(err, &verificationErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidCert), errors.As(err, &recordHeaderErr):
+		return newError(ReasonTLSError, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return newError(ReasonTimeout, err)
+	default:
+		return newError(ReasonRepoNotReachable, err)
+	}
+}
+
+// isAuthenticated reports whether req carried credentials, in the
+// Authorization header or the token headers of Gitlab.
+func isAuthenticated(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	return req.Header.Get("Authorization") != "" || req.Header.Get("Private-Token") != "" ||
+		req.Header.Get("Job-Token") != ""
+}
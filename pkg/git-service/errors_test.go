@@ -0,0 +1,113 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorReasons(t *testing.T) {
+	authenticated := &http.Request{Header: http.Header{"Authorization": {"Bearer s3cr3t"}}}
+	gitlabToken := &http.Request{Header: http.Header{"Private-Token": {"s3cr3t"}}}
+	anonymous := &http.Request{Header: http.Header{}}
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		err    error
+		reason GitConditionReason
+	}{
+		{"Anonymous unauthorized", &http.Response{StatusCode: 401, Request: anonymous}, assert.AnError,
+			ReasonAccessTokenRequired},
+		{"Rejected credentials", &http.Response{StatusCode: 401, Request: authenticated}, assert.AnError,
+			ReasonAuthenticationFailed},
+		{"Rejected Gitlab token", &http.Response{StatusCode: 401, Request: gitlabToken}, assert.AnError,
+			ReasonAuthenticationFailed},
+		{"Forbidden", &http.Response{StatusCode: 403, Request: authenticated}, assert.AnError, ReasonForbidden},
+		{"Anonymous forbidden", &http.Response{StatusCode: 403, Request: anonymous}, assert.AnError, ReasonPrivateRepo},
+		{"Archived", &http.Response{StatusCode: 403, Request: authenticated},
+			errors.New("403 Repository was archived so is read-only."), ReasonRepoArchived},
+		{"Rate limited", &http.Response{StatusCode: 403, Header: http.Header{"X-Ratelimit-Remaining": {"0"}}},
+			assert.AnError, ReasonRateLimitExceeded},
+		{"Not found", &http.Response{StatusCode: 404}, assert.AnError, ReasonFileNotFound},
+		{"Server error", &http.Response{StatusCode: 502}, assert.AnError, ReasonServerError},
+		{"Other status", &http.Response{StatusCode: 418}, assert.AnError, ReasonRepoNotReachable},
+		{"DNS failure", nil, &net.DNSError{Err: "no such host", Name: "git.invalid", IsNotFound: true},
+			ReasonDNSLookupFailed},
+		{"Deadline exceeded", nil, context.DeadlineExceeded, ReasonTimeout},
+		{"Connection refused", nil, errors.New("connection refused"), ReasonRepoNotReachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.resp != nil && tt.resp.Header == nil {
+				tt.resp.Header = http.Header{}
+			}
+			err := apiError(tt.resp, tt.err, ReasonFileNotFound)
+			assert.Equal(t, tt.reason, ReasonForError(err))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	t.Run("TLS", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		t.Cleanup(server.Close)
+		// The certificate of the test server is not trusted by the default client.
+		_, err := newAPIClient(server.URL, nil).get(context.Background(), "/", nil, nil)
+		assert.Equal(t, ReasonTLSError, ReasonForError(transportError(err)))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(server.Close)
+		client := newAPIClient(server.URL, &http.Client{Timeout: 50 * time.Millisecond})
+		_, err := client.get(context.Background(), "/", nil, nil)
+		assert.Equal(t, ReasonTimeout, ReasonForError(transportError(err)))
+	})
+}
+
+func TestPrivateRepo(t *testing.T) {
+	server := newFakeGiteaAPI(t)
+	registerTestHost(t, HostConfig{Host: "gitea.private.example.com", Type: Gitea, APIURL: server.URL + "/api/v1"})
+
+	// The fake API hides the repository from anonymous users behind a 404, so
+	// it cannot be told apart from a missing repository.
+	gs := New("https://gitea.private.example.com/hello/world", "", Credentials{}, testLogger)
+	_, reason := gs.IsRepoReachable(context.Background())
+	assert.Equal(t, ReasonRepoNotFound, reason)
+	assert.Contains(t, gs.Message(), "Private repositories are not found without credentials")
+
+	// A server refusing anonymous users the repository tells it is private.
+	forbidden := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(forbidden.Close)
+	registerTestHost(t, HostConfig{Host: "gitea.forbidden.example.com", Type: Gitea, APIURL: forbidden.URL + "/api/v1"})
+	gs = New("https://gitea.forbidden.example.com/hello/world", "", Credentials{}, testLogger)
+	_, reason = gs.IsRepoReachable(context.Background())
+	assert.Equal(t, ReasonPrivateRepo, reason)
+	assert.Contains(t, gs.Message(), RemediationHint(ReasonPrivateRepo))
+
+	// A missing reference of a reachable repository is not mistaken for a private repository.
+	gs = New("https://gitea.private.example.com/hello/world", "missing", testCredentials, testLogger)
+	_, reason = gs.IsRepoReachable(context.Background())
+	assert.Equal(t, ReasonRepoNotFound, reason)
+}
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t, "Forbidden. "+RemediationHint(ReasonForbidden), ErrorMessage(ReasonForbidden, nil))
+	assert.Equal(t, "boom. "+RemediationHint(ReasonServerError),
+		ErrorMessage(ReasonServerError, errors.New("boom")))
+
+	message := ErrorMessage(ReasonRepoNotReachable, errors.New(strings.Repeat("x", 2*maxMessageLength)))
+	assert.True(t, strings.HasPrefix(message, strings.Repeat("x", maxMessageLength)+"..."))
+}
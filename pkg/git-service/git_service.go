@@ -1,9 +1,14 @@
 package gitservice
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"path"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
@@ -13,27 +18,67 @@ import (
 type GitService struct {
 	GitURL      string
 	reference   string
-	secretValue string
+	credentials Credentials
 	gitType     GitProvider
-	owner       string
-	repo        string
-	logger      logr.Logger
-	status      metav1.ConditionStatus
-	reason      GitConditionReason
+	provider    Provider
+	repository  *Repository
+	ref         *Ref
+	err         error
+	budgetKey   string
+	// probeTimeout bounds the probes shared with other GitService instances.
+	probeTimeout time.Duration
+	logger       logr.Logger
+	status       metav1.ConditionStatus
+	reason       GitConditionReason
 }
 
-func New(gitURL, branch, secretValue string, logger logr.Logger) *GitService {
+func New(gitURL, branch string, credentials Credentials, logger logr.Logger) *GitService {
 	var status metav1.ConditionStatus = metav1.ConditionUnknown
 	var reason GitConditionReason = ReasonProcessing
-	gitType := identifyGitType(gitURL)
+	u, err := parseGitURL(gitURL)
+	if err != nil {
+		logger.Error(err, "Cannot parse gitURL")
+		return &GitService{
+			status: metav1.ConditionFalse,
+			reason: ReasonInvalidGitURL,
+		}
+	}
+	gitType := gitTypeForURL(u)
 	if gitType == Unknown {
-		logger.Error(errors.New(ReasonUnsupportedGitType.String()), "Unsupported Git Type")
+		// Hosts without a dedicated provider are probed through the Git HTTP protocol.
+		logger.Info("Unknown Git host, falling back to the Git HTTP protocol", "host", u.Host)
+		gitType = SmartHTTP
+	}
+	if credentials.GithubApp != nil && gitType != Github {
+		// Requests would otherwise be sent anonymously.
+		err := newError(ReasonUnsupportedSecretType,
+			fmt.Errorf("the credentials of a Github App only authenticate to Github, not to %s", gitURL))
+		logger.Error(err, "Cannot use the credentials", "gitType", gitType)
+		return &GitService{
+			err:    err,
+			status: metav1.ConditionFalse,
+			reason: ReasonUnsupportedSecretType,
+		}
+	}
+	host := normalizeHost(u.Host)
+	port := ""
+	if u.Scheme == "https" {
+		port = u.Port()
+	}
+	provider, err := newProvider(gitType, ProviderOptions{
+		Host:        host,
+		Port:        port,
+		Credentials: credentials,
+		Logger:      logger,
+	})
+	if err != nil {
+		logger.Error(err, "Cannot create Git provider", "gitType", gitType)
 		return &GitService{
 			status: metav1.ConditionFalse,
-			reason: ReasonUnsupportedGitType,
+			reason: ReasonRepoNotReachable,
 		}
 	}
-	owner, repo, err := getOwnerAndRepo(gitURL)
+	repository, err := provider.ParseURL(u)
 	if err != nil {
 		logger.Error(err, "Cannot get owner and repo from gitURL")
 		return &GitService{
@@ -43,62 +88,317 @@ func New(gitURL, branch, secretValue string, logger logr.Logger) *GitService {
 	}
 
 	return &GitService{
-		GitURL:      gitURL,
-		reference:   branch,
-		gitType:     gitType,
-		owner:       owner,
-		repo:        repo,
-		secretValue: secretValue,
-		logger:      logger,
-		status:      status,
-		reason:      reason,
+		GitURL:       gitURL,
+		reference:    branch,
+		gitType:      gitType,
+		provider:     provider,
+		repository:   repository,
+		credentials:  credentials,
+		budgetKey:    budgetKey(host, credentials),
+		probeTimeout: hostProbeTimeout(host),
+		logger:       logger,
+		status:       status,
+		reason:       reason,
 	}
 }
 
-func (g *GitService) IsRepoReachable() (metav1.ConditionStatus, GitConditionReason) {
+func (g *GitService) IsRepoReachable(ctx context.Context) (metav1.ConditionStatus, GitConditionReason) {
 	if g.status != metav1.ConditionUnknown {
 		return g.status, g.reason
 	}
-	switch g.gitType {
-	case Github:
-		isGHRepoReachable(g)
-	case Gitlab:
-		isGLRepoReachable(g)
+	if g.reference == "" {
+		if _, err := g.DefaultBranch(ctx); err != nil {
+			g.status, g.reason, g.err = metav1.ConditionFalse, ReasonForError(err), err
+			return g.status, g.reason
+		}
+	}
+	ref, err := g.resolveRef(ctx)
+	if err != nil {
+		g.status, g.reason, g.err = metav1.ConditionFalse, ReasonForError(err), err
+		return g.status, g.reason
+	}
+	g.logger.Info("Resolved Git reference", "reference", ref.Name, "type", ref.Type, "sha", ref.SHA)
+	if err := g.checkArchived(ctx); err != nil {
+		g.status, g.reason, g.err = metav1.ConditionFalse, ReasonForError(err), err
+		return g.status, g.reason
+	}
+	g.ref = ref
+	g.status, g.reason = metav1.ConditionTrue, ReasonSucceeded
+	if ref.Unverified {
+		g.reason = ReasonCommitNotVerified
+		g.err = fmt.Errorf("the repository is reachable, but commit %s is not the tip of a branch or tag "+
+			"so the server cannot tell whether it exists", ref.SHA)
 	}
 	return g.status, g.reason
 }
 
-func identifyGitType(gitURL string) GitProvider {
-	commonRegex := regexp.
-		MustCompile(`^(https?://)?(www\.)?(github\.com|gitlab\.com)(:[0-9]{1,5})?\/([^\/]+)\/([^\/]+)(\.git)?\/?$`)
-	if !commonRegex.MatchString(gitURL) {
-		return Unknown
+// DefaultBranch looks up the default branch of the repository. When no
+// reference was given to New, the default branch becomes the reference.
+func (g *GitService) DefaultBranch(ctx context.Context) (string, error) {
+	if g.provider == nil {
+		return "", newError(g.reason, errors.New("the Git URL cannot be used"))
+	}
+	value, err := probes.do(ctx, g.probeKey("default-branch"), g.probeTimeout, func(ctx context.Context) (any, error) {
+		return g.provider.DefaultBranch(ctx, g.repository)
+	})
+	if err != nil {
+		return "", err
+	}
+	branch := value.(string)
+	g.logger.Info("Detected default branch", "branch", branch)
+	if g.reference == "" {
+		g.reference = branch
+	}
+	return branch, nil
+}
+
+// checkArchived fails with ReasonRepoArchived when the provider tells the
+// repository is archived, which its successful responses do not reveal.
+func (g *GitService) checkArchived(ctx context.Context) error {
+	provider, ok := g.provider.(ArchiveProvider)
+	if !ok {
+		return nil
+	}
+	value, err := probes.do(ctx, g.probeKey("archived"), g.probeTimeout, func(ctx context.Context) (any, error) {
+		return provider.IsArchived(ctx, g.repository)
+	})
+	if err != nil {
+		return err
+	}
+	if value.(bool) {
+		return newError(ReasonRepoArchived, fmt.Errorf("%s is archived", g.repository.FullName()))
+	}
+	return nil
+}
+
+// resolveRef resolves the reference through the probe cache shared by all the
+// GitService instances.
+func (g *GitService) resolveRef(ctx context.Context) (*Ref, error) {
+	key := g.probeKey("ref/" + g.reference)
+	value, err := probes.do(ctx, key, g.probeTimeout, func(ctx context.Context) (any, error) {
+		ref, err := g.provider.ResolveRef(ctx, g.repository, g.reference)
+		if err != nil {
+			return nil, err
+		}
+		return *ref, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ref := value.(Ref)
+	return &ref, nil
+}
+
+// ForgetProbes drops the cached probe results of the repository, so that the
+// next probes see a reference that was just pushed to.
+func (g *GitService) ForgetProbes() {
+	if g.repository != nil {
+		probes.forget(g.probeKey(""))
 	}
+}
+
+// probeKey identifies a probe of the repository with the credentials of the
+// GitService, so that results are never shared across users.
+func (g *GitService) probeKey(probe string) string {
+	return g.budgetKey + "/" + g.repository.FullName() + "/" + g.repository.CloneURL + "#" + probe
+}
 
+// CheckContextDir checks that contextDir is a directory of the repository at
+// the resolved reference, returning the status, reason and message of the
+// check. The status is Unknown when the provider cannot list directories.
+func (g *GitService) CheckContextDir(
+	ctx context.Context, contextDir string,
+) (metav1.ConditionStatus, GitConditionReason, string) {
+	dir := cleanDir(contextDir)
+	if dir == "" {
+		return metav1.ConditionTrue, ReasonContextDirFound, "The context directory is the repository root"
+	}
+	if g.ref == nil {
+		return metav1.ConditionUnknown, g.reason, "The Git reference must be resolved before the context directory is checked"
+	}
+	_, err := g.provider.ListDirectory(ctx, g.repository, g.ref.SHA, dir)
 	switch {
-	case strings.Contains(gitURL, "github.com"):
-		return Github
-	case strings.Contains(gitURL, "gitlab.com"):
-		return Gitlab
+	case err == nil:
+		return metav1.ConditionTrue, ReasonContextDirFound,
+			fmt.Sprintf("Context directory %q found at commit %s", dir, g.ref.SHA)
+	case errors.Is(err, errors.ErrUnsupported):
+		return metav1.ConditionUnknown, ReasonUnsupportedGitType,
+			fmt.Sprintf("Context directory %q cannot be checked on this Git server", dir)
+	case ReasonForError(err) == ReasonFileNotFound:
+		g.logger.Error(err, "Context directory not found", "contextDir", dir)
+		return metav1.ConditionFalse, ReasonContextDirNotFound,
+			fmt.Sprintf("Context directory %q is not a directory of %s at %s %q (commit %s)",
+				dir, g.repository.FullName(), strings.ToLower(g.ref.Type.String()), g.ref.Name, g.ref.SHA)
 	default:
+		g.logger.Error(err, "Cannot check the context directory", "contextDir", dir)
+		return metav1.ConditionFalse, ReasonForError(err), fmt.Sprintf("Cannot check context directory %q: %v", dir, err)
+	}
+}
+
+// ListDirectory returns the entries of the directory at dir, relative to the
+// root of the repository, at the resolved reference.
+func (g *GitService) ListDirectory(ctx context.Context, dir string) ([]Entry, error) {
+	if g.ref == nil {
+		return nil, newError(g.reason, errors.New("the Git reference is not resolved"))
+	}
+	return g.provider.ListDirectory(ctx, g.repository, g.ref.SHA, cleanDir(dir))
+}
+
+// GetFile returns the content of the file at filePath, relative to the root of
+// the repository, at the resolved reference.
+func (g *GitService) GetFile(ctx context.Context, filePath string) ([]byte, error) {
+	if g.ref == nil {
+		return nil, newError(g.reason, errors.New("the Git reference is not resolved"))
+	}
+	return g.provider.GetFile(ctx, g.repository, g.ref.SHA, cleanDir(filePath))
+}
+
+// maxMessageLength bounds the error text included in Message, which may quote
+// the body of an API response.
+const maxMessageLength = 512
+
+// Message describes why IsRepoReachable failed and how to fix it, or what it
+// could not verify. It returns the empty string if it fully succeeded.
+func (g *GitService) Message() string {
+	if g.status == metav1.ConditionUnknown || (g.status == metav1.ConditionTrue && g.err == nil) {
+		return ""
+	}
+	return ErrorMessage(g.reason, g.err)
+}
+
+// ErrorMessage describes a failure reported with reason and how to fix it.
+// err, which may be nil, details the failure.
+func ErrorMessage(reason GitConditionReason, err error) string {
+	message := reason.String()
+	if err != nil {
+		message = err.Error()
+		if len(message) > maxMessageLength {
+			message = message[:maxMessageLength] + "..."
+		}
+	}
+	if hint := RemediationHint(reason); hint != "" {
+		message += ". " + hint
+	}
+	return message
+}
+
+// RetryAfter returns how long to wait before probing the repository again
+// when IsRepoReachable failed on a rate limit, or zero otherwise.
+func (g *GitService) RetryAfter() time.Duration {
+	return RetryAfter(g.err, time.Now())
+}
+
+// RateLimitHoldBack returns how long a non-urgent probe of the repository
+// should wait for the API quota to reset, leaving the reserve of the quota to
+// urgent probes. It is zero when the remaining quota is above the reserve.
+func (g *GitService) RateLimitHoldBack() time.Duration {
+	if g.budgetKey == "" {
+		return 0
+	}
+	return budget.holdBack(g.budgetKey, time.Now())
+}
+
+// ResolvedRef returns the reference resolved by IsRepoReachable, or nil if the
+// repository was not reachable.
+func (g *GitService) ResolvedRef() *Ref {
+	return g.ref
+}
+
+// Repository returns the provider-specific coordinates of the repository, or
+// nil if the Git URL could not be parsed.
+func (g *GitService) Repository() *Repository {
+	return g.repository
+}
+
+// cleanDir returns dir relative to the root of the repository, without
+// leading or trailing slash. The root itself is the empty string.
+func cleanDir(dir string) string {
+	return strings.Trim(path.Clean("/"+dir), "/")
+}
+
+var (
+	// scpLikeURL matches the scp-like syntax of Git SSH URLs, e.g. "git@github.com:org/repo.git".
+	scpLikeURL = regexp.MustCompile(`^(?:([^@/]+)@)?([^:/]+):([^/].*)$`)
+
+	// portPrefix matches a port number, which tells "host:8080/org/repo" apart
+	// from an scp-like URL.
+	portPrefix = regexp.MustCompile(`^[0-9]{1,5}(/|$)`)
+)
+
+// parseGitURL parses gitURL, assuming https when no scheme is given. scp-like
+// SSH URLs are converted to ssh URLs whose path is relative, as in the original.
+func parseGitURL(gitURL string) (*url.URL, error) {
+	if !strings.Contains(gitURL, "://") {
+		if m := scpLikeURL.FindStringSubmatch(gitURL); m != nil && !portPrefix.MatchString(m[3]) {
+			u := &url.URL{Scheme: "ssh", Host: m[2], Path: m[3]}
+			if m[1] != "" {
+				u.User = url.User(m[1])
+			}
+			return u, nil
+		}
+		gitURL = "https://" + gitURL
+	}
+	u, err := url.Parse(gitURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ssh" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	return u, nil
+}
+
+// RepositoryKey identifies the repository of gitURL whatever the form of the
+// URL: its lowercased host and path, without the scheme, user, port, "www."
+// prefix and ".git" suffix, e.g. "github.com/owner/repo".
+func RepositoryKey(gitURL string) (string, error) {
+	u, err := parseGitURL(gitURL)
+	if err != nil {
+		return "", err
+	}
+	segments := splitRepoPath(u)
+	if u.Host == "" || len(segments) == 0 {
+		return "", errors.New(ReasonInvalidGitURL.String())
+	}
+	return normalizeHost(u.Host) + "/" + strings.ToLower(strings.Join(segments, "/")), nil
+}
+
+// gitTypeForURL returns the provider type serving u. SSH URLs are always
+// served by the SSH provider.
+func gitTypeForURL(u *url.URL) GitProvider {
+	if u.Scheme == "ssh" {
+		return SSH
+	}
+	return providerTypeForHost(u.Host)
+}
+
+// parseRepository parses gitURL into the repository coordinates of provider.
+func parseRepository(provider Provider, gitURL string) (*Repository, error) {
+	u, err := parseGitURL(gitURL)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ParseURL(u)
+}
+
+func identifyGitType(gitURL string) GitProvider {
+	u, err := parseGitURL(gitURL)
+	if err != nil {
 		return Unknown
 	}
+	return gitTypeForURL(u)
 }
 
 func getOwnerAndRepo(gitURL string) (string, string, error) {
-	// This regular expression matches URLs of the form "https://github.com/username/repo"
-	// or "https://gitlab.com/username/repo", with or without the "https://" prefix,
-	// and optionally with ".git" at the end.
-	re := regexp.
-		MustCompile(`^(https?://)?(www\.)?(github\.com|gitlab\.com)(:[0-9]{1,5})?\/([^\/]+)\/([^\/]+)(\.git)?\/?$`)
-	matches := re.FindStringSubmatch(gitURL)
-
-	if len(matches) < 7 {
+	provider, err := newProvider(identifyGitType(gitURL), ProviderOptions{Logger: logr.Discard()})
+	if err != nil {
 		return "", "", errors.New(ReasonInvalidGitURL.String())
 	}
-
-	username, repo := matches[5], matches[6]
-	return username, strings.TrimSuffix(repo, ".git"), nil
+	repository, err := parseRepository(provider, gitURL)
+	if err != nil {
+		return "", "", errors.New(ReasonInvalidGitURL.String())
+	}
+	return repository.Owner, repository.Name, nil
 }
 
 /** Run this main function to test this package
@@ -109,9 +409,9 @@ func main() {
 
 	ctx := context.Background()
 	logger = log.FromContext(ctx)
-	// g := New("https://github.com/openshift-console/console-application-operator", "main", "", logger)
-	g := New("https://gitlab.com/avikkundu/oc-pipe", "main", "<PAT>", logger)
-	fmt.Println(g.isRepoReachable())
+	// g := New("https://github.com/openshift-console/console-application-operator", "main", Credentials{}, logger)
+	g := New("https://gitlab.com/avikkundu/oc-pipe", "main", Credentials{Token: "<PAT>"}, logger)
+	fmt.Println(g.IsRepoReachable(ctx))
 
 }
 
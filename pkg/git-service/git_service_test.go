@@ -1,11 +1,19 @@
 package gitservice
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestIdentifyGitType(t *testing.T) {
@@ -56,3 +64,195 @@ func TestGetOwnerAndRepo(t *testing.T) {
 		})
 	}
 }
+
+func TestRepositoryKey(t *testing.T) {
+	for _, gitURL := range []string{
+		"https://github.com/Hello/World",
+		"https://www.github.com/hello/world.git",
+		"github.com/hello/world/",
+		"git@github.com:hello/world.git",
+		"ssh://git@github.com:22/hello/world.git",
+	} {
+		key, err := RepositoryKey(gitURL)
+		require.NoError(t, err, gitURL)
+		assert.Equal(t, "github.com/hello/world", key, gitURL)
+	}
+	_, err := RepositoryKey("https://github.com")
+	assert.Error(t, err)
+}
+
+func TestSelfHostedProviders(t *testing.T) {
+	githubAPI := newFakeGithubAPI(t)
+	gitlabAPI := newFakeGitlabAPI(t)
+	tests := []struct {
+		name   string
+		host   HostConfig
+		gitURL string
+	}{
+		{
+			"Github Enterprise Server",
+			HostConfig{Host: "github.example.com", Type: Github, APIURL: githubAPI.URL + "/api/v3/"},
+			"https://github.example.com/hello/world",
+		},
+		{
+			"Gitlab self-managed",
+			HostConfig{Host: "gitlab.example.com", Type: Gitlab, APIURL: gitlabAPI.URL},
+			"https://gitlab.example.com/hello/world.git",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registerTestHost(t, tt.host)
+			assert.Equal(t, tt.host.Type, identifyGitType(tt.gitURL))
+
+			status, reason := New(tt.gitURL, conformanceBranch, testCredentials, testLogger).IsRepoReachable(context.Background())
+			assert.Equal(t, metav1.ConditionTrue, status)
+			assert.Equal(t, ReasonSucceeded, reason)
+
+			status, reason = New(tt.gitURL, "missing", testCredentials, testLogger).IsRepoReachable(context.Background())
+			assert.Equal(t, metav1.ConditionFalse, status)
+			assert.Equal(t, ReasonRepoNotFound, reason)
+		})
+	}
+}
+
+func TestResolvedRef(t *testing.T) {
+	registerTestHost(t, HostConfig{Host: "github.example.com", Type: Github, APIURL: newFakeGithubAPI(t).URL + "/api/v3/"})
+	gitURL := "https://github.example.com/hello/world"
+
+	gs := New(gitURL, conformanceTag, testCredentials, testLogger)
+	assert.Nil(t, gs.ResolvedRef(), "nothing is resolved before the repository is checked")
+	status, _ := gs.IsRepoReachable(context.Background())
+	require.Equal(t, metav1.ConditionTrue, status)
+	assert.Equal(t, &Ref{Name: conformanceTag, Type: RefTypeTag, SHA: conformanceSHA}, gs.ResolvedRef())
+
+	gs = New(gitURL, "missing", testCredentials, testLogger)
+	gs.IsRepoReachable(context.Background())
+	assert.Nil(t, gs.ResolvedRef())
+}
+
+func TestEmptyReferenceUsesDefaultBranch(t *testing.T) {
+	registerTestHost(t, HostConfig{Host: "github.example.com", Type: Github, APIURL: newFakeGithubAPI(t).URL + "/api/v3/"})
+
+	gs := New("https://github.example.com/hello/world", "", testCredentials, testLogger)
+	status, reason := gs.IsRepoReachable(context.Background())
+	require.Equal(t, metav1.ConditionTrue, status)
+	assert.Equal(t, ReasonSucceeded, reason)
+	assert.Equal(t, &Ref{Name: conformanceBranch, Type: RefTypeBranch, SHA: conformanceSHA}, gs.ResolvedRef())
+
+	status, reason = New("https://github.example.com/hello/missing", "", testCredentials, testLogger).IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionFalse, status)
+	assert.Equal(t, ReasonRepoNotFound, reason)
+
+	_, err := New("ftp://github.example.com/hello/world", "", testCredentials, testLogger).DefaultBranch(context.Background())
+	assert.Equal(t, ReasonInvalidGitURL, ReasonForError(err))
+}
+
+func TestArchivedRepository(t *testing.T) {
+	upstream, err := url.Parse(newFakeGithubAPI(t).URL)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	// The repository answers 200 to every read, only its archived flag tells.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/repos/"+conformanceOwner+"/"+conformanceName {
+			writeJSON(w, map[string]any{"name": conformanceName, "default_branch": conformanceBranch, "archived": true})
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	registerTestHost(t, HostConfig{Host: "github.example.com", Type: Github, APIURL: server.URL + "/api/v3/"})
+
+	for _, reference := range []string{conformanceBranch, ""} {
+		gs := New("https://github.example.com/hello/world", reference, testCredentials, testLogger)
+		status, reason := gs.IsRepoReachable(context.Background())
+		assert.Equal(t, metav1.ConditionFalse, status, "reference %q", reference)
+		assert.Equal(t, ReasonRepoArchived, reason, "reference %q", reference)
+		assert.Contains(t, gs.Message(), "archived")
+	}
+}
+
+func TestCheckContextDir(t *testing.T) {
+	registerTestHost(t, HostConfig{Host: "github.example.com", Type: Github, APIURL: newFakeGithubAPI(t).URL + "/api/v3/"})
+	gs := New("https://github.example.com/hello/world", conformanceBranch, testCredentials, testLogger)
+
+	status, reason, _ := gs.CheckContextDir(context.Background(), "app")
+	assert.Equal(t, metav1.ConditionUnknown, status, "the reference is not resolved yet")
+	assert.Equal(t, ReasonProcessing, reason)
+
+	repoStatus, _ := gs.IsRepoReachable(context.Background())
+	require.Equal(t, metav1.ConditionTrue, repoStatus)
+	tests := []struct {
+		contextDir string
+		status     metav1.ConditionStatus
+		reason     GitConditionReason
+	}{
+		{"", metav1.ConditionTrue, ReasonContextDirFound},
+		{"./", metav1.ConditionTrue, ReasonContextDirFound},
+		{"app", metav1.ConditionTrue, ReasonContextDirFound},
+		{"/app/", metav1.ConditionTrue, ReasonContextDirFound},
+		{"ap", metav1.ConditionFalse, ReasonContextDirNotFound},
+		{conformanceFile, metav1.ConditionFalse, ReasonContextDirNotFound},
+	}
+	for _, tt := range tests {
+		status, reason, message := gs.CheckContextDir(context.Background(), tt.contextDir)
+		assert.Equal(t, tt.status, status, tt.contextDir)
+		assert.Equal(t, tt.reason, reason, tt.contextDir)
+		assert.NotEmpty(t, message)
+	}
+}
+
+func TestCheckContextDirUnsupported(t *testing.T) {
+	server := newFakeSmartHTTPServer(t)
+	gs := New(server.URL+"/hello/world.git", conformanceBranch, testCredentials, testLogger)
+	repoStatus, _ := gs.IsRepoReachable(context.Background())
+	require.Equal(t, metav1.ConditionTrue, repoStatus)
+	status, reason, _ := gs.CheckContextDir(context.Background(), "app")
+	assert.Equal(t, metav1.ConditionUnknown, status)
+	assert.Equal(t, ReasonUnsupportedGitType, reason)
+}
+
+// registerTestHost registers host for the duration of the test.
+func registerTestHost(t *testing.T, host HostConfig) {
+	require.NoError(t, RegisterHost(host))
+	// The next test registering the host serves another repository.
+	t.Cleanup(func() { UnregisterHost(host.Host) })
+}
+
+func TestDefaultAPIURLKeepsPort(t *testing.T) {
+	requested := make(chan string, 10)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.Path
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	// Test servers listen on 127.0.0.1, the port is not part of the host.
+	const host = "127.0.0.1"
+	require.NoError(t, SetHostTransport(host, TransportConfig{CABundle: serverCABundle(server)}))
+	t.Cleanup(func() { require.NoError(t, SetHostTransport(host, TransportConfig{})) })
+
+	tests := []struct {
+		gitType  GitProvider
+		repoPath string
+		apiPath  string
+	}{
+		{Github, "/hello/world", "/api/v3/repos/hello/world"},
+		{Gitlab, "/hello/world", "/api/v4/projects/hello/world"},
+		{Gitea, "/hello/world", "/api/v1/repos/hello/world"},
+		{BitbucketServer, "/scm/hello/world.git", "/rest/api/1.0/projects/hello/repos/world"},
+		{AzureDevOps, "/hello/project/_git/world", "/hello/project/_apis/git/repositories/world"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.gitType), func(t *testing.T) {
+			registerTestHost(t, HostConfig{Host: host, Type: tt.gitType})
+			gs := New(server.URL+tt.repoPath, conformanceBranch, testCredentials, testLogger)
+			status, _ := gs.IsRepoReachable(context.Background())
+			assert.Equal(t, metav1.ConditionFalse, status)
+			require.NotEmpty(t, requested, "the API is served on the port of the Git URL")
+			assert.True(t, strings.HasPrefix(<-requested, tt.apiPath))
+			for len(requested) > 0 {
+				<-requested
+			}
+		})
+	}
+}
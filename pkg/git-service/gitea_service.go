@@ -0,0 +1,164 @@
+package gitservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/go-logr/logr"
+)
+
+const codebergHost = "codeberg.org"
+
+func init() {
+	// Gitea has no public instance, so its hosts come from the operator configuration.
+	RegisterProvider(Gitea, newGiteaProvider)
+	// Forgejo is a fork of Gitea serving the same API; Codeberg is its public instance.
+	RegisterProvider(Forgejo, newGiteaProvider, codebergHost)
+}
+
+// giteaProvider talks to the REST API v1 of Gitea and Forgejo.
+type giteaProvider struct {
+	client *apiClient
+	logger logr.Logger
+}
+
+func newGiteaProvider(opts ProviderOptions) (Provider, error) {
+	apiURL := opts.APIURL
+	if apiURL == "" {
+		if opts.Host == "" {
+			return nil, errors.New("a host is required for Gitea")
+		}
+		apiURL = opts.hostURL() + "/api/v1"
+	}
+	client := newAPIClient(apiURL, opts.HTTPClient)
+	opts.Credentials.setAuthorization(client, "token")
+	return &giteaProvider{client: client, logger: opts.Logger}, nil
+}
+
+// ParseURL parses URLs of the form https://{host}/{owner}/{repo}.
+func (p *giteaProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	return &Repository{Host: normalizeHost(u.Host), Owner: segments[0], Name: segments[1]}, nil
+}
+
+func (p *giteaProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
+	}
+	p.logger.Info("Successfully reached Gitea API!")
+	return nil
+}
+
+func (p *giteaProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	ref, err := resolveRef(ctx, reference,
+		func(ctx context.Context, branch string) (string, error) {
+			var b struct {
+				Commit struct {
+					ID string `json:"id"`
+				} `json:"commit"`
+			}
+			resp, err := p.client.get(ctx, p.repoPath(repo)+"/branches/"+url.PathEscape(branch), nil, &b)
+			if err != nil {
+				return "", apiError(resp, err, ReasonRepoNotFound)
+			}
+			return b.Commit.ID, nil
+		},
+		func(ctx context.Context, tag string) (string, error) {
+			var t struct {
+				Commit struct {
+					SHA string `json:"sha"`
+				} `json:"commit"`
+			}
+			resp, err := p.client.get(ctx, p.repoPath(repo)+"/tags/"+url.PathEscape(tag), nil, &t)
+			if err != nil {
+				return "", apiError(resp, err, ReasonRepoNotFound)
+			}
+			return t.Commit.SHA, nil
+		},
+		func(ctx context.Context, sha string) (string, error) {
+			var commit struct {
+				SHA string `json:"sha"`
+			}
+			resp, err := p.client.get(ctx, p.repoPath(repo)+"/git/commits/"+url.PathEscape(sha), nil, &commit)
+			if err != nil {
+				return "", apiError(resp, err, ReasonRepoNotFound)
+			}
+			return commit.SHA, nil
+		},
+	)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Gitea API")
+		return nil, err
+	}
+	return ref, nil
+}
+
+func (p *giteaProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	var repository struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	resp, err := p.client.get(ctx, p.repoPath(repo), nil, &repository)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Gitea API")
+		return "", apiError(resp, err, ReasonRepoNotFound)
+	}
+	return repository.DefaultBranch, nil
+}
+
+func (p *giteaProvider) IsArchived(ctx context.Context, repo *Repository) (bool, error) {
+	var repository struct {
+		Archived bool `json:"archived"`
+	}
+	resp, err := p.client.get(ctx, p.repoPath(repo), nil, &repository)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Gitea API")
+		return false, apiError(resp, err, ReasonRepoNotFound)
+	}
+	return repository.Archived, nil
+}
+
+func (p *giteaProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	var content []byte
+	query := url.Values{"ref": {reference}}
+	resp, err := p.client.get(ctx, p.repoPath(repo)+"/raw/"+escapePath(path), query, &content)
+	if err != nil {
+		return nil, apiError(resp, err, ReasonFileNotFound)
+	}
+	return content, nil
+}
+
+func (p *giteaProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	var content json.RawMessage
+	contentsPath := p.repoPath(repo) + "/contents"
+	if path != "" {
+		contentsPath += "/" + escapePath(path)
+	}
+	resp, err := p.client.get(ctx, contentsPath, url.Values{"ref": {reference}}, &content)
+	if err != nil {
+		return nil, apiError(resp, err, ReasonFileNotFound)
+	}
+	// Directories are listed as an array, files as a single object.
+	var contents []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(content, &contents); err != nil {
+		return nil, notADirectory(path)
+	}
+	entries := make([]Entry, 0, len(contents))
+	for _, c := range contents {
+		entries = append(entries, newEntry(c.Path, c.Type == "dir"))
+	}
+	return entries, nil
+}
+
+func (p *giteaProvider) repoPath(repo *Repository) string {
+	return "/repos/" + url.PathEscape(repo.Owner) + "/" + url.PathEscape(repo.Name)
+}
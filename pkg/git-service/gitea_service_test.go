@@ -0,0 +1,111 @@
+package gitservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newFakeGiteaAPI serves the conformance repository through the subset of the
+// Gitea REST API v1 used by the Gitea provider. The repository is private, so
+// requests without the conformance token get a 404 like on a real instance.
+func newFakeGiteaAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	repoPath := "/api/v1/repos/" + conformanceOwner + "/" + conformanceName
+	mux.HandleFunc(repoPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"name": conformanceName, "default_branch": conformanceBranch})
+	})
+	mux.HandleFunc(repoPath+"/branches/"+conformanceBranch, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"name":   conformanceBranch,
+			"commit": map[string]any{"id": conformanceSHA},
+		})
+	})
+	mux.HandleFunc(repoPath+"/tags/"+conformanceTag, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"name":   conformanceTag,
+			"id":     conformanceTagSHA,
+			"commit": map[string]any{"sha": conformanceSHA},
+		})
+	})
+	mux.HandleFunc(repoPath+"/git/commits/"+conformanceSHA, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"sha": conformanceSHA})
+	})
+	contents := func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, repoPath+"/contents"), "/")
+		entries, isFile, ok := lookupConformanceTree(path)
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case isFile:
+			writeJSON(w, map[string]any{"name": conformanceFile, "path": path, "type": "file"})
+		default:
+			values := []map[string]any{}
+			for _, entry := range entries {
+				values = append(values, map[string]any{"name": entry.Name, "path": entry.Path, "type": string(entry.Type)})
+			}
+			writeJSON(w, values)
+		}
+	}
+	mux.HandleFunc(repoPath+"/contents", contents)
+	mux.HandleFunc(repoPath+"/contents/", contents)
+	mux.HandleFunc(repoPath+"/raw/"+conformanceFile, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != conformanceBranch {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(conformanceContent))
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token "+conformanceToken {
+			http.NotFound(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGiteaProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeGiteaAPI(t)
+		provider, err := newGiteaProvider(ProviderOptions{
+			APIURL:      server.URL + "/api/v1",
+			Credentials: testCredentials,
+			Logger:      testLogger,
+		})
+		require.NoError(t, err)
+		return providerFixture{
+			provider: provider,
+			gitURL:   "https://gitea.example.com/" + conformanceOwner + "/" + conformanceName + ".git",
+		}
+	})
+}
+
+func TestGiteaProviderSelfHosted(t *testing.T) {
+	server := newFakeGiteaAPI(t)
+	registerTestHost(t, HostConfig{Host: "gitea.example.com", Type: Gitea, APIURL: server.URL + "/api/v1"})
+	gitURL := "https://gitea.example.com/hello/world"
+
+	status, reason := New(gitURL, conformanceBranch, testCredentials, testLogger).IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionTrue, status)
+	assert.Equal(t, ReasonSucceeded, reason)
+
+	// The fake API hides the repository from anonymous users, like private repositories
+	status, reason = New(gitURL, conformanceBranch, Credentials{}, testLogger).IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionFalse, status)
+	assert.Equal(t, ReasonRepoNotFound, reason)
+}
+
+func TestGiteaProviderRequiresHost(t *testing.T) {
+	_, err := newGiteaProvider(ProviderOptions{Logger: testLogger})
+	assert.Error(t, err)
+}
@@ -0,0 +1,249 @@
+package gitservice
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// SecretGithubAppIDKey is the key of the ID of a Github App in an Opaque source secret.
+	SecretGithubAppIDKey = "github-app-id"
+
+	// SecretGithubAppInstallationIDKey is the key of the installation ID of a
+	// Github App in an Opaque source secret.
+	SecretGithubAppInstallationIDKey = "github-app-installation-id"
+
+	// SecretGithubAppPrivateKeyKey is the key of the PEM private key of a Github
+	// App in an Opaque source secret.
+	SecretGithubAppPrivateKeyKey = "github-app-private-key"
+
+	// installationTokenRefreshMargin is how long before their expiry
+	// installation tokens are replaced, so that no request uses an expired one.
+	installationTokenRefreshMargin = 5 * time.Minute
+
+	// appJWTLifetime is the lifetime of the JWT authenticating as the Github
+	// App, at most 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+
+	// appJWTClockSkew backdates the JWT to allow for clocks drifting from Github.
+	appJWTClockSkew = time.Minute
+)
+
+// GithubApp is a Github App installation, authenticating with short-lived
+// installation tokens instead of the personal access token of a user.
+type GithubApp struct {
+	// AppID is the ID of the Github App.
+	AppID int64
+
+	// InstallationID is the ID of the installation of the app on the account
+	// owning the repositories.
+	InstallationID int64
+
+	// PrivateKey is the PEM private key of the app, signing the JWTs the
+	// installation tokens are requested with.
+	PrivateKey []byte
+}
+
+// githubAppKeys are the keys of a Github App in an Opaque source secret, all
+// required.
+var githubAppKeys = []string{SecretGithubAppIDKey, SecretGithubAppInstallationIDKey, SecretGithubAppPrivateKeyKey}
+
+// hasGithubAppKey returns whether secret holds any key of a Github App.
+func hasGithubAppKey(secret *corev1.Secret) bool {
+	for _, key := range githubAppKeys {
+		if len(secret.Data[key]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// githubAppCredentials builds the Github App credentials of an Opaque secret.
+func githubAppCredentials(secret *corev1.Secret) (Credentials, error) {
+	for _, key := range githubAppKeys {
+		if len(secret.Data[key]) == 0 {
+			return Credentials{}, missingKeyError(secret, key)
+		}
+	}
+	app := &GithubApp{PrivateKey: secret.Data[SecretGithubAppPrivateKeyKey]}
+	for key, id := range map[string]*int64{
+		SecretGithubAppIDKey:             &app.AppID,
+		SecretGithubAppInstallationIDKey: &app.InstallationID,
+	} {
+		var err error
+		if *id, err = strconv.ParseInt(strings.TrimSpace(string(secret.Data[key])), 10, 64); err != nil {
+			return Credentials{}, newError(ReasonInvalidSecretKey,
+				fmt.Errorf("the %q key of secret %q is not a number", key, secret.Name))
+		}
+	}
+	if _, err := parseRSAPrivateKey(app.PrivateKey); err != nil {
+		return Credentials{}, newError(ReasonInvalidSecretKey,
+			fmt.Errorf("the %q key of secret %q: %w", SecretGithubAppPrivateKeyKey, secret.Name, err))
+	}
+	return Credentials{GithubApp: app}, nil
+}
+
+// parseRSAPrivateKey parses the PKCS #1 private keys generated by Github, or
+// PKCS #8 RSA private keys.
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// keyDigest returns the hex SHA-256 digest of the private key of the app.
+func (a *GithubApp) keyDigest() string {
+	digest := sha256.Sum256(a.PrivateKey)
+	return hex.EncodeToString(digest[:])
+}
+
+// jwt returns a JWT authenticating as the app, signed with its private key.
+func (a *GithubApp) jwt(now time.Time) (string, error) {
+	key, err := parseRSAPrivateKey(a.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(a.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// installationToken is an installation access token and its expiry.
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// installationTokenCache keeps the installation tokens of every Github App
+// across GitService instances until shortly before they expire. Concurrent
+// requests for the token of an installation mint a single one.
+type installationTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]installationToken
+	group  singleflight.Group
+	now    func() time.Time
+}
+
+var installationTokens = &installationTokenCache{tokens: map[string]installationToken{}, now: time.Now}
+
+// get returns a valid token of the installation of app, minting one from the
+// API at apiURL with client when there is none.
+func (c *installationTokenCache) get(
+	ctx context.Context, client *http.Client, apiURL string, app *GithubApp,
+) (string, error) {
+	// The IDs of an app are public: the private key proves the secret is the
+	// app's, so tokens minted with another key are never shared.
+	key := fmt.Sprintf("%s#%d/%d/%s", apiURL, app.AppID, app.InstallationID, app.keyDigest())
+	c.mu.Lock()
+	token, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && c.now().Add(installationTokenRefreshMargin).Before(token.ExpiresAt) {
+		return token.Token, nil
+	}
+
+	value, err, _ := c.group.Do(key, func() (any, error) {
+		token, err := c.mint(ctx, client, apiURL, app)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.tokens[key] = token
+		return token.Token, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// mint requests a new installation token, authenticating as the app.
+func (c *installationTokenCache) mint(
+	ctx context.Context, client *http.Client, apiURL string, app *GithubApp,
+) (installationToken, error) {
+	token := installationToken{}
+	jwt, err := app.jwt(c.now())
+	if err != nil {
+		return token, newError(ReasonInvalidSecretKey, err)
+	}
+	api := newAPIClient(apiURL, client)
+	api.header.Set("Authorization", "Bearer "+jwt)
+	api.header.Set("Accept", "application/vnd.github+json")
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", app.InstallationID)
+	resp, err := api.do(ctx, http.MethodPost, path, nil, nil, &token)
+	if err != nil {
+		err = fmt.Errorf("cannot mint an installation token of Github App %d: %w", app.AppID, err)
+		// An unknown installation is reported as rejected credentials, not as a missing repository.
+		return token, apiError(resp, err, ReasonAuthenticationFailed)
+	}
+	if token.Token == "" {
+		return token, newError(ReasonAuthenticationFailed, errors.New("the Github API returned no installation token"))
+	}
+	return token, nil
+}
+
+// githubAppTransport authenticates the requests to the Github API with the
+// installation tokens of a Github App.
+type githubAppTransport struct {
+	app    *GithubApp
+	apiURL string
+	client *http.Client
+	base   http.RoundTripper
+}
+
+func (t *githubAppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := installationTokens.get(req.Context(), t.client, t.apiURL, t.app)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,183 @@
+package gitservice
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	testAppID          = 1234
+	testInstallationID = 5678
+)
+
+// newTestGithubApp returns a Github App with a freshly generated private key.
+func newTestGithubApp(t *testing.T) (*GithubApp, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return &GithubApp{AppID: testAppID, InstallationID: testInstallationID, PrivateKey: privateKey}, key
+}
+
+// verifyJWT checks the signature of jwt with key and returns its claims.
+func verifyJWT(jwt string, key *rsa.PublicKey) (map[string]any, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed JWT %q", jwt)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]any{}
+	return claims, json.Unmarshal(payload, &claims)
+}
+
+// fakeGithubAppAPI is the fake Github API in front of a fake installation
+// token endpoint, which only accepts the JWTs signed with the given keys.
+type fakeGithubAppAPI struct {
+	*httptest.Server
+	minted atomic.Int32
+	now    func() time.Time
+}
+
+func newFakeGithubAppAPI(t *testing.T, keys ...*rsa.PublicKey) *fakeGithubAppAPI {
+	api := &fakeGithubAppAPI{now: time.Now}
+	upstream, err := url.Parse(newFakeGithubAPI(t).URL)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+
+	mux := http.NewServeMux()
+	tokenPath := fmt.Sprintf("/api/v3/app/installations/%d/access_tokens", testInstallationID)
+	mux.HandleFunc(tokenPath, func(w http.ResponseWriter, r *http.Request) {
+		var claims map[string]any
+		var err error
+		for _, key := range keys {
+			if claims, err = verifyJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), key); err == nil {
+				break
+			}
+		}
+		if r.Method != http.MethodPost || err != nil || claims["iss"] != fmt.Sprint(testAppID) {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]string{"message": "A JSON web token could not be decoded"})
+			return
+		}
+		n := api.minted.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]any{
+			"token":      fmt.Sprintf("ghs_%d", n),
+			"expires_at": api.now().Add(time.Hour).Format(time.RFC3339),
+		})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != fmt.Sprintf("token ghs_%d", api.minted.Load()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+	api.Server = httptest.NewServer(mux)
+	t.Cleanup(api.Close)
+	return api
+}
+
+func TestGithubAppInstallationTokens(t *testing.T) {
+	app, key := newTestGithubApp(t)
+	api := newFakeGithubAppAPI(t, &key.PublicKey)
+	now := time.Now()
+	api.now = func() time.Time { return now }
+	installationTokens.now = func() time.Time { return now }
+	t.Cleanup(func() { installationTokens.now = time.Now })
+
+	provider, err := newGithubProvider(ProviderOptions{
+		APIURL:      api.URL + "/api/v3/",
+		Credentials: Credentials{GithubApp: app},
+		Logger:      testLogger,
+	})
+	require.NoError(t, err)
+	repo := &Repository{Host: githubHost, Owner: conformanceOwner, Name: conformanceName}
+
+	for i := 0; i < 3; i++ {
+		ref, err := provider.ResolveRef(context.Background(), repo, conformanceBranch)
+		require.NoError(t, err)
+		assert.Equal(t, conformanceSHA, ref.SHA)
+	}
+	assert.Equal(t, int32(1), api.minted.Load(), "the token is cached")
+
+	now = now.Add(time.Hour - installationTokenRefreshMargin)
+	_, err = provider.ResolveRef(context.Background(), repo, conformanceBranch)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), api.minted.Load(), "the token is refreshed before it expires")
+}
+
+func TestGithubAppTokensPerPrivateKey(t *testing.T) {
+	app, key := newTestGithubApp(t)
+	// Another secret with the public IDs of the app, and its own private key.
+	forged, forgedKey := newTestGithubApp(t)
+	api := newFakeGithubAppAPI(t, &key.PublicKey, &forgedKey.PublicKey)
+	apiURL := api.URL + "/api/v3/"
+
+	token, err := installationTokens.get(context.Background(), http.DefaultClient, apiURL, app)
+	require.NoError(t, err)
+	forgedToken, err := installationTokens.get(context.Background(), http.DefaultClient, apiURL, forged)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, forgedToken)
+	assert.Equal(t, int32(2), api.minted.Load(), "each private key mints its own token")
+	assert.NotEqual(t, Credentials{GithubApp: app}.fingerprint(), Credentials{GithubApp: forged}.fingerprint())
+}
+
+func TestGithubAppRejected(t *testing.T) {
+	app, _ := newTestGithubApp(t)
+	// The token endpoint only trusts the key of another app.
+	_, otherKey := newTestGithubApp(t)
+	api := newFakeGithubAppAPI(t, &otherKey.PublicKey)
+	registerTestHost(t, HostConfig{Host: "github.app.example.com", Type: Github, APIURL: api.URL + "/api/v3/"})
+
+	gs := New("https://github.app.example.com/"+conformanceOwner+"/"+conformanceName, conformanceBranch,
+		Credentials{GithubApp: app}, testLogger)
+	_, reason := gs.IsRepoReachable(context.Background())
+	assert.Equal(t, ReasonAuthenticationFailed, reason)
+	assert.Contains(t, gs.Message(), "cannot mint an installation token")
+}
+
+func TestGithubAppOnAnotherProvider(t *testing.T) {
+	app, _ := newTestGithubApp(t)
+	for _, gitURL := range []string{
+		"https://gitlab.com/" + conformanceOwner + "/" + conformanceName,
+		"ssh://git@github.com/" + conformanceOwner + "/" + conformanceName + ".git",
+	} {
+		gs := New(gitURL, conformanceBranch, Credentials{GithubApp: app}, testLogger)
+		status, reason := gs.IsRepoReachable(context.Background())
+		assert.Equal(t, metav1.ConditionFalse, status, gitURL)
+		assert.Equal(t, ReasonUnsupportedSecretType, reason, gitURL)
+		assert.Contains(t, gs.Message(), "only authenticate to Github", gitURL)
+	}
+}
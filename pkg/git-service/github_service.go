@@ -2,41 +2,351 @@ package gitservice
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 
+	"github.com/go-logr/logr"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
+)
+
+const (
+	githubHost = "github.com"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	// githubAPIURL is the REST API endpoint of github.com.
+	githubAPIURL = "https://api.github.com/"
 )
 
-func isGHRepoReachable(g *GitService) {
-	ctx := context.Background()
+func init() {
+	RegisterProvider(Github, newGithubProvider, githubHost)
+}
+
+type githubProvider struct {
+	client *github.Client
+	logger logr.Logger
+}
+
+func newGithubProvider(opts ProviderOptions) (Provider, error) {
+	apiURL := opts.APIURL
+	if apiURL == "" && opts.Host != "" && opts.Host != githubHost {
+		// Github Enterprise Server serves its REST API under /api/v3/.
+		apiURL = opts.hostURL() + "/api/v3/"
+	}
 
-	oauth_client := (*http.Client)(nil)
-	if g.secretValue != "" {
+	oauthClient := opts.HTTPClient
+	switch credentials := opts.Credentials; {
+	case credentials.GithubApp != nil:
+		transport := &githubAppTransport{
+			app:    credentials.GithubApp,
+			apiURL: apiURL,
+			client: opts.HTTPClient,
+			base:   http.DefaultTransport,
+		}
+		if transport.apiURL == "" {
+			transport.apiURL = githubAPIURL
+		}
+		if opts.HTTPClient != nil {
+			transport.base = opts.HTTPClient.Transport
+		}
+		oauthClient = &http.Client{Transport: transport}
+	case credentials.Token != "":
 		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: g.secretValue},
+			&oauth2.Token{AccessToken: credentials.Token},
 		)
-		oauth_client = oauth2.NewClient(ctx, ts)
+		ctx := context.Background()
+		if opts.HTTPClient != nil {
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, opts.HTTPClient)
+		}
+		oauthClient = oauth2.NewClient(ctx, ts)
+	case credentials.Password != "":
+		// Github accepts a personal access token as the password.
+		transport := &github.BasicAuthTransport{Username: credentials.Username, Password: credentials.Password}
+		if opts.HTTPClient != nil {
+			transport.Transport = opts.HTTPClient.Transport
+		}
+		oauthClient = transport.Client()
+	}
+
+	client := github.NewClient(oauthClient)
+	if apiURL != "" {
+		var err error
+		client, err = github.NewEnterpriseClient(apiURL, apiURL, oauthClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &githubProvider{client: client, logger: opts.Logger}, nil
+}
+
+func (p *githubProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	return &Repository{Host: normalizeHost(u.Host), Owner: segments[0], Name: segments[1]}, nil
+}
+
+func (p *githubProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
+	}
+	p.logger.Info("Successfully reached Github API!")
+	return nil
+}
+
+func (p *githubProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	ref, err := resolveRef(ctx, reference,
+		func(ctx context.Context, branch string) (string, error) {
+			b, resp, err := p.client.Repositories.GetBranch(ctx, repo.Owner, repo.Name, branch)
+			if err != nil {
+				return "", githubError(resp, err, ReasonRepoNotFound)
+			}
+			return b.GetCommit().GetSHA(), nil
+		},
+		func(ctx context.Context, tag string) (string, error) {
+			return p.tagCommit(ctx, repo, tag)
+		},
+		func(ctx context.Context, sha string) (string, error) {
+			commit, resp, err := p.client.Repositories.GetCommit(ctx, repo.Owner, repo.Name, sha)
+			if err != nil {
+				if resp != nil && resp.StatusCode == http.StatusUnprocessableEntity {
+					// Github answers unknown commit SHAs with a 422.
+					return "", newError(ReasonRepoNotFound, err)
+				}
+				return "", githubError(resp, err, ReasonRepoNotFound)
+			}
+			return commit.GetSHA(), nil
+		},
+	)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Github API")
+		return nil, err
+	}
+	return ref, nil
+}
+
+// tagCommit returns the commit tag points at, peeling annotated tags.
+func (p *githubProvider) tagCommit(ctx context.Context, repo *Repository, tag string) (string, error) {
+	ref, resp, err := p.client.Git.GetRef(ctx, repo.Owner, repo.Name, "tags/"+tag)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusOK {
+			// Only tags starting with the name exist.
+			return "", newError(ReasonRepoNotFound, err)
+		}
+		return "", githubError(resp, err, ReasonRepoNotFound)
+	}
+	if ref.GetObject().GetType() != "tag" {
+		return ref.GetObject().GetSHA(), nil
+	}
+	annotated, resp, err := p.client.Git.GetTag(ctx, repo.Owner, repo.Name, ref.GetObject().GetSHA())
+	if err != nil {
+		return "", githubError(resp, err, ReasonRepoNotFound)
+	}
+	return annotated.GetObject().GetSHA(), nil
+}
+
+func (p *githubProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	repository, resp, err := p.client.Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Github API")
+		return "", githubError(resp, err, ReasonRepoNotFound)
+	}
+	return repository.GetDefaultBranch(), nil
+}
+
+func (p *githubProvider) IsArchived(ctx context.Context, repo *Repository) (bool, error) {
+	repository, resp, err := p.client.Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Github API")
+		return false, githubError(resp, err, ReasonRepoNotFound)
+	}
+	return repository.GetArchived(), nil
+}
+
+func (p *githubProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	file, _, resp, err := p.client.Repositories.GetContents(ctx, repo.Owner, repo.Name, path,
+		&github.RepositoryContentGetOptions{Ref: reference})
+	if err != nil {
+		return nil, githubError(resp, err, ReasonFileNotFound)
+	}
+	if file == nil {
+		return nil, newError(ReasonFileNotFound, errors.New(path+" is a directory"))
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+func (p *githubProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	file, contents, resp, err := p.client.Repositories.GetContents(ctx, repo.Owner, repo.Name, path,
+		&github.RepositoryContentGetOptions{Ref: reference})
+	if err != nil {
+		return nil, githubError(resp, err, ReasonFileNotFound)
+	}
+	if file != nil {
+		return nil, notADirectory(path)
 	}
+	entries := make([]Entry, 0, len(contents))
+	for _, content := range contents {
+		entries = append(entries, newEntry(content.GetPath(), content.GetType() == "dir"))
+	}
+	return entries, nil
+}
 
-	client := github.NewClient(oauth_client)
+func (p *githubProvider) CreateWebhook(ctx context.Context, repo *Repository, hook Webhook) (string, error) {
+	created, resp, err := p.client.Repositories.CreateHook(ctx, repo.Owner, repo.Name, githubHook(hook))
+	if err != nil {
+		return "", githubError(resp, err, ReasonRepoNotFound)
+	}
+	return strconv.FormatInt(created.GetID(), 10), nil
+}
 
-	_, resp, err := client.Repositories.GetBranch(ctx, g.owner, g.repo, g.reference)
+func (p *githubProvider) UpdateWebhook(ctx context.Context, repo *Repository, id string, hook Webhook) error {
+	hookID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return newError(ReasonWebhookNotFound, err)
+	}
+	_, resp, err := p.client.Repositories.EditHook(ctx, repo.Owner, repo.Name, hookID, githubHook(hook))
 	if err != nil {
-		g.logger.Error(err, "Unsuccessful response from Github API")
-		g.status = "False"
-		switch resp.StatusCode {
-		case 403:
-			g.reason = ReasonRateLimitExceeded
-		case 404:
-			g.reason = ReasonRepoNotFound
-		default:
-			g.reason = ReasonRepoNotReachable
+		return githubError(resp, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+func (p *githubProvider) DeleteWebhook(ctx context.Context, repo *Repository, id string) error {
+	hookID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return newError(ReasonWebhookNotFound, err)
+	}
+	resp, err := p.client.Repositories.DeleteHook(ctx, repo.Owner, repo.Name, hookID)
+	if err != nil {
+		return githubError(resp, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+// githubHook returns a webhook sending the push and pull request events as
+// JSON, signed with the secret of hook.
+func githubHook(hook Webhook) *github.Hook {
+	return &github.Hook{
+		Name:   github.String("web"),
+		Active: github.Bool(true),
+		Events: []string{"push", "pull_request"},
+		Config: map[string]interface{}{
+			"url":          hook.URL,
+			"content_type": "json",
+			"secret":       hook.Secret,
+			"insecure_ssl": "0",
+		},
+	}
+}
+
+func (p *githubProvider) SetCommitStatus(ctx context.Context, repo *Repository, sha string, status CommitStatus) error {
+	_, resp, err := p.client.Repositories.CreateStatus(ctx, repo.Owner, repo.Name, sha, &github.RepoStatus{
+		State:       github.String(string(status.State)),
+		Context:     github.String(status.Context),
+		Description: github.String(status.Description),
+		TargetURL:   optionalString(status.TargetURL),
+	})
+	if err != nil {
+		return githubError(resp, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+func (p *githubProvider) CreateDeployment(
+	ctx context.Context, repo *Repository, deployment Deployment,
+) (string, error) {
+	created, resp, err := p.client.Repositories.CreateDeployment(ctx, repo.Owner, repo.Name, &github.DeploymentRequest{
+		Ref:         github.String(deployment.SHA),
+		Environment: github.String(deployment.Environment),
+		Description: github.String("Deployed from " + deployment.Ref),
+		// The commit is deployed as is, whatever its other statuses
+		AutoMerge:        github.Bool(false),
+		RequiredContexts: &[]string{},
+	})
+	if err != nil {
+		return "", githubError(resp, err, ReasonRepoNotFound)
+	}
+	return strconv.FormatInt(created.GetID(), 10), nil
+}
+
+func (p *githubProvider) UpdateDeployment(
+	ctx context.Context, repo *Repository, id string, status DeploymentStatus,
+) error {
+	deploymentID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return newError(ReasonDeploymentNotFound, err)
+	}
+	_, resp, err := p.client.Repositories.CreateDeploymentStatus(ctx, repo.Owner, repo.Name, deploymentID,
+		&github.DeploymentStatusRequest{
+			State:          github.String(string(status.State)),
+			Description:    github.String(status.Description),
+			EnvironmentURL: optionalString(status.EnvironmentURL),
+		})
+	if err != nil {
+		return githubError(resp, err, ReasonDeploymentNotFound)
+	}
+	return nil
+}
+
+func (p *githubProvider) ListPullRequests(ctx context.Context, repo *Repository, base string) ([]PullRequest, error) {
+	opts := &github.PullRequestListOptions{State: "open", Base: base, ListOptions: github.ListOptions{PerPage: 100}}
+	var pullRequests []PullRequest
+	for {
+		page, resp, err := p.client.PullRequests.List(ctx, repo.Owner, repo.Name, opts)
+		if err != nil {
+			return nil, githubError(resp, err, ReasonRepoNotFound)
 		}
-		return
+		for _, pr := range page {
+			// The head repository of a pull request from a deleted fork is gone
+			if pr.GetHead().GetRepo() == nil {
+				continue
+			}
+			pullRequests = append(pullRequests, PullRequest{
+				Number:      pr.GetNumber(),
+				Title:       pr.GetTitle(),
+				URL:         pr.GetHTMLURL(),
+				HeadRepoURL: pr.GetHead().GetRepo().GetCloneURL(),
+				HeadRef:     pr.GetHead().GetRef(),
+				HeadSHA:     pr.GetHead().GetSHA(),
+			})
+		}
+		if resp.NextPage == 0 {
+			return pullRequests, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (p *githubProvider) CommentPullRequest(ctx context.Context, repo *Repository, number int, body string) error {
+	_, resp, err := p.client.Issues.CreateComment(ctx, repo.Owner, repo.Name, number,
+		&github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		return githubError(resp, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+// optionalString returns a pointer to s, or nil when it is empty.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// githubError maps an unsuccessful Github API response onto a GitConditionReason,
+// using notFound as the reason of a 404.
+func githubError(resp *github.Response, err error, notFound GitConditionReason) error {
+	if resp == nil {
+		return apiError(nil, err, notFound)
 	}
-	g.logger.Info("Successfully reached Github API!")
-	g.status, g.reason = metav1.ConditionTrue, ReasonSucceeded
+	return apiError(resp.Response, err, notFound)
 }
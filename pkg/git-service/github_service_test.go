@@ -0,0 +1,96 @@
+package gitservice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeGithubAPI serves the conformance repository through the subset of the
+// Github REST API used by the Github provider, rooted at /api/v3/.
+func newFakeGithubAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	repoPath := "/api/v3/repos/" + conformanceOwner + "/" + conformanceName
+	mux.HandleFunc(repoPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"name": conformanceName, "default_branch": conformanceBranch})
+	})
+	mux.HandleFunc(repoPath+"/branches/"+conformanceBranch, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"name":   conformanceBranch,
+			"commit": map[string]any{"sha": conformanceSHA},
+		})
+	})
+	mux.HandleFunc(repoPath+"/git/refs/tags/"+conformanceTag, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"ref":    "refs/tags/" + conformanceTag,
+			"object": map[string]any{"type": "tag", "sha": conformanceTagSHA},
+		})
+	})
+	mux.HandleFunc(repoPath+"/git/tags/"+conformanceTagSHA, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"tag":    conformanceTag,
+			"object": map[string]any{"type": "commit", "sha": conformanceSHA},
+		})
+	})
+	mux.HandleFunc(repoPath+"/commits/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != repoPath+"/commits/"+conformanceSHA {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		writeJSON(w, map[string]any{"sha": conformanceSHA})
+	})
+	mux.HandleFunc(repoPath+"/contents/", func(w http.ResponseWriter, r *http.Request) {
+		entries, _, ok := lookupConformanceTree(strings.TrimPrefix(r.URL.Path, repoPath+"/contents/"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		contents := []map[string]any{}
+		for _, entry := range entries {
+			contents = append(contents, map[string]any{"name": entry.Name, "path": entry.Path, "type": string(entry.Type)})
+		}
+		writeJSON(w, contents)
+	})
+	mux.HandleFunc(repoPath+"/contents/"+conformanceFile, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != conformanceBranch {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"type":     "file",
+			"name":     conformanceFile,
+			"path":     conformanceFile,
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString([]byte(conformanceContent)),
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestGithubProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeGithubAPI(t)
+		provider, err := newGithubProvider(ProviderOptions{
+			APIURL:      server.URL + "/api/v3/",
+			Credentials: testCredentials,
+			Logger:      testLogger,
+		})
+		require.NoError(t, err)
+		return providerFixture{
+			provider: provider,
+			gitURL:   "https://github.com/" + conformanceOwner + "/" + conformanceName + ".git",
+		}
+	})
+}
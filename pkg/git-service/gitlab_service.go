@@ -1,40 +1,394 @@
 package gitservice
 
 import (
-	"github.com/xanzy/go-gitlab"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	"github.com/xanzy/go-gitlab"
 )
 
-func isGLRepoReachable(g *GitService) {
+const gitlabHost = "gitlab.com"
+
+func init() {
+	RegisterProvider(Gitlab, newGitlabProvider, gitlabHost)
+}
+
+type gitlabProvider struct {
+	client *gitlab.Client
+	token  string
+	logger logr.Logger
+}
+
+func newGitlabProvider(opts ProviderOptions) (Provider, error) {
+	apiURL := opts.APIURL
+	if apiURL == "" && opts.Host != "" && opts.Host != gitlabHost {
+		// The client appends /api/v4/ to the base URL of self-managed instances.
+		apiURL = opts.hostURL()
+	}
+	var clientOpts []gitlab.ClientOptionFunc
+	if apiURL != "" {
+		clientOpts = append(clientOpts, gitlab.WithBaseURL(apiURL))
+	}
+	if opts.HTTPClient != nil {
+		// The HTTP client retries transient failures itself.
+		clientOpts = append(clientOpts, gitlab.WithHTTPClient(opts.HTTPClient), gitlab.WithCustomRetryMax(0))
+	}
+	// The Gitlab API only accepts tokens, so a basic-auth password is used as one.
+	token := opts.Credentials.accessToken()
+	client, err := gitlab.NewClient(token, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &gitlabProvider{client: client, token: token, logger: opts.Logger}, nil
+}
+
+// ParseURL accepts nested groups, so the owner of a Gitlab repository may
+// itself contain slashes, e.g. "group/subgroup".
+func (p *gitlabProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	if len(segments) < 2 {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	for _, segment := range segments {
+		if segment == "" || segment == "-" {
+			return nil, errors.New(ReasonInvalidGitURL.String())
+		}
+	}
+	last := len(segments) - 1
+	return &Repository{
+		Host:  normalizeHost(u.Host),
+		Owner: strings.Join(segments[:last], "/"),
+		Name:  segments[last],
+	}, nil
+}
 
-	if g.secretValue == "" {
-		g.logger.Error(nil, "Secret value not provided")
-		g.status, g.reason = metav1.ConditionFalse, ReasonAccessTokenRequired
-		return
+func (p *gitlabProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
 	}
+	p.logger.Info("Successfully reached Gitlab API!")
+	return nil
+}
 
-	client, err := gitlab.NewClient(g.secretValue)
+func (p *gitlabProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	if p.token == "" {
+		p.logger.Error(nil, "Secret value not provided")
+		return nil, newError(ReasonAccessTokenRequired, nil)
+	}
+	ref, err := resolveRef(ctx, reference,
+		func(ctx context.Context, branch string) (string, error) {
+			b, res, err := p.client.Branches.GetBranch(repo.FullName(), branch, gitlab.WithContext(ctx))
+			if err != nil {
+				return "", gitlabError(res, err, ReasonRepoNotFound)
+			}
+			return commitID(b.Commit), nil
+		},
+		func(ctx context.Context, tag string) (string, error) {
+			t, res, err := p.client.Tags.GetTag(repo.FullName(), tag, gitlab.WithContext(ctx))
+			if err != nil {
+				return "", gitlabError(res, err, ReasonRepoNotFound)
+			}
+			return commitID(t.Commit), nil
+		},
+		func(ctx context.Context, sha string) (string, error) {
+			c, res, err := p.client.Commits.GetCommit(repo.FullName(), sha, nil, gitlab.WithContext(ctx))
+			if err != nil {
+				return "", gitlabError(res, err, ReasonRepoNotFound)
+			}
+			return commitID(c), nil
+		},
+	)
 	if err != nil {
-		g.logger.Error(err, "Failed to create Gitlab client")
-		g.status, g.reason = metav1.ConditionFalse, ReasonRepoNotReachable
-		return
+		p.logger.Error(err, "Unsuccessful response from Gitlab API")
+		return nil, err
 	}
+	return ref, nil
+}
+
+func (p *gitlabProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	if p.token == "" {
+		p.logger.Error(nil, "Secret value not provided")
+		return "", newError(ReasonAccessTokenRequired, nil)
+	}
+	project, res, err := p.client.Projects.GetProject(repo.FullName(), nil, gitlab.WithContext(ctx))
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Gitlab API")
+		return "", gitlabError(res, err, ReasonRepoNotFound)
+	}
+	return project.DefaultBranch, nil
+}
 
-	_, res, err := client.Branches.GetBranch(g.owner+"/"+g.repo, g.reference)
+func (p *gitlabProvider) IsArchived(ctx context.Context, repo *Repository) (bool, error) {
+	project, res, err := p.client.Projects.GetProject(repo.FullName(), nil, gitlab.WithContext(ctx))
 	if err != nil {
-		g.logger.Error(err, "Unsuccessful response from Gitlab API")
-		g.status = metav1.ConditionFalse
-		switch res.StatusCode {
-		case 429:
-			g.reason = ReasonRateLimitExceeded
-		case 404:
-			g.reason = ReasonRepoNotFound
-		default:
-			g.reason = ReasonRepoNotReachable
+		p.logger.Error(err, "Unsuccessful response from Gitlab API")
+		return false, gitlabError(res, err, ReasonRepoNotFound)
+	}
+	return project.Archived, nil
+}
+
+func (p *gitlabProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	content, res, err := p.client.RepositoryFiles.GetRawFile(repo.FullName(), path,
+		&gitlab.GetRawFileOptions{Ref: gitlab.Ptr(reference)}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, gitlabError(res, err, ReasonFileNotFound)
+	}
+	return content, nil
+}
+
+func (p *gitlabProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	opts := &gitlab.ListTreeOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+		Path:        gitlab.Ptr(path),
+		Ref:         gitlab.Ptr(reference),
+	}
+	var entries []Entry
+	for {
+		nodes, res, err := p.client.Repositories.ListTree(repo.FullName(), opts, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, gitlabError(res, err, ReasonFileNotFound)
+		}
+		for _, node := range nodes {
+			entries = append(entries, newEntry(node.Path, node.Type == "tree"))
+		}
+		if res.NextPage == 0 {
+			break
 		}
-		return
+		opts.Page = res.NextPage
+	}
+	if len(entries) == 0 && path != "" {
+		// Gitlab lists files as empty directories, but directories cannot be empty in Git.
+		return nil, notADirectory(path)
+	}
+	return entries, nil
+}
+
+// commitID returns the SHA of commit, which Gitlab omits for some references.
+func commitID(commit *gitlab.Commit) string {
+	if commit == nil {
+		return ""
+	}
+	return commit.ID
+}
+
+// gitlabError maps an unsuccessful Gitlab API response onto a GitConditionReason,
+// using notFound as the reason of a 404.
+func (p *gitlabProvider) CreateWebhook(ctx context.Context, repo *Repository, hook Webhook) (string, error) {
+	if p.token == "" {
+		return "", newError(ReasonAccessTokenRequired, nil)
+	}
+	created, res, err := p.client.Projects.AddProjectHook(repo.FullName(), &gitlab.AddProjectHookOptions{
+		URL:                   gitlab.Ptr(hook.URL),
+		Token:                 gitlab.Ptr(hook.Secret),
+		PushEvents:            gitlab.Ptr(true),
+		TagPushEvents:         gitlab.Ptr(true),
+		MergeRequestsEvents:   gitlab.Ptr(true),
+		EnableSSLVerification: gitlab.Ptr(true),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", gitlabError(res, err, ReasonRepoNotFound)
+	}
+	return strconv.Itoa(created.ID), nil
+}
+
+func (p *gitlabProvider) UpdateWebhook(ctx context.Context, repo *Repository, id string, hook Webhook) error {
+	if p.token == "" {
+		return newError(ReasonAccessTokenRequired, nil)
+	}
+	hookID, err := strconv.Atoi(id)
+	if err != nil {
+		return newError(ReasonWebhookNotFound, err)
+	}
+	_, res, err := p.client.Projects.EditProjectHook(repo.FullName(), hookID, &gitlab.EditProjectHookOptions{
+		URL:                   gitlab.Ptr(hook.URL),
+		Token:                 gitlab.Ptr(hook.Secret),
+		PushEvents:            gitlab.Ptr(true),
+		TagPushEvents:         gitlab.Ptr(true),
+		MergeRequestsEvents:   gitlab.Ptr(true),
+		EnableSSLVerification: gitlab.Ptr(true),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return gitlabError(res, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+func (p *gitlabProvider) DeleteWebhook(ctx context.Context, repo *Repository, id string) error {
+	if p.token == "" {
+		return newError(ReasonAccessTokenRequired, nil)
+	}
+	hookID, err := strconv.Atoi(id)
+	if err != nil {
+		return newError(ReasonWebhookNotFound, err)
+	}
+	res, err := p.client.Projects.DeleteProjectHook(repo.FullName(), hookID, gitlab.WithContext(ctx))
+	if err != nil {
+		return gitlabError(res, err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+// gitlabStates are the Gitlab states of the commit states. Gitlab moves
+// statuses through running rather than pending.
+var gitlabStates = map[CommitState]gitlab.BuildStateValue{
+	CommitStatePending: gitlab.Running,
+	CommitStateSuccess: gitlab.Success,
+	CommitStateFailure: gitlab.Failed,
+}
+
+func (p *gitlabProvider) SetCommitStatus(ctx context.Context, repo *Repository, sha string, status CommitStatus) error {
+	if p.token == "" {
+		return newError(ReasonAccessTokenRequired, nil)
+	}
+	opts := &gitlab.SetCommitStatusOptions{
+		State:       gitlabStates[status.State],
+		Name:        gitlab.Ptr(status.Context),
+		Description: gitlab.Ptr(status.Description),
+		TargetURL:   optionalString(status.TargetURL),
+	}
+	_, res, err := p.client.Commits.SetCommitStatus(repo.FullName(), sha, opts, gitlab.WithContext(ctx))
+	if err != nil && res != nil && res.StatusCode == http.StatusBadRequest &&
+		strings.Contains(err.Error(), "Cannot transition status") {
+		// Gitlab rejects setting the state the status already has
+		return nil
+	}
+	if err != nil {
+		return gitlabError(res, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+// gitlabDeploymentStates are the Gitlab states of the deployment states.
+var gitlabDeploymentStates = map[DeploymentState]gitlab.DeploymentStatusValue{
+	DeploymentStateInProgress: gitlab.DeploymentStatusRunning,
+	DeploymentStateSuccess:    gitlab.DeploymentStatusSuccess,
+	DeploymentStateFailure:    gitlab.DeploymentStatusFailed,
+}
+
+// CreateDeployment creates a deployment record, and its environment when it
+// does not exist yet.
+func (p *gitlabProvider) CreateDeployment(
+	ctx context.Context, repo *Repository, deployment Deployment,
+) (string, error) {
+	if p.token == "" {
+		return "", newError(ReasonAccessTokenRequired, nil)
+	}
+	created, res, err := p.client.Deployments.CreateProjectDeployment(repo.FullName(),
+		&gitlab.CreateProjectDeploymentOptions{
+			Environment: gitlab.Ptr(deployment.Environment),
+			Ref:         gitlab.Ptr(deployment.Ref),
+			SHA:         gitlab.Ptr(deployment.SHA),
+			Tag:         gitlab.Ptr(deployment.Tag),
+			Status:      gitlab.Ptr(gitlab.DeploymentStatusCreated),
+		}, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", gitlabError(res, err, ReasonRepoNotFound)
+	}
+	return strconv.Itoa(created.ID), nil
+}
+
+// UpdateDeployment sets the state of the deployment record. Gitlab keeps the
+// URL on the environment rather than on the deployment, it is updated there.
+func (p *gitlabProvider) UpdateDeployment(
+	ctx context.Context, repo *Repository, id string, status DeploymentStatus,
+) error {
+	if p.token == "" {
+		return newError(ReasonAccessTokenRequired, nil)
+	}
+	deploymentID, err := strconv.Atoi(id)
+	if err != nil {
+		return newError(ReasonDeploymentNotFound, err)
+	}
+	updated, res, err := p.client.Deployments.UpdateProjectDeployment(repo.FullName(), deploymentID,
+		&gitlab.UpdateProjectDeploymentOptions{Status: gitlab.Ptr(gitlabDeploymentStates[status.State])},
+		gitlab.WithContext(ctx))
+	if err != nil && res != nil && res.StatusCode == http.StatusBadRequest &&
+		strings.Contains(err.Error(), "Cannot transition status") {
+		// Gitlab rejects setting the state the deployment already has
+		return nil
+	}
+	if err != nil {
+		return gitlabError(res, err, ReasonDeploymentNotFound)
+	}
+	environment := updated.Environment
+	if status.EnvironmentURL == "" || environment == nil || environment.ExternalURL == status.EnvironmentURL {
+		return nil
+	}
+	_, res, err = p.client.Environments.EditEnvironment(repo.FullName(), environment.ID,
+		&gitlab.EditEnvironmentOptions{ExternalURL: gitlab.Ptr(status.EnvironmentURL)}, gitlab.WithContext(ctx))
+	if err != nil {
+		return gitlabError(res, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+func (p *gitlabProvider) ListPullRequests(ctx context.Context, repo *Repository, base string) ([]PullRequest, error) {
+	opts := &gitlab.ListProjectMergeRequestsOptions{
+		ListOptions:  gitlab.ListOptions{PerPage: 100},
+		State:        gitlab.Ptr("opened"),
+		TargetBranch: gitlab.Ptr(base),
+	}
+	// The clone URLs of the projects merge requests are opened from, by ID
+	repoURLs := map[int]string{}
+	var pullRequests []PullRequest
+	for {
+		page, res, err := p.client.MergeRequests.ListProjectMergeRequests(repo.FullName(), opts,
+			gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, gitlabError(res, err, ReasonRepoNotFound)
+		}
+		for _, mr := range page {
+			repoURL, ok := repoURLs[mr.SourceProjectID]
+			if !ok {
+				project, res, err := p.client.Projects.GetProject(mr.SourceProjectID, nil, gitlab.WithContext(ctx))
+				if err != nil && res != nil && res.StatusCode == http.StatusNotFound {
+					// The fork the merge request is opened from is gone, or private
+					continue
+				}
+				if err != nil {
+					return nil, gitlabError(res, err, ReasonRepoNotFound)
+				}
+				repoURL = project.HTTPURLToRepo
+				repoURLs[mr.SourceProjectID] = repoURL
+			}
+			pullRequests = append(pullRequests, PullRequest{
+				Number:      mr.IID,
+				Title:       mr.Title,
+				URL:         mr.WebURL,
+				HeadRepoURL: repoURL,
+				HeadRef:     mr.SourceBranch,
+				HeadSHA:     mr.SHA,
+			})
+		}
+		if res.NextPage == 0 {
+			return pullRequests, nil
+		}
+		opts.Page = res.NextPage
+	}
+}
+
+func (p *gitlabProvider) CommentPullRequest(ctx context.Context, repo *Repository, number int, body string) error {
+	if p.token == "" {
+		return newError(ReasonAccessTokenRequired, nil)
+	}
+	_, res, err := p.client.Notes.CreateMergeRequestNote(repo.FullName(), number,
+		&gitlab.CreateMergeRequestNoteOptions{Body: gitlab.Ptr(body)}, gitlab.WithContext(ctx))
+	if err != nil {
+		return gitlabError(res, err, ReasonRepoNotFound)
+	}
+	return nil
+}
+
+func gitlabError(res *gitlab.Response, err error, notFound GitConditionReason) error {
+	if res == nil {
+		return apiError(nil, err, notFound)
 	}
-	g.logger.Info("Successfully reached Gitlab API!")
-	g.status, g.reason = metav1.ConditionTrue, ReasonSucceeded
+	return apiError(res.Response, err, notFound)
 }
@@ -0,0 +1,94 @@
+package gitservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+)
+
+// newFakeGitlabAPI serves the conformance repository through the subset of the
+// Gitlab REST API used by the Gitlab provider. Requests without the
+// conformance token are rejected.
+func newFakeGitlabAPI(t *testing.T) *httptest.Server {
+	projectPath := "/api/v4/projects/" + gitlab.PathEscape(conformanceOwner+"/"+conformanceName)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != conformanceToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case projectPath:
+			writeJSON(w, map[string]any{"path": conformanceName, "default_branch": conformanceBranch})
+		case projectPath + "/repository/branches/" + conformanceBranch:
+			writeJSON(w, map[string]any{
+				"name":   conformanceBranch,
+				"commit": map[string]any{"id": conformanceSHA},
+			})
+		case projectPath + "/repository/tags/" + conformanceTag:
+			writeJSON(w, map[string]any{
+				"name":   conformanceTag,
+				"target": conformanceTagSHA,
+				"commit": map[string]any{"id": conformanceSHA},
+			})
+		case projectPath + "/repository/commits/" + conformanceSHA:
+			writeJSON(w, map[string]any{"id": conformanceSHA})
+		case projectPath + "/repository/tree":
+			entries, _, ok := lookupConformanceTree(r.URL.Query().Get("path"))
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			nodes := []map[string]any{}
+			for _, entry := range entries {
+				nodeType := "blob"
+				if entry.Type == EntryTypeDir {
+					nodeType = "tree"
+				}
+				nodes = append(nodes, map[string]any{"name": entry.Name, "path": entry.Path, "type": nodeType})
+			}
+			writeJSON(w, nodes)
+		case projectPath + "/repository/files/" + gitlab.PathEscape(conformanceFile) + "/raw":
+			if r.URL.Query().Get("ref") != conformanceBranch {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(conformanceContent))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGitlabProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeGitlabAPI(t)
+		provider, err := newGitlabProvider(ProviderOptions{
+			APIURL:      server.URL,
+			Credentials: testCredentials,
+			Logger:      testLogger,
+		})
+		require.NoError(t, err)
+		return providerFixture{
+			provider: provider,
+			gitURL:   "https://gitlab.com/" + conformanceOwner + "/" + conformanceName,
+		}
+	})
+}
+
+func TestGitlabProviderNestedGroups(t *testing.T) {
+	provider, err := newGitlabProvider(ProviderOptions{Logger: testLogger})
+	require.NoError(t, err)
+	u, err := url.Parse("https://gitlab.com/group/subgroup/repo.git")
+	require.NoError(t, err)
+	repo, err := provider.ParseURL(u)
+	require.NoError(t, err)
+	assert.Equal(t, "group/subgroup", repo.Owner)
+	assert.Equal(t, "repo", repo.Name)
+}
@@ -0,0 +1,32 @@
+package gitservice
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// probeCacheRequests counts the probes answered from the cache ("hit"),
+	// sent to the provider ("miss") or collapsed into a concurrent one ("shared").
+	probeCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consoleapplication_git_probe_cache_requests_total",
+		Help: "Number of Git probes by cache result: hit, miss or shared.",
+	}, []string{"result"})
+
+	// conditionalRequests counts the revalidated responses by outcome.
+	conditionalRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consoleapplication_git_conditional_requests_total",
+		Help: "Number of conditional Git API requests by result: not_modified or modified.",
+	}, []string{"result"})
+
+	// probeCacheTTL is the duration probe results are reused for.
+	probeCacheTTL = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "consoleapplication_git_probe_cache_ttl_seconds",
+		Help: "Duration Git probe results are reused for, in seconds.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(probeCacheRequests, conditionalRequests, probeCacheTTL)
+	probeCacheTTL.Set(DefaultProbeCacheTTL.Seconds())
+}
@@ -0,0 +1,193 @@
+package gitservice
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// refAdvertisement is the list of references a Git server advertises when a
+// client connects to git-upload-pack.
+type refAdvertisement struct {
+	// refs maps full reference names to the commit they point at. Annotated
+	// tags are peeled, so they map to the tagged commit.
+	refs map[string]string
+
+	// capabilities are the capabilities announced with the first reference.
+	capabilities []string
+}
+
+// symref returns the target of the symbolic reference name, e.g. the branch
+// HEAD points at, if the server announced it.
+func (a *refAdvertisement) symref(name string) (string, bool) {
+	for _, capability := range a.capabilities {
+		if target, ok := strings.CutPrefix(capability, "symref="+name+":"); ok {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// defaultBranch returns the branch HEAD points at. Servers that do not announce
+// the symbolic reference are assumed to point HEAD at the first branch on the
+// same commit, as Git clients do.
+func (a *refAdvertisement) defaultBranch() (string, bool) {
+	if target, ok := a.symref("HEAD"); ok {
+		return strings.TrimPrefix(target, "refs/heads/"), true
+	}
+	head, ok := a.refs["HEAD"]
+	if !ok {
+		return "", false
+	}
+	var branches []string
+	for name, sha := range a.refs {
+		if branch, ok := strings.CutPrefix(name, "refs/heads/"); ok && sha == head {
+			branches = append(branches, branch)
+		}
+	}
+	if len(branches) == 0 {
+		return "", false
+	}
+	sort.Strings(branches)
+	return branches[0], true
+}
+
+// resolve resolves reference as a branch, then a tag, then a commit. reference
+// may also be a full reference name such as "refs/heads/main". Only commits
+// at the tip of an advertised reference can be verified; any other full commit
+// SHA is returned as Unverified, as checking it would require fetching objects.
+// Abbreviated SHAs must match a single advertised commit.
+func (a *refAdvertisement) resolve(reference string) (*Ref, error) {
+	for _, name := range []string{"refs/heads/" + reference, "refs/tags/" + reference, reference} {
+		if sha, ok := a.refs[name]; ok {
+			refType := RefTypeBranch
+			if strings.HasPrefix(name, "refs/tags/") {
+				refType = RefTypeTag
+			}
+			return &Ref{Name: reference, Type: refType, SHA: sha}, nil
+		}
+	}
+	if !isCommitSHA(reference) {
+		return nil, fmt.Errorf("reference %q not found", reference)
+	}
+	matches := map[string]bool{}
+	for _, sha := range a.refs {
+		if strings.HasPrefix(sha, strings.ToLower(reference)) {
+			matches[sha] = true
+		}
+	}
+	switch {
+	case len(matches) > 1:
+		return nil, fmt.Errorf("abbreviated commit %q is ambiguous, it matches %d commits", reference, len(matches))
+	case len(matches) == 1:
+		for sha := range matches {
+			return &Ref{Name: reference, Type: RefTypeCommit, SHA: sha}, nil
+		}
+	case isFullCommitSHA(reference):
+		return &Ref{Name: reference, Type: RefTypeCommit, SHA: strings.ToLower(reference), Unverified: true}, nil
+	}
+	return nil, fmt.Errorf("commit %q not found at the tip of a branch or tag", reference)
+}
+
+// readPktLine reads one pkt-line from r. A flush packet is returned as nil.
+func readPktLine(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return nil, errors.New("invalid pkt-line length")
+	}
+	switch {
+	case length == 0:
+		return nil, nil
+	case length < 4:
+		return nil, fmt.Errorf("invalid pkt-line length %d", length)
+	}
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// parseRefAdvertisement parses the pkt-line encoded reference advertisement of
+// git-upload-pack. The "# service=git-upload-pack" section sent by smart HTTP
+// servers before the references is skipped.
+func parseRefAdvertisement(r io.Reader) (*refAdvertisement, error) {
+	br := bufio.NewReader(r)
+	adv := &refAdvertisement{refs: map[string]string{}}
+	first, announced := true, false
+	for {
+		line, err := readPktLine(br)
+		if err != nil {
+			return nil, err
+		}
+		if line == nil {
+			if announced {
+				// End of the service announcement, the references follow.
+				announced = false
+				continue
+			}
+			return adv, nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if bytes.HasPrefix(line, []byte("# service=")) {
+			announced = true
+			continue
+		}
+		if bytes.HasPrefix(line, []byte("ERR ")) {
+			return nil, errors.New(string(line[4:]))
+		}
+		if first {
+			var caps []byte
+			line, caps, _ = bytes.Cut(line, []byte{0})
+			adv.capabilities = strings.Fields(string(caps))
+			first = false
+		}
+		// The lines are never quoted in the errors, which are reported in the
+		// status, as the server could be any internal service.
+		sha, name, ok := strings.Cut(string(line), " ")
+		if !ok || !isFullCommitSHA(sha) {
+			return nil, errors.New("invalid reference line")
+		}
+		// Empty repositories advertise their capabilities on a placeholder.
+		if name != "capabilities^{}" {
+			adv.addRef(name, sha)
+		}
+	}
+}
+
+// parseDumbRefs parses the info/refs file served by dumb HTTP servers, which
+// lists one "<sha>\t<name>" pair per line.
+func parseDumbRefs(r io.Reader) (*refAdvertisement, error) {
+	adv := &refAdvertisement{refs: map[string]string{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		sha, name, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || !isFullCommitSHA(sha) {
+			return nil, errors.New("invalid reference line")
+		}
+		adv.addRef(name, sha)
+	}
+	return adv, scanner.Err()
+}
+
+func (a *refAdvertisement) addRef(name, sha string) {
+	if tag, ok := strings.CutSuffix(name, "^{}"); ok {
+		a.refs[tag] = sha
+		return
+	}
+	if _, ok := a.refs[name]; !ok {
+		a.refs[name] = sha
+	}
+}
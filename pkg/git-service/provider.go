@@ -0,0 +1,253 @@
+package gitservice
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Provider is implemented by every Git hosting service GitService can talk to.
+// Providers add themselves to the registry with RegisterProvider, usually from
+// an init function in the file implementing them.
+type Provider interface {
+	// ParseURL extracts the repository coordinates from a Git URL hosted by the provider.
+	ParseURL(u *url.URL) (*Repository, error)
+
+	// IsRepoReachable returns nil if the repository exists and the reference can be accessed.
+	IsRepoReachable(ctx context.Context, repo *Repository, reference string) error
+
+	// ResolveRef resolves the reference, looked up as a branch, then a tag, then
+	// a commit SHA, to the commit it currently points at.
+	ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error)
+
+	// DefaultBranch returns the name of the default branch of the repository.
+	DefaultBranch(ctx context.Context, repo *Repository) (string, error)
+
+	// GetFile returns the content of the file at path for the given reference.
+	GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error)
+
+	// ListDirectory returns the entries of the directory at path for the given
+	// reference. The empty path is the root of the repository. A missing path,
+	// or one that is not a directory, is reported with ReasonFileNotFound.
+	ListDirectory(ctx context.Context, repo *Repository, reference, path string) ([]Entry, error)
+}
+
+// ArchiveProvider is implemented by the providers whose API tells whether a
+// repository is archived, which readable repositories do not reveal otherwise.
+type ArchiveProvider interface {
+	// IsArchived returns whether the repository is archived and read-only.
+	IsArchived(ctx context.Context, repo *Repository) (bool, error)
+}
+
+// ProviderOptions holds the settings a ProviderFactory builds a Provider from.
+type ProviderOptions struct {
+	// Host is the normalized hostname of the repository, see normalizeHost.
+	Host string
+
+	// Port is the port of the HTTPS Git URL of the repository, if any. The
+	// conventional self-hosted endpoints are served on it.
+	Port string
+
+	// APIURL is the base URL of the provider API. When empty, the public endpoint
+	// is used for the public host and the conventional self-hosted endpoint otherwise.
+	APIURL string
+
+	// Credentials are used to authenticate against the provider.
+	Credentials Credentials
+
+	// Logger is the logger used by the provider.
+	Logger logr.Logger
+
+	// HTTPClient sends the requests of the provider. newProvider sets a client
+	// recording the rate limits of the host in the operator-wide budget.
+	HTTPClient *http.Client
+
+	// Timeout bounds each attempt of a request to the host, 0 disables it.
+	Timeout time.Duration
+
+	// Retries is the number of retries of a request failing transiently.
+	Retries int
+
+	// AllowInsecureCredentials lets the credentials be sent to plain http:// URLs.
+	AllowInsecureCredentials bool
+}
+
+// ProviderFactory creates a Provider from the given options.
+type ProviderFactory func(opts ProviderOptions) (Provider, error)
+
+// Repository identifies a repository hosted on a Git provider.
+type Repository struct {
+	// Host is the hostname of the Git provider, without the "www." prefix.
+	Host string
+
+	// Owner is the user, organization or group owning the repository.
+	Owner string
+
+	// Project is the project containing the repository, for providers that
+	// nest repositories in projects below the owner, like Azure DevOps.
+	Project string
+
+	// Name is the name of the repository.
+	Name string
+
+	// CloneURL is the URL Git clients fetch the repository from. It is only set
+	// by providers speaking the Git protocol rather than a provider API.
+	CloneURL string
+}
+
+// FullName returns the "owner/name" or "owner/project/name" path of the repository.
+func (r *Repository) FullName() string {
+	if r.Project != "" {
+		return r.Owner + "/" + r.Project + "/" + r.Name
+	}
+	return r.Owner + "/" + r.Name
+}
+
+// Ref is a reference resolved by a Provider.
+type Ref struct {
+	// Name is the reference as requested, e.g. the branch name.
+	Name string
+
+	// Type is the kind of reference Name resolved to.
+	Type RefType
+
+	// SHA is the full hash of the commit the reference points at.
+	SHA string
+
+	// Unverified reports that the commit SHA was accepted without checking
+	// that it exists, as the Git protocol only advertises the commits at the
+	// tip of the branches and tags.
+	Unverified bool
+}
+
+// EntryType is the type of a directory entry.
+type EntryType string
+
+const (
+	// EntryTypeFile is a file, including symbolic links and submodules.
+	EntryTypeFile EntryType = "file"
+
+	// EntryTypeDir is a directory.
+	EntryTypeDir EntryType = "dir"
+)
+
+// Entry is a file or directory listed by Provider.ListDirectory.
+type Entry struct {
+	// Name is the last segment of Path.
+	Name string
+
+	// Path is the path of the entry from the root of the repository.
+	Path string
+
+	// Type is the type of the entry.
+	Type EntryType
+}
+
+// newEntry returns the entry at path, which is a directory if isDir is true.
+func newEntry(entryPath string, isDir bool) Entry {
+	entryPath = strings.Trim(entryPath, "/")
+	entry := Entry{Name: path.Base(entryPath), Path: entryPath, Type: EntryTypeFile}
+	if isDir {
+		entry.Type = EntryTypeDir
+	}
+	return entry
+}
+
+// notADirectory returns the error of ListDirectory for a path that is a file.
+func notADirectory(dirPath string) error {
+	return newError(ReasonFileNotFound, fmt.Errorf("%s is not a directory", dirPath))
+}
+
+var (
+	registryMu  sync.RWMutex
+	factories   = map[GitProvider]ProviderFactory{}
+	hostTypes   = map[string]GitProvider{}
+	hostConfigs = map[string]HostConfig{}
+)
+
+// RegisterProvider makes a provider available under gitType and associates the
+// given hosts with it. Registering an existing type replaces its factory.
+func RegisterProvider(gitType GitProvider, factory ProviderFactory, hosts ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	factories[gitType] = factory
+	for _, host := range hosts {
+		hostTypes[normalizeHost(host)] = gitType
+	}
+}
+
+// lookupProvider returns the factory registered for gitType.
+func lookupProvider(gitType GitProvider) (ProviderFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := factories[gitType]
+	return factory, ok
+}
+
+// providerTypeForHost returns the provider type registered for host, or Unknown.
+func providerTypeForHost(host string) GitProvider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if gitType, ok := hostTypes[normalizeHost(host)]; ok {
+		return gitType
+	}
+	return Unknown
+}
+
+// newProvider creates the provider registered for gitType, pointing it at the
+// API URL configured for opts.Host unless opts.APIURL is already set.
+func newProvider(gitType GitProvider, opts ProviderOptions) (Provider, error) {
+	factory, ok := lookupProvider(gitType)
+	if !ok {
+		return nil, fmt.Errorf("no provider registered for %q", gitType)
+	}
+	config, ok := hostConfigFor(opts.Host)
+	if ok && opts.APIURL == "" {
+		opts.APIURL = config.APIURL
+	}
+	// The zero HostConfig holds the defaults of the hosts without configuration.
+	opts.Timeout, opts.Retries = config.requestTimeout(), config.requestRetries()
+	opts.AllowInsecureCredentials = config.AllowInsecureCredentials
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = newHTTPClient(opts)
+	}
+	return factory(opts)
+}
+
+// hostURL returns the HTTPS URL of the host of the repository, with its port.
+func (opts ProviderOptions) hostURL() string {
+	if opts.Port == "" {
+		return "https://" + opts.Host
+	}
+	return "https://" + net.JoinHostPort(opts.Host, opts.Port)
+}
+
+// normalizeHost lowercases host and strips the port and "www." prefix.
+func normalizeHost(host string) string {
+	host = strings.ToLower(host)
+	if h, _, found := strings.Cut(host, ":"); found {
+		host = h
+	}
+	return strings.TrimPrefix(host, "www.")
+}
+
+// splitRepoPath splits the path of a Git URL into its segments, dropping the
+// ".git" suffix of the last one.
+func splitRepoPath(u *url.URL) []string {
+	path := strings.Trim(u.Path, "/")
+	if path == "" {
+		return nil
+	}
+	segments := strings.Split(path, "/")
+	last := len(segments) - 1
+	segments[last] = strings.TrimSuffix(segments[last], ".git")
+	return segments
+}
@@ -0,0 +1,265 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The repository served by every fake provider API used in the conformance suite.
+const (
+	conformanceOwner  = "hello"
+	conformanceName   = "world"
+	conformanceBranch = "main"
+	conformanceSHA    = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	conformanceTag    = "v1.0.0"
+	// conformanceTagSHA is the object of the annotated conformanceTag, which
+	// points at conformanceSHA.
+	conformanceTagSHA     = "9fceb02d0ae598e95dc970b74767f19372d61af8"
+	conformanceMissingSHA = "0123456789abcdef0123456789abcdef01234567"
+	conformanceFile       = "README.md"
+	conformanceContent    = "hello world\n"
+	conformanceToken      = "s3cr3t"
+)
+
+// conformanceTree maps the directories of the conformance repository to their entries.
+var conformanceTree = map[string][]Entry{
+	"": {
+		{Name: conformanceFile, Path: conformanceFile, Type: EntryTypeFile},
+		{Name: "app", Path: "app", Type: EntryTypeDir},
+	},
+	"app": {
+		{Name: "package.json", Path: "app/package.json", Type: EntryTypeFile},
+	},
+}
+
+// lookupConformanceTree returns the entries of the directory at path in the
+// conformance repository, isFile if path is a file, or neither if it does not exist.
+func lookupConformanceTree(path string) (entries []Entry, isFile, ok bool) {
+	path = strings.Trim(path, "/")
+	if entries, ok := conformanceTree[path]; ok {
+		return entries, false, true
+	}
+	for _, dirEntries := range conformanceTree {
+		for _, entry := range dirEntries {
+			if entry.Path == path {
+				return nil, true, true
+			}
+		}
+	}
+	return nil, false, false
+}
+
+var (
+	testLogger      = logr.Discard()
+	testCredentials = Credentials{Token: conformanceToken}
+)
+
+// providerFixture is a provider under test backed by a fake of its API.
+type providerFixture struct {
+	provider Provider
+	// gitURL is the URL of the conformance repository on the fake.
+	gitURL string
+}
+
+// runProviderConformance runs the checks every Provider implementation must pass.
+// newFixture must return a provider authenticated with conformanceToken.
+func runProviderConformance(t *testing.T, newFixture func(t *testing.T) providerFixture) {
+	ctx := context.Background()
+
+	parse := func(t *testing.T, f providerFixture) *Repository {
+		u, err := url.Parse(f.gitURL)
+		require.NoError(t, err)
+		repo, err := f.provider.ParseURL(u)
+		require.NoError(t, err)
+		return repo
+	}
+
+	t.Run("ParseURL", func(t *testing.T) {
+		f := newFixture(t)
+		repo := parse(t, f)
+		assert.Equal(t, conformanceOwner, repo.Owner)
+		assert.Equal(t, conformanceName, repo.Name)
+	})
+
+	t.Run("ParseURL rejects a URL without repository", func(t *testing.T) {
+		f := newFixture(t)
+		u, err := url.Parse(f.gitURL)
+		require.NoError(t, err)
+		u.Path = "/"
+		_, err = f.provider.ParseURL(u)
+		assert.Error(t, err)
+	})
+
+	t.Run("IsRepoReachable", func(t *testing.T) {
+		f := newFixture(t)
+		assert.NoError(t, f.provider.IsRepoReachable(ctx, parse(t, f), conformanceBranch))
+	})
+
+	t.Run("Missing reference", func(t *testing.T) {
+		f := newFixture(t)
+		err := f.provider.IsRepoReachable(ctx, parse(t, f), "missing")
+		assert.Equal(t, ReasonRepoNotFound, ReasonForError(err))
+	})
+
+	t.Run("Missing repository", func(t *testing.T) {
+		f := newFixture(t)
+		f.gitURL = strings.Replace(f.gitURL, "/"+conformanceName, "/missing", 1)
+		err := f.provider.IsRepoReachable(ctx, parse(t, f), conformanceBranch)
+		assert.Equal(t, ReasonRepoNotFound, ReasonForError(err))
+	})
+
+	t.Run("ResolveRef", func(t *testing.T) {
+		f := newFixture(t)
+		ref, err := f.provider.ResolveRef(ctx, parse(t, f), conformanceBranch)
+		require.NoError(t, err)
+		assert.Equal(t, conformanceBranch, ref.Name)
+		assert.Equal(t, RefTypeBranch, ref.Type)
+		assert.Equal(t, conformanceSHA, ref.SHA)
+	})
+
+	t.Run("ResolveRef tag", func(t *testing.T) {
+		f := newFixture(t)
+		ref, err := f.provider.ResolveRef(ctx, parse(t, f), conformanceTag)
+		require.NoError(t, err)
+		assert.Equal(t, RefTypeTag, ref.Type)
+		assert.Equal(t, conformanceSHA, ref.SHA, "annotated tags resolve to the tagged commit")
+	})
+
+	t.Run("ResolveRef commit", func(t *testing.T) {
+		f := newFixture(t)
+		ref, err := f.provider.ResolveRef(ctx, parse(t, f), conformanceSHA)
+		require.NoError(t, err)
+		assert.Equal(t, RefTypeCommit, ref.Type)
+		assert.Equal(t, conformanceSHA, ref.SHA)
+	})
+
+	t.Run("Missing abbreviated commit", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.provider.ResolveRef(ctx, parse(t, f), conformanceMissingSHA[:7])
+		assert.Equal(t, ReasonRepoNotFound, ReasonForError(err))
+	})
+
+	t.Run("DefaultBranch", func(t *testing.T) {
+		f := newFixture(t)
+		branch, err := f.provider.DefaultBranch(ctx, parse(t, f))
+		require.NoError(t, err)
+		assert.Equal(t, conformanceBranch, branch)
+	})
+
+	t.Run("IsArchived", func(t *testing.T) {
+		f := newFixture(t)
+		provider, ok := f.provider.(ArchiveProvider)
+		if !ok {
+			t.Skip("the provider cannot tell archived repositories")
+		}
+		archived, err := provider.IsArchived(ctx, parse(t, f))
+		require.NoError(t, err)
+		assert.False(t, archived)
+	})
+
+	t.Run("Missing repository default branch", func(t *testing.T) {
+		f := newFixture(t)
+		f.gitURL = strings.Replace(f.gitURL, "/"+conformanceName, "/missing", 1)
+		_, err := f.provider.DefaultBranch(ctx, parse(t, f))
+		assert.Equal(t, ReasonRepoNotFound, ReasonForError(err))
+	})
+
+	t.Run("GetFile", func(t *testing.T) {
+		f := newFixture(t)
+		content, err := f.provider.GetFile(ctx, parse(t, f), conformanceBranch, conformanceFile)
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skip("provider cannot read files")
+		}
+		require.NoError(t, err)
+		assert.Equal(t, conformanceContent, string(content))
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.provider.GetFile(ctx, parse(t, f), conformanceBranch, "missing.txt")
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skip("provider cannot read files")
+		}
+		assert.Equal(t, ReasonFileNotFound, ReasonForError(err))
+	})
+
+	t.Run("ListDirectory", func(t *testing.T) {
+		f := newFixture(t)
+		runListDirectoryConformance(t, f, parse(t, f))
+	})
+}
+
+// runListDirectoryConformance checks ListDirectory against conformanceTree.
+func runListDirectoryConformance(t *testing.T, f providerFixture, repo *Repository) {
+	ctx := context.Background()
+	if _, err := f.provider.ListDirectory(ctx, repo, conformanceBranch, ""); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("provider cannot list directories")
+	}
+	for _, dir := range []string{"", "app"} {
+		entries, err := f.provider.ListDirectory(ctx, repo, conformanceBranch, dir)
+		require.NoError(t, err, dir)
+		assert.ElementsMatch(t, conformanceTree[dir], entries, dir)
+	}
+	for _, path := range []string{"missing", conformanceFile} {
+		_, err := f.provider.ListDirectory(ctx, repo, conformanceBranch, path)
+		assert.Equal(t, ReasonFileNotFound, ReasonForError(err), path)
+	}
+}
+
+type fakeProvider struct{}
+
+func (fakeProvider) ParseURL(u *url.URL) (*Repository, error) {
+	return &Repository{Host: u.Host, Owner: "fake", Name: "repo"}, nil
+}
+
+func (fakeProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	return nil
+}
+
+func (fakeProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	return &Ref{Name: reference}, nil
+}
+
+func (fakeProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	return "main", nil
+}
+
+func (fakeProvider) ListDirectory(ctx context.Context, repo *Repository, reference, path string) ([]Entry, error) {
+	return nil, newError(ReasonFileNotFound, errors.New(path))
+}
+
+func (fakeProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	return nil, newError(ReasonFileNotFound, errors.New(path))
+}
+
+func TestRegisterProvider(t *testing.T) {
+	const fakeType GitProvider = "fake"
+	RegisterProvider(fakeType, func(ProviderOptions) (Provider, error) {
+		return fakeProvider{}, nil
+	}, "git.example.com")
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		delete(factories, fakeType)
+		delete(hostTypes, "git.example.com")
+	})
+
+	assert.Equal(t, fakeType, identifyGitType("https://git.example.com/any/repo"))
+	assert.Equal(t, fakeType, identifyGitType("https://WWW.git.example.com:8443/any/repo"))
+
+	status, reason := New("https://git.example.com/any/repo", "main", Credentials{}, testLogger).IsRepoReachable(context.Background())
+	assert.Equal(t, "True", string(status))
+	assert.Equal(t, ReasonSucceeded, reason)
+}
+
+func TestReasonForError(t *testing.T) {
+	assert.Equal(t, ReasonRateLimitExceeded, ReasonForError(newError(ReasonRateLimitExceeded, errors.New("403"))))
+	assert.Equal(t, ReasonRepoNotReachable, ReasonForError(errors.New("boom")))
+}
@@ -0,0 +1,71 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PullRequest is an open pull request, or merge request on Gitlab.
+type PullRequest struct {
+	// Number identifies the pull request in its repository.
+	Number int
+
+	// Title is the title of the pull request.
+	Title string
+
+	// URL is the page of the pull request.
+	URL string
+
+	// HeadRepoURL is the repository the pull request is opened from, a fork
+	// or the repository itself.
+	HeadRepoURL string
+
+	// HeadRef is the branch the pull request is opened from.
+	HeadRef string
+
+	// HeadSHA is the latest commit of the pull request. Bitbucket Cloud only
+	// gives its abbreviated hash.
+	HeadSHA string
+}
+
+// PullRequestProvider is implemented by the providers able to list and
+// comment on the pull requests of a repository.
+type PullRequestProvider interface {
+	// ListPullRequests returns the open pull requests targeting the base branch.
+	ListPullRequests(ctx context.Context, repo *Repository, base string) ([]PullRequest, error)
+
+	// CommentPullRequest adds a comment to the pull request with number.
+	CommentPullRequest(ctx context.Context, repo *Repository, number int, body string) error
+}
+
+// pullRequestProvider returns the provider of the repository when it serves pull requests.
+func (g *GitService) pullRequestProvider() (PullRequestProvider, error) {
+	if g.provider == nil {
+		return nil, newError(g.reason, errors.New("the Git URL cannot be used"))
+	}
+	provider, ok := g.provider.(PullRequestProvider)
+	if !ok {
+		return nil, newError(ReasonUnsupportedGitType, fmt.Errorf("%s repositories have no pull requests", g.gitType))
+	}
+	return provider, nil
+}
+
+// ListPullRequests returns the open pull requests of the repository targeting
+// the base branch.
+func (g *GitService) ListPullRequests(ctx context.Context, base string) ([]PullRequest, error) {
+	provider, err := g.pullRequestProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.ListPullRequests(ctx, g.repository, base)
+}
+
+// CommentPullRequest adds a comment to the pull request of the repository with number.
+func (g *GitService) CommentPullRequest(ctx context.Context, number int, body string) error {
+	provider, err := g.pullRequestProvider()
+	if err != nil {
+		return err
+	}
+	return provider.CommentPullRequest(ctx, g.repository, number, body)
+}
@@ -0,0 +1,200 @@
+package gitservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePullRequests is a fake of the pull request APIs of a provider, serving
+// the JSON of routes and keeping the comments posted.
+type fakePullRequests struct {
+	mu sync.Mutex
+	// routes are the bodies served to GET requests, by path.
+	routes map[string]any
+	// queries are the queries of the GET requests, by path.
+	queries map[string]url.Values
+	// comments are the bodies posted, by path.
+	comments map[string]map[string]any
+}
+
+func (f *fakePullRequests) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		body, ok := f.routes[r.URL.Path]
+		if !ok {
+			break
+		}
+		f.queries[r.URL.Path] = r.URL.Query()
+		writeJSON(w, body)
+		return
+	case http.MethodPost:
+		comment := map[string]any{}
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		f.comments[r.URL.Path] = comment
+		comment["id"] = 1
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(comment)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	writeJSON(w, map[string]string{"message": "404 Not Found"})
+}
+
+func TestPullRequestProviders(t *testing.T) {
+	tests := []struct {
+		name string
+		// newProvider returns the provider under test talking to apiURL.
+		newProvider func(apiURL string) (Provider, error)
+		// routes are the API responses listing one open pull request, and one
+		// opened from a deleted fork.
+		routes map[string]any
+		// listPath and baseQuery are the path listing the pull requests and
+		// the query parameter selecting the base branch.
+		listPath  string
+		baseQuery [2]string
+		// commentPath is the path commenting the pull request, and comment
+		// extracts the text of the posted comment.
+		commentPath string
+		comment     func(body map[string]any) any
+		want        PullRequest
+	}{
+		{
+			name: "Github",
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGithubProvider(ProviderOptions{APIURL: apiURL + "/api/v3/", Credentials: testCredentials,
+					Logger: testLogger})
+			},
+			routes: map[string]any{"/api/v3/repos/hello/world/pulls": []map[string]any{
+				{"number": 1, "title": "Add a feature", "html_url": "https://git.example.com/hello/world/pull/1",
+					"head": map[string]any{"ref": "feature", "sha": statusSHA,
+						"repo": map[string]any{"clone_url": "https://git.example.com/fork/world.git"}}},
+				{"number": 2, "head": map[string]any{"ref": "gone", "sha": statusSHA, "repo": nil}},
+			}},
+			listPath:    "/api/v3/repos/hello/world/pulls",
+			baseQuery:   [2]string{"base", conformanceBranch},
+			commentPath: "/api/v3/repos/hello/world/issues/1/comments",
+			comment:     func(body map[string]any) any { return body["body"] },
+			want: PullRequest{Number: 1, Title: "Add a feature", URL: "https://git.example.com/hello/world/pull/1",
+				HeadRepoURL: "https://git.example.com/fork/world.git", HeadRef: "feature", HeadSHA: statusSHA},
+		},
+		{
+			name: "Gitlab",
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGitlabProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials, Logger: testLogger})
+			},
+			routes: map[string]any{
+				"/api/v4/projects/hello/world/merge_requests": []map[string]any{
+					{"iid": 1, "title": "Add a feature", "web_url": "https://git.example.com/hello/world/-/merge_requests/1",
+						"source_branch": "feature", "sha": statusSHA, "source_project_id": 7},
+					{"iid": 2, "source_branch": "gone", "sha": statusSHA, "source_project_id": 8},
+				},
+				"/api/v4/projects/7": map[string]any{"id": 7, "http_url_to_repo": "https://git.example.com/fork/world.git"},
+			},
+			listPath:    "/api/v4/projects/hello/world/merge_requests",
+			baseQuery:   [2]string{"target_branch", conformanceBranch},
+			commentPath: "/api/v4/projects/hello/world/merge_requests/1/notes",
+			comment:     func(body map[string]any) any { return body["body"] },
+			want: PullRequest{Number: 1, Title: "Add a feature",
+				URL:         "https://git.example.com/hello/world/-/merge_requests/1",
+				HeadRepoURL: "https://git.example.com/fork/world.git", HeadRef: "feature", HeadSHA: statusSHA},
+		},
+		{
+			name: "Bitbucket",
+			newProvider: func(apiURL string) (Provider, error) {
+				return newBitbucketProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials, Logger: testLogger})
+			},
+			routes: map[string]any{"/repositories/hello/world/pullrequests": map[string]any{"values": []map[string]any{
+				{"id": 1, "title": "Add a feature",
+					"links": map[string]any{"html": map[string]any{
+						"href": "https://git.example.com/hello/world/pull-requests/1"}},
+					"source": map[string]any{"branch": map[string]any{"name": "feature"},
+						"commit":     map[string]any{"hash": statusSHA[:12]},
+						"repository": map[string]any{"full_name": "fork/world"}}},
+				{"id": 2, "source": map[string]any{"branch": map[string]any{"name": "gone"}, "repository": nil}},
+			}}},
+			listPath:    "/repositories/hello/world/pullrequests",
+			baseQuery:   [2]string{"q", `destination.branch.name="` + conformanceBranch + `"`},
+			commentPath: "/repositories/hello/world/pullrequests/1/comments",
+			comment: func(body map[string]any) any {
+				content, _ := body["content"].(map[string]any)
+				return content["raw"]
+			},
+			want: PullRequest{Number: 1, Title: "Add a feature", URL: "https://git.example.com/hello/world/pull-requests/1",
+				HeadRepoURL: "https://git.example.com/fork/world", HeadRef: "feature", HeadSHA: statusSHA[:12]},
+		},
+		{
+			name: "Bitbucket Data Center",
+			newProvider: func(apiURL string) (Provider, error) {
+				return newBitbucketServerProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials,
+					Logger: testLogger})
+			},
+			routes: map[string]any{"/projects/hello/repos/world/pull-requests": map[string]any{
+				"isLastPage": true,
+				"values": []map[string]any{
+					{"id": 1, "title": "Add a feature",
+						"links": map[string]any{"self": []map[string]any{
+							{"href": "https://git.example.com/projects/hello/repos/world/pull-requests/1"}}},
+						"fromRef": map[string]any{"displayId": "feature", "latestCommit": statusSHA,
+							"repository": map[string]any{"links": map[string]any{"clone": []map[string]any{
+								{"name": "ssh", "href": "ssh://git@git.example.com:7999/fork/world.git"},
+								{"name": "http", "href": "https://git.example.com/scm/fork/world.git"},
+							}}}}},
+					{"id": 2, "fromRef": map[string]any{"displayId": "gone", "repository": map[string]any{}}},
+				},
+			}},
+			listPath:    "/projects/hello/repos/world/pull-requests",
+			baseQuery:   [2]string{"at", "refs/heads/" + conformanceBranch},
+			commentPath: "/projects/hello/repos/world/pull-requests/1/comments",
+			comment:     func(body map[string]any) any { return body["text"] },
+			want: PullRequest{Number: 1, Title: "Add a feature",
+				URL:         "https://git.example.com/projects/hello/repos/world/pull-requests/1",
+				HeadRepoURL: "https://git.example.com/scm/fork/world.git", HeadRef: "feature", HeadSHA: statusSHA},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := &fakePullRequests{routes: tt.routes, queries: map[string]url.Values{},
+				comments: map[string]map[string]any{}}
+			server := httptest.NewServer(fake)
+			t.Cleanup(server.Close)
+			p, err := tt.newProvider(server.URL)
+			require.NoError(t, err)
+			provider, ok := p.(PullRequestProvider)
+			require.True(t, ok)
+			repo := &Repository{Host: "git.example.com", Owner: conformanceOwner, Name: conformanceName}
+
+			pullRequests, err := provider.ListPullRequests(ctx, repo, conformanceBranch)
+			require.NoError(t, err)
+			assert.Equal(t, []PullRequest{tt.want}, pullRequests)
+			assert.Equal(t, tt.baseQuery[1], fake.queries[tt.listPath].Get(tt.baseQuery[0]))
+
+			require.NoError(t, provider.CommentPullRequest(ctx, repo, 1, "Preview at https://hello.apps.example.com"))
+			require.Contains(t, fake.comments, tt.commentPath)
+			assert.Equal(t, "Preview at https://hello.apps.example.com", tt.comment(fake.comments[tt.commentPath]))
+
+			_, err = provider.ListPullRequests(ctx, &Repository{Owner: "missing", Name: "repo"}, conformanceBranch)
+			assert.Equal(t, ReasonRepoNotFound, ReasonForError(err))
+		})
+	}
+}
+
+func TestListPullRequestsUnsupported(t *testing.T) {
+	server := newFakeSmartHTTPServer(t)
+	gs := New(server.URL+"/hello/world.git", conformanceBranch, testCredentials, testLogger)
+	_, err := gs.ListPullRequests(context.Background(), conformanceBranch)
+	assert.Equal(t, ReasonUnsupportedGitType, ReasonForError(err))
+	err = gs.CommentPullRequest(context.Background(), 1, "Preview")
+	assert.Equal(t, ReasonUnsupportedGitType, ReasonForError(err))
+}
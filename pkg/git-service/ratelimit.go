@@ -0,0 +1,191 @@
+package gitservice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimitReserve is the default percentage of the API quota kept for
+// urgent probes, see SetRateLimitReserve.
+const DefaultRateLimitReserve = 10
+
+// defaultRetryAfter is the delay before retrying a rate limited request whose
+// response tells no reset time.
+const defaultRetryAfter = time.Minute
+
+// RateLimit is the API quota of a host, as reported by its last response.
+type RateLimit struct {
+	// Limit is the number of requests allowed in the current window.
+	Limit int
+
+	// Remaining is the number of requests left in the current window.
+	Remaining int
+
+	// Reset is when the quota is restored.
+	Reset time.Time
+}
+
+// rateLimitHeaders are the headers reporting the quota: X-RateLimit-* on
+// Github and Gitea, RateLimit-* on Gitlab.
+var rateLimitHeaders = []struct{ limit, remaining, reset string }{
+	{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+	{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+}
+
+// parseRateLimit reads the quota reported by the headers of a response, if any.
+func parseRateLimit(header http.Header, now time.Time) (RateLimit, bool) {
+	for _, names := range rateLimitHeaders {
+		remaining, err := strconv.Atoi(header.Get(names.remaining))
+		if err != nil {
+			continue
+		}
+		limit, _ := strconv.Atoi(header.Get(names.limit))
+		reset, _ := parseResetTime(header.Get(names.reset), now)
+		return RateLimit{Limit: limit, Remaining: remaining, Reset: reset}, true
+	}
+	return RateLimit{}, false
+}
+
+// parseResetTime parses a reset header, which is either a Unix timestamp, as
+// sent by Github and Gitlab, or a number of seconds from now.
+func parseResetTime(value string, now time.Time) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false
+	}
+	// No delay is anywhere close to 2001-09-09, the Unix time 1e9.
+	if seconds >= 1e9 {
+		return time.Unix(seconds, 0), true
+	}
+	return now.Add(time.Duration(seconds) * time.Second), true
+}
+
+// retryTime returns when a rate limited request can be retried: at the reset
+// time of the quota, or after Retry-After for secondary rate limits.
+func retryTime(header http.Header, now time.Time) time.Time {
+	if retryAt, ok := parseResetTime(header.Get("Retry-After"), now); ok {
+		return retryAt
+	}
+	if limit, ok := parseRateLimit(header, now); ok && !limit.Reset.IsZero() {
+		return limit.Reset
+	}
+	return now.Add(defaultRetryAfter)
+}
+
+// isRateLimited reports whether a response rejected for lack of quota. A 403
+// is only a rate limit when the quota is exhausted or a retry delay is given.
+func isRateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		limit, ok := parseRateLimit(resp.Header, time.Now())
+		return (ok && limit.Remaining == 0) || resp.Header.Get("Retry-After") != ""
+	default:
+		return false
+	}
+}
+
+// rateLimitError returns the ReasonRateLimitExceeded error of a rate limited
+// response, telling when the request can be retried.
+func rateLimitError(header http.Header, err error) error {
+	return &Error{Reason: ReasonRateLimitExceeded, Err: err, RetryAt: retryTime(header, time.Now())}
+}
+
+// rateLimitBudget tracks the quotas of the Git hosts across all the
+// ConsoleApplications, so that non-urgent probes leave the end of a quota to
+// urgent ones.
+type rateLimitBudget struct {
+	mu      sync.Mutex
+	reserve int
+	limits  map[string]RateLimit
+}
+
+var budget = &rateLimitBudget{reserve: DefaultRateLimitReserve, limits: map[string]RateLimit{}}
+
+// SetRateLimitReserve sets the percentage of the API quota of each host and
+// credentials kept for urgent probes. 0 disables holding probes back.
+func SetRateLimitReserve(percent int) {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	budget.reserve = percent
+}
+
+// observe records the quota reported by a response for key.
+func (b *rateLimitBudget) observe(key string, header http.Header) {
+	limit, ok := parseRateLimit(header, time.Now())
+	if !ok {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limits[key] = limit
+}
+
+// holdBack returns how long a non-urgent request for key should wait for the
+// quota to reset, or zero if the remaining quota is above the reserve.
+func (b *rateLimitBudget) holdBack(key string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	limit, ok := b.limits[key]
+	if !ok || !limit.Reset.After(now) {
+		return 0
+	}
+	if limit.Remaining > limit.Limit*b.reserve/100 {
+		return 0
+	}
+	return limit.Reset.Sub(now)
+}
+
+// rateLimitTransport records the quota reported by every response in the budget.
+type rateLimitTransport struct {
+	key  string
+	base http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if resp != nil {
+		budget.observe(t.key, resp.Header)
+	}
+	return resp, err
+}
+
+// newHTTPClient returns the HTTP client providers send their requests to
+// opts.Host with, revalidating the responses of previous requests with the
+// same credentials. Each attempt of a request is bounded by opts.Timeout, and
+// transient failures are retried up to opts.Retries times.
+func newHTTPClient(opts ProviderOptions) *http.Client {
+	key := budgetKey(opts.Host, opts.Credentials)
+	return &http.Client{Transport: &rateLimitTransport{key: key, base: sharedTransport(key, opts)}}
+}
+
+// budgetKey identifies the quota of a host: API quotas are granted per user,
+// or per client address for anonymous requests.
+func budgetKey(host string, credentials Credentials) string {
+	return host + "/" + credentials.fingerprint()
+}
+
+// fingerprint returns a digest identifying the credentials without revealing
+// them, or the empty string for anonymous access.
+func (c Credentials) fingerprint() string {
+	if c.Token == "" && c.Username == "" && c.Password == "" && len(c.SSHPrivateKey) == 0 && c.GithubApp == nil {
+		return ""
+	}
+	hash := sha256.New()
+	for _, value := range [][]byte{[]byte(c.Token), []byte(c.Username), []byte(c.Password), c.SSHPrivateKey} {
+		hash.Write(value)
+		hash.Write([]byte{0})
+	}
+	if c.GithubApp != nil {
+		// Installation tokens share the quota of the installation. The private
+		// key tells apart the secrets forging the public IDs of an app.
+		fmt.Fprintf(hash, "github-app/%d/%d/%s", c.GithubApp.AppID, c.GithubApp.InstallationID, c.GithubApp.keyDigest())
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
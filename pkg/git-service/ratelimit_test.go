@@ -0,0 +1,152 @@
+package gitservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   RateLimit
+		ok     bool
+	}{
+		{
+			name: "Github",
+			header: http.Header{
+				"X-Ratelimit-Limit":     {"5000"},
+				"X-Ratelimit-Remaining": {"42"},
+				"X-Ratelimit-Reset":     {"1700000600"},
+			},
+			want: RateLimit{Limit: 5000, Remaining: 42, Reset: time.Unix(1700000600, 0)},
+			ok:   true,
+		},
+		{
+			name: "Gitlab",
+			header: http.Header{
+				"Ratelimit-Limit":     {"2000"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"1700000060"},
+			},
+			want: RateLimit{Limit: 2000, Remaining: 0, Reset: time.Unix(1700000060, 0)},
+			ok:   true,
+		},
+		{
+			name: "Reset in seconds",
+			header: http.Header{
+				"Ratelimit-Remaining": {"3"},
+				"Ratelimit-Reset":     {"30"},
+			},
+			want: RateLimit{Remaining: 3, Reset: now.Add(30 * time.Second)},
+			ok:   true,
+		},
+		{
+			name:   "No rate limit",
+			header: http.Header{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRateLimit(tt.header, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsRateLimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   bool
+	}{
+		{"Too many requests", http.StatusTooManyRequests, http.Header{}, true},
+		{"Quota exhausted", http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"0"}}, true},
+		{"Secondary rate limit", http.StatusForbidden, http.Header{"Retry-After": {"60"}}, true},
+		{"Forbidden", http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"4999"}}, false},
+		{"Not found", http.StatusNotFound, http.Header{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRateLimited(&http.Response{StatusCode: tt.status, Header: tt.header}))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	err := rateLimitError(http.Header{"Retry-After": {"90"}}, assert.AnError)
+	assert.Equal(t, ReasonRateLimitExceeded, ReasonForError(err))
+	assert.InDelta(t, 90*time.Second, RetryAfter(err, now), float64(time.Second))
+
+	err = rateLimitError(http.Header{}, assert.AnError)
+	assert.InDelta(t, defaultRetryAfter, RetryAfter(err, now), float64(time.Second))
+
+	assert.Zero(t, RetryAfter(newError(ReasonRepoNotFound, assert.AnError), now))
+	assert.Zero(t, RetryAfter(nil, now))
+}
+
+func TestRateLimitBudget(t *testing.T) {
+	now := time.Now()
+	reset := now.Add(10 * time.Minute)
+	b := &rateLimitBudget{reserve: 10, limits: map[string]RateLimit{}}
+	observe := func(remaining int, reset time.Time) {
+		b.observe("github.com/", http.Header{
+			"X-Ratelimit-Limit":     {"5000"},
+			"X-Ratelimit-Remaining": {strconv.Itoa(remaining)},
+			"X-Ratelimit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+		})
+	}
+
+	assert.Zero(t, b.holdBack("github.com/", now), "unknown quota")
+
+	observe(501, reset)
+	assert.Zero(t, b.holdBack("github.com/", now), "above the reserve")
+
+	observe(500, reset)
+	assert.InDelta(t, 10*time.Minute, b.holdBack("github.com/", now), float64(time.Second), "within the reserve")
+	assert.Zero(t, b.holdBack("gitlab.com/", now), "other host")
+
+	observe(0, now.Add(-time.Second))
+	assert.Zero(t, b.holdBack("github.com/", now), "quota already reset")
+}
+
+func TestGithubProviderRateLimited(t *testing.T) {
+	reset := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(w, map[string]string{"message": "API rate limit exceeded"})
+	}))
+	t.Cleanup(server.Close)
+
+	key := budgetKey("github.ratelimit.example.com", Credentials{})
+	provider, err := newGithubProvider(ProviderOptions{
+		APIURL: server.URL + "/api/v3/",
+		Logger: testLogger,
+		HTTPClient: newHTTPClient(ProviderOptions{
+			Host:    "github.ratelimit.example.com",
+			Timeout: DefaultRequestTimeout,
+		}),
+	})
+	require.NoError(t, err)
+
+	repo := &Repository{Host: "github.ratelimit.example.com", Owner: conformanceOwner, Name: conformanceName}
+	_, err = provider.ResolveRef(context.Background(), repo, conformanceBranch)
+	require.Error(t, err)
+	assert.Equal(t, ReasonRateLimitExceeded, ReasonForError(err))
+	assert.InDelta(t, time.Until(reset), RetryAfter(err, time.Now()), float64(time.Second))
+	assert.InDelta(t, time.Until(reset), budget.holdBack(key, time.Now()), float64(time.Second))
+}
@@ -0,0 +1,68 @@
+package gitservice
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// RefType is the kind of reference a requested reference resolved to.
+type RefType string
+
+const (
+	// RefTypeBranch indicates the reference is a branch
+	RefTypeBranch RefType = "Branch"
+
+	// RefTypeTag indicates the reference is a tag
+	RefTypeTag RefType = "Tag"
+
+	// RefTypeCommit indicates the reference is a commit SHA
+	RefTypeCommit RefType = "Commit"
+)
+
+func (t RefType) String() string {
+	return string(t)
+}
+
+// commitSHAPattern matches full and abbreviated SHA-1 and SHA-256 commit hashes.
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// isCommitSHA reports whether reference looks like a, possibly abbreviated, commit SHA.
+func isCommitSHA(reference string) bool {
+	return commitSHAPattern.MatchString(reference)
+}
+
+// isFullCommitSHA reports whether reference is a full SHA-1 or SHA-256 commit hash.
+func isFullCommitSHA(reference string) bool {
+	return isCommitSHA(reference) && (len(reference) == 40 || len(reference) == 64)
+}
+
+// refLookup returns the commit a reference of a given type points at. A
+// reference that does not exist is reported with a ReasonRepoNotFound error.
+type refLookup func(ctx context.Context, reference string) (string, error)
+
+// resolveRef resolves reference as a branch, then as a tag, then, if it looks
+// like a commit SHA, as a commit. Any error other than ReasonRepoNotFound, such
+// as an authentication failure, stops the resolution.
+func resolveRef(ctx context.Context, reference string, branch, tag, commit refLookup) (*Ref, error) {
+	refTypes := []RefType{RefTypeBranch, RefTypeTag}
+	lookups := []refLookup{branch, tag}
+	if isCommitSHA(reference) {
+		refTypes = append(refTypes, RefTypeCommit)
+		lookups = append(lookups, commit)
+	}
+
+	var lastErr error
+	for i, lookup := range lookups {
+		sha, err := lookup(ctx, reference)
+		if err == nil {
+			return &Ref{Name: reference, Type: refTypes[i], SHA: sha}, nil
+		}
+		if ReasonForError(err) != ReasonRepoNotFound {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, newError(ReasonRepoNotFound,
+		fmt.Errorf("reference %q is not a branch, tag or commit: %w", reference, lastErr))
+}
@@ -0,0 +1,146 @@
+package gitservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+func init() {
+	// The smart HTTP provider serves any host without a dedicated provider,
+	// so it is not associated with any host.
+	RegisterProvider(SmartHTTP, newSmartHTTPProvider)
+}
+
+// smartHTTPProvider checks repositories on arbitrary Git servers, such as cgit
+// or Gerrit, through the reference advertisement of the Git HTTP protocol. It
+// cannot read files, as that would require fetching objects.
+type smartHTTPProvider struct {
+	client *apiClient
+	logger logr.Logger
+	// authenticated is set when the requests carry the credentials.
+	authenticated bool
+	// allowInsecureCredentials lets the credentials be sent over plain HTTP.
+	allowInsecureCredentials bool
+}
+
+func newSmartHTTPProvider(opts ProviderOptions) (Provider, error) {
+	// Requests are sent to the clone URL of the repository, not to an API.
+	client := newAPIClient("", opts.HTTPClient)
+	client.header.Set("Accept", "*/*")
+	client.untrusted = true
+	// Git servers expect basic authentication; tokens are sent as the password.
+	if credentials := opts.Credentials; credentials.accessToken() != "" {
+		username := credentials.Username
+		if username == "" {
+			username = "git"
+		}
+		client.header.Set("Authorization", basicAuth(username, credentials.accessToken()))
+	}
+	return &smartHTTPProvider{
+		client:                   client,
+		logger:                   opts.Logger,
+		authenticated:            client.header.Get("Authorization") != "",
+		allowInsecureCredentials: opts.AllowInsecureCredentials,
+	}, nil
+}
+
+// ParseURL accepts any repository path. The last segment is the repository
+// name and the preceding ones, if any, its owner.
+func (p *smartHTTPProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	if len(segments) == 0 || segments[len(segments)-1] == "" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	last := len(segments) - 1
+	cloneURL := *u
+	cloneURL.User = nil
+	cloneURL.RawQuery, cloneURL.Fragment = "", ""
+	cloneURL.Path = strings.TrimSuffix(cloneURL.Path, "/")
+	cloneURL.RawPath = ""
+	return &Repository{
+		Host:     normalizeHost(u.Host),
+		Owner:    strings.Join(segments[:last], "/"),
+		Name:     segments[last],
+		CloneURL: cloneURL.String(),
+	}, nil
+}
+
+func (p *smartHTTPProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
+	}
+	p.logger.Info("Successfully reached Git server!")
+	return nil
+}
+
+func (p *smartHTTPProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	adv, err := p.advertisedRefs(ctx, repo)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Git server")
+		return nil, err
+	}
+	ref, err := adv.resolve(reference)
+	if err != nil {
+		return nil, newError(ReasonRepoNotFound, fmt.Errorf("%w in %s", err, repo.CloneURL))
+	}
+	return ref, nil
+}
+
+func (p *smartHTTPProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	adv, err := p.advertisedRefs(ctx, repo)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Git server")
+		return "", err
+	}
+	branch, ok := adv.defaultBranch()
+	if !ok {
+		return "", newError(ReasonRepoNotFound, fmt.Errorf("no default branch advertised by %s", repo.CloneURL))
+	}
+	return branch, nil
+}
+
+func (p *smartHTTPProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	err := fmt.Errorf("reading %s over smart HTTP: %w", path, errors.ErrUnsupported)
+	return nil, newError(ReasonUnsupportedGitType, err)
+}
+
+func (p *smartHTTPProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	err := fmt.Errorf("listing %s over smart HTTP: %w", path, errors.ErrUnsupported)
+	return nil, newError(ReasonUnsupportedGitType, err)
+}
+
+// advertisedRefs fetches the references of the repository from info/refs.
+// Servers speaking the dumb HTTP protocol answer with a plain list of references.
+func (p *smartHTTPProvider) advertisedRefs(ctx context.Context, repo *Repository) (*refAdvertisement, error) {
+	// Basic authentication sends the credentials in clear text.
+	if p.authenticated && !p.allowInsecureCredentials && strings.HasPrefix(repo.CloneURL, "http://") {
+		return nil, newError(ReasonInsecureCredentials,
+			fmt.Errorf("refusing to send the credentials to %s over plain HTTP", repo.CloneURL))
+	}
+	var body []byte
+	query := url.Values{"service": {"git-upload-pack"}}
+	resp, err := p.client.get(ctx, repo.CloneURL+"/info/refs", query, &body)
+	if err != nil {
+		return nil, apiError(resp, err, ReasonRepoNotFound)
+	}
+	if resp.Header.Get("Content-Type") != "application/x-git-upload-pack-advertisement" {
+		adv, err := parseDumbRefs(bytes.NewReader(body))
+		if err != nil {
+			return nil, newError(ReasonRepoNotReachable, fmt.Errorf("%s is not a Git repository: %w", repo.CloneURL, err))
+		}
+		return adv, nil
+	}
+	adv, err := parseRefAdvertisement(bytes.NewReader(body))
+	if err != nil {
+		return nil, newError(ReasonRepoNotReachable, err)
+	}
+	return adv, nil
+}
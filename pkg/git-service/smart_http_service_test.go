@@ -0,0 +1,264 @@
+package gitservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pktLine encodes payload as a pkt-line.
+func pktLine(payload string) string {
+	return fmt.Sprintf("%04x%s", len(payload)+4, payload)
+}
+
+// newFakeSmartHTTPServer serves the reference advertisement of the
+// conformance repository like a Git smart HTTP server would, over plain HTTP
+// with the credentials allowed.
+func newFakeSmartHTTPServer(t *testing.T) *httptest.Server {
+	refsPath := "/" + conformanceOwner + "/" + conformanceName + ".git/info/refs"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != refsPath || r.URL.Query().Get("service") != "git-upload-pack" {
+			http.NotFound(w, r)
+			return
+		}
+		if _, password, _ := r.BasicAuth(); password != conformanceToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		_, _ = w.Write([]byte(pktLine("# service=git-upload-pack\n") + "0000" +
+			pktLine(conformanceSHA+" HEAD\x00multi_ack symref=HEAD:refs/heads/"+conformanceBranch+"\n") +
+			pktLine(conformanceSHA+" refs/heads/"+conformanceBranch+"\n") +
+			pktLine(conformanceTagSHA+" refs/tags/"+conformanceTag+"\n") +
+			pktLine(conformanceSHA+" refs/tags/"+conformanceTag+"^{}\n") +
+			"0000"))
+	}))
+	t.Cleanup(server.Close)
+	allowInsecureCredentials(t, server)
+	return server
+}
+
+// allowInsecureCredentials lets the credentials be sent to the host of server
+// for the duration of the test, without registering a provider type for it.
+func allowInsecureCredentials(t *testing.T, server *httptest.Server) {
+	host := normalizeHost(strings.TrimPrefix(server.URL, "http://"))
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	hostConfigs[host] = HostConfig{Host: host, AllowInsecureCredentials: true}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		delete(hostConfigs, host)
+	})
+}
+
+func TestSmartHTTPProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeSmartHTTPServer(t)
+		provider, err := newSmartHTTPProvider(ProviderOptions{Credentials: testCredentials, Logger: testLogger,
+			AllowInsecureCredentials: true})
+		require.NoError(t, err)
+		return providerFixture{
+			provider: provider,
+			gitURL:   server.URL + "/" + conformanceOwner + "/" + conformanceName + ".git",
+		}
+	})
+}
+
+func TestParseRefAdvertisement(t *testing.T) {
+	const tagSHA = "1111111111111111111111111111111111111111"
+	adv, err := parseRefAdvertisement(strings.NewReader(
+		pktLine("# service=git-upload-pack\n") + "0000" +
+			pktLine(conformanceSHA+" HEAD\x00side-band symref=HEAD:refs/heads/main\n") +
+			pktLine(conformanceSHA+" refs/heads/main\n") +
+			pktLine(tagSHA+" refs/tags/v1.0.0\n") +
+			pktLine(conformanceSHA+" refs/tags/v1.0.0^{}\n") +
+			"0000"))
+	require.NoError(t, err)
+
+	assert.Equal(t, conformanceSHA, adv.refs["refs/heads/main"])
+	assert.Equal(t, conformanceSHA, adv.refs["refs/tags/v1.0.0"], "annotated tags are peeled")
+	head, ok := adv.symref("HEAD")
+	assert.True(t, ok)
+	assert.Equal(t, "refs/heads/main", head)
+
+	tests := []struct {
+		reference  string
+		refType    RefType
+		sha        string
+		unverified bool
+	}{
+		{"main", RefTypeBranch, conformanceSHA, false},
+		{"refs/heads/main", RefTypeBranch, conformanceSHA, false},
+		{"v1.0.0", RefTypeTag, conformanceSHA, false},
+		{conformanceSHA[:7], RefTypeCommit, conformanceSHA, false},
+		{conformanceSHA, RefTypeCommit, conformanceSHA, false},
+		{tagSHA, RefTypeCommit, tagSHA, true},
+	}
+	for _, tt := range tests {
+		ref, err := adv.resolve(tt.reference)
+		require.NoError(t, err, tt.reference)
+		assert.Equal(t, tt.refType, ref.Type, tt.reference)
+		assert.Equal(t, tt.sha, ref.SHA, tt.reference)
+		assert.Equal(t, tt.unverified, ref.Unverified, tt.reference)
+	}
+	_, err = adv.resolve("missing")
+	assert.Error(t, err)
+	_, err = adv.resolve("abcdef0")
+	assert.Error(t, err, "abbreviated SHAs must match an advertised commit")
+
+	ambiguous, err := parseRefAdvertisement(strings.NewReader(
+		pktLine("abcdef0111111111111111111111111111111111 refs/heads/main\n") +
+			pktLine("abcdef0222222222222222222222222222222222 refs/heads/feature\n") +
+			"0000"))
+	require.NoError(t, err)
+	_, err = ambiguous.resolve("abcdef0")
+	assert.ErrorContains(t, err, "ambiguous")
+	ref, err := ambiguous.resolve("abcdef01")
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef0111111111111111111111111111111111", ref.SHA)
+}
+
+func TestDefaultBranchWithoutSymref(t *testing.T) {
+	adv, err := parseRefAdvertisement(strings.NewReader(
+		pktLine(conformanceSHA+" HEAD\x00side-band\n") +
+			pktLine("1111111111111111111111111111111111111111 refs/heads/develop\n") +
+			pktLine(conformanceSHA+" refs/heads/trunk\n") +
+			"0000"))
+	require.NoError(t, err)
+	branch, ok := adv.defaultBranch()
+	assert.True(t, ok)
+	assert.Equal(t, "trunk", branch, "HEAD is matched to the branch on the same commit")
+
+	_, ok = (&refAdvertisement{refs: map[string]string{}}).defaultBranch()
+	assert.False(t, ok)
+}
+
+func TestParseRefAdvertisementEmptyRepository(t *testing.T) {
+	adv, err := parseRefAdvertisement(strings.NewReader(
+		pktLine("# service=git-upload-pack\n") + "0000" +
+			pktLine("0000000000000000000000000000000000000000 capabilities^{}\x00side-band\n") +
+			"0000"))
+	require.NoError(t, err)
+	assert.Empty(t, adv.refs)
+	assert.Equal(t, []string{"side-band"}, adv.capabilities)
+}
+
+func TestParseRefAdvertisementError(t *testing.T) {
+	_, err := parseRefAdvertisement(strings.NewReader(pktLine("ERR access denied\n")))
+	assert.EqualError(t, err, "access denied")
+}
+
+func TestUnknownHostFallsBackToSmartHTTP(t *testing.T) {
+	server := newFakeSmartHTTPServer(t)
+	gitURL := server.URL + "/hello/world.git"
+
+	gs := New(gitURL, conformanceBranch, testCredentials, testLogger)
+	assert.Equal(t, SmartHTTP, gs.gitType)
+	status, reason := gs.IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionTrue, status)
+	assert.Equal(t, ReasonSucceeded, reason)
+	assert.Empty(t, gs.Message())
+
+	const unadvertisedSHA = "0123456789abcdef0123456789abcdef01234567"
+	gs = New(gitURL, unadvertisedSHA, testCredentials, testLogger)
+	status, reason = gs.IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionTrue, status)
+	assert.Equal(t, ReasonCommitNotVerified, reason)
+	assert.Contains(t, gs.Message(), "cannot tell whether it exists")
+
+	status, reason = New(gitURL, conformanceBranch, Credentials{}, testLogger).IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionFalse, status)
+	assert.Equal(t, ReasonAccessTokenRequired, reason)
+}
+
+func TestSmartHTTPProviderInsecureCredentials(t *testing.T) {
+	server := newFakeSmartHTTPServer(t)
+	gitURL := server.URL + "/" + conformanceOwner + "/" + conformanceName + ".git"
+	provider, err := newSmartHTTPProvider(ProviderOptions{Credentials: testCredentials, Logger: testLogger})
+	require.NoError(t, err)
+	repo, err := parseRepository(provider, gitURL)
+	require.NoError(t, err)
+	_, err = provider.ResolveRef(context.Background(), repo, conformanceBranch)
+	assert.Equal(t, ReasonInsecureCredentials, ReasonForError(err), "credentials over plain HTTP")
+
+	provider, err = newSmartHTTPProvider(ProviderOptions{Logger: testLogger})
+	require.NoError(t, err)
+	_, err = provider.ResolveRef(context.Background(), repo, conformanceBranch)
+	assert.Equal(t, ReasonAccessTokenRequired, ReasonForError(err), "anonymous requests are sent")
+}
+
+// TestSmartHTTPProviderGitHTTPBackend checks the provider against a real
+// repository served by git http-backend.
+func TestSmartHTTPProviderGitHTTPBackend(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	runGit(t, "", "init", "--quiet", "--initial-branch=main", work)
+	require.NoError(t, os.WriteFile(filepath.Join(work, "README.md"), []byte(conformanceContent), 0o600))
+	runGit(t, work, "add", "README.md")
+	runGit(t, work, "commit", "--quiet", "-m", "Initial commit")
+	runGit(t, work, "tag", "-a", "-m", "First release", "v1.0.0")
+	runGit(t, "", "clone", "--quiet", "--bare", work, filepath.Join(root, "hello", "world.git"))
+	sha := strings.TrimSpace(runGit(t, work, "rev-parse", "HEAD"))
+
+	server := httptest.NewServer(&cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	defer server.Close()
+
+	provider, err := newSmartHTTPProvider(ProviderOptions{Logger: testLogger})
+	require.NoError(t, err)
+	repo, err := parseRepository(provider, server.URL+"/hello/world.git")
+	require.NoError(t, err)
+
+	ref, err := provider.ResolveRef(context.Background(), repo, "main")
+	require.NoError(t, err)
+	assert.Equal(t, sha, ref.SHA)
+	ref, err = provider.ResolveRef(context.Background(), repo, "refs/tags/v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, sha, ref.SHA)
+	_, err = provider.ResolveRef(context.Background(), repo, "missing")
+	assert.Equal(t, ReasonRepoNotFound, ReasonForError(err))
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
+
+func TestSmartHTTPProviderHidesResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("root:x:0:0:root:/root:/bin/bash\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	gs := New(server.URL+"/hello/world.git", conformanceBranch, Credentials{}, testLogger)
+	status, _ := gs.IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionFalse, status)
+	assert.NotContains(t, gs.Message(), "root:x")
+}
@@ -0,0 +1,330 @@
+package gitservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultSSHUser = "git"
+
+func init() {
+	// Git SSH URLs are routed to this provider by their scheme, not their host.
+	RegisterProvider(SSH, newSSHProvider)
+}
+
+// sshProvider checks repositories through the reference advertisement of
+// git-upload-pack over SSH, typically authenticating with a deploy key. It
+// cannot read files, as that would require fetching objects.
+type sshProvider struct {
+	signer     ssh.Signer
+	keyErr     error
+	knownHosts []byte
+	timeout    time.Duration
+	logger     logr.Logger
+}
+
+func newSSHProvider(opts ProviderOptions) (Provider, error) {
+	p := &sshProvider{knownHosts: opts.Credentials.KnownHosts, timeout: opts.Timeout, logger: opts.Logger}
+	if len(opts.Credentials.SSHPrivateKey) > 0 {
+		// An invalid key is reported when the repository is probed, with a precise reason.
+		p.signer, p.keyErr = ssh.ParsePrivateKey(opts.Credentials.SSHPrivateKey)
+	}
+	return p, nil
+}
+
+// ParseURL accepts ssh URLs and scp-like URLs with any repository path. The
+// last segment is the repository name and the preceding ones, if any, its owner.
+func (p *sshProvider) ParseURL(u *url.URL) (*Repository, error) {
+	segments := splitRepoPath(u)
+	if u.Host == "" || len(segments) == 0 || segments[len(segments)-1] == "" {
+		return nil, errors.New(ReasonInvalidGitURL.String())
+	}
+	last := len(segments) - 1
+	cloneURL := (&url.URL{Scheme: "ssh", User: u.User, Host: u.Host, Path: u.Path}).String()
+	if !strings.HasPrefix(u.Path, "/") {
+		// Keep the scp-like syntax, as its relative path may resolve against
+		// the home directory of the SSH user.
+		cloneURL = u.Host + ":" + u.Path
+		if u.User != nil {
+			cloneURL = u.User.Username() + "@" + cloneURL
+		}
+	}
+	return &Repository{
+		Host:     normalizeHost(u.Host),
+		Owner:    strings.Join(segments[:last], "/"),
+		Name:     segments[last],
+		CloneURL: cloneURL,
+	}, nil
+}
+
+func (p *sshProvider) IsRepoReachable(ctx context.Context, repo *Repository, reference string) error {
+	if _, err := p.ResolveRef(ctx, repo, reference); err != nil {
+		return err
+	}
+	p.logger.Info("Successfully reached Git server over SSH!")
+	return nil
+}
+
+func (p *sshProvider) ResolveRef(ctx context.Context, repo *Repository, reference string) (*Ref, error) {
+	adv, err := p.advertisedRefs(ctx, repo)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Git server over SSH")
+		return nil, err
+	}
+	ref, err := adv.resolve(reference)
+	if err != nil {
+		return nil, newError(ReasonRepoNotFound, fmt.Errorf("%w in %s", err, repo.CloneURL))
+	}
+	return ref, nil
+}
+
+func (p *sshProvider) DefaultBranch(ctx context.Context, repo *Repository) (string, error) {
+	adv, err := p.advertisedRefs(ctx, repo)
+	if err != nil {
+		p.logger.Error(err, "Unsuccessful response from Git server over SSH")
+		return "", err
+	}
+	branch, ok := adv.defaultBranch()
+	if !ok {
+		return "", newError(ReasonRepoNotFound, fmt.Errorf("no default branch advertised by %s", repo.CloneURL))
+	}
+	return branch, nil
+}
+
+func (p *sshProvider) GetFile(ctx context.Context, repo *Repository, reference, path string) ([]byte, error) {
+	err := fmt.Errorf("reading %s over SSH: %w", path, errors.ErrUnsupported)
+	return nil, newError(ReasonUnsupportedGitType, err)
+}
+
+func (p *sshProvider) ListDirectory(
+	ctx context.Context, repo *Repository, reference, path string,
+) ([]Entry, error) {
+	err := fmt.Errorf("listing %s over SSH: %w", path, errors.ErrUnsupported)
+	return nil, newError(ReasonUnsupportedGitType, err)
+}
+
+// advertisedRefs runs git-upload-pack on the server and reads the references
+// it advertises, then hangs up without requesting any object. The whole
+// exchange is bounded by the timeout of the provider.
+func (p *sshProvider) advertisedRefs(ctx context.Context, repo *Repository) (*refAdvertisement, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	adv, err := p.readAdvertisedRefs(ctx, repo)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// Closing the connection on the deadline surfaces as an unrelated read error.
+		return nil, newError(ReasonTimeout, fmt.Errorf("%w: %w", ctx.Err(), errors.Unwrap(err)))
+	}
+	return adv, err
+}
+
+func (p *sshProvider) readAdvertisedRefs(ctx context.Context, repo *Repository) (*refAdvertisement, error) {
+	switch {
+	case p.keyErr != nil:
+		return nil, newError(ReasonSSHKeyRequired, fmt.Errorf("invalid SSH private key: %w", p.keyErr))
+	case p.signer == nil:
+		return nil, newError(ReasonSSHKeyRequired, errors.New("an SSH private key is required for Git SSH URLs"))
+	}
+	hostKeyCallback, err := p.hostKeyCallback()
+	if err != nil {
+		return nil, newError(ReasonHostKeyVerificationFailed, err)
+	}
+	u, err := parseGitURL(repo.CloneURL)
+	if err != nil {
+		return nil, newError(ReasonInvalidGitURL, err)
+	}
+	user := defaultSSHUser
+	if u.User != nil {
+		user = u.User.Username()
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	client, err := dialSSH(ctx, addr, &ssh.ClientConfig{
+		User:              user,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(p.signer)},
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms(hostKeyCallback, addr),
+	})
+	if err != nil {
+		return nil, sshError(err)
+	}
+	defer client.Close()
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	defer stop()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, newError(ReasonRepoNotReachable, err)
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, newError(ReasonRepoNotReachable, err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, newError(ReasonRepoNotReachable, err)
+	}
+	stderr := &bytes.Buffer{}
+	session.Stderr = stderr
+	if err := session.Start("git-upload-pack " + shellQuote(u.Path)); err != nil {
+		return nil, newError(ReasonRepoNotReachable, err)
+	}
+
+	adv, err := parseRefAdvertisement(stdout)
+	// A flush packet tells the server no objects are wanted.
+	_, _ = io.WriteString(stdin, "0000")
+	_ = stdin.Close()
+	_ = session.Wait()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+		if isNotFoundMessage(err.Error()) {
+			return nil, newError(ReasonRepoNotFound, err)
+		}
+		return nil, newError(ReasonRepoNotReachable, err)
+	}
+	return adv, nil
+}
+
+// maxKnownHostsCallbacks bounds the number of host key callbacks kept parsed.
+const maxKnownHostsCallbacks = 100
+
+// knownHostsCallbacks caches the host key callbacks by digest of their
+// known_hosts entries, as knownhosts only parses files.
+var (
+	knownHostsMu        sync.Mutex
+	knownHostsCallbacks = map[string]ssh.HostKeyCallback{}
+)
+
+// hostKeyCallback verifies host keys against the known_hosts entries of the provider.
+func (p *sshProvider) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if len(bytes.TrimSpace(p.knownHosts)) == 0 {
+		return nil, errors.New("known_hosts entries are required to verify the SSH host key")
+	}
+	digest := sha256.Sum256(p.knownHosts)
+	key := hex.EncodeToString(digest[:])
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	if callback, ok := knownHostsCallbacks[key]; ok {
+		return callback, nil
+	}
+	file, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(p.knownHosts); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	if len(knownHostsCallbacks) >= maxKnownHostsCallbacks {
+		for evicted := range knownHostsCallbacks {
+			delete(knownHostsCallbacks, evicted)
+			break
+		}
+	}
+	knownHostsCallbacks[key] = callback
+	return callback, nil
+}
+
+// placeholderHostKey is a host key no known_hosts entry holds, checked to list
+// the known keys of a host.
+var placeholderHostKey, _ = ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+
+// hostKeyAlgorithms returns the algorithms of the host keys known for addr, so
+// that servers offering several host keys present a known one. It returns nil,
+// the default algorithms, when no key is known for addr.
+func hostKeyAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	var keyErr *knownhosts.KeyError
+	if err := callback(addr, &net.TCPAddr{}, placeholderHostKey); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		keyAlgorithms := []string{known.Key.Type()}
+		if known.Key.Type() == ssh.KeyAlgoRSA {
+			// RSA keys sign with SHA-2 on current servers, see RFC 8332.
+			keyAlgorithms = []string{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSA}
+		}
+		for _, algorithm := range keyAlgorithms {
+			if !slices.Contains(algorithms, algorithm) {
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	return algorithms
+}
+
+// dialSSH opens an SSH connection to addr, which is closed if ctx is done
+// before the connection is established.
+func dialSSH(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// sshError maps an SSH connection failure onto a GitConditionReason.
+func sshError(err error) error {
+	var keyErr *knownhosts.KeyError
+	switch {
+	case errors.As(err, &keyErr):
+		return newError(ReasonHostKeyVerificationFailed, err)
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		return newError(ReasonAuthenticationFailed, err)
+	default:
+		return transportError(err)
+	}
+}
+
+// isNotFoundMessage reports whether a Git server error message says the repository does not exist.
+func isNotFoundMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "not found") || strings.Contains(msg, "does not exist") ||
+		strings.Contains(msg, "does not appear to be a git repository")
+}
+
+// shellQuote quotes s for the POSIX shell, as Git does for the remote command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,253 @@
+package gitservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeSSHServer is an in-process SSH server answering git-upload-pack with the
+// reference advertisement of the conformance repository.
+type fakeSSHServer struct {
+	addr string
+	// knownHosts is the known_hosts line of the server host key.
+	knownHosts []byte
+	// clientKey is the PEM encoded private key the server accepts.
+	clientKey []byte
+}
+
+func newFakeSSHServer(t *testing.T) *fakeSSHServer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+	clientPub, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	authorized, err := ssh.NewPublicKey(clientPub)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() != defaultSSHUser || !bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, errors.New("unauthorized")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+	// The server also offers a host key missing from known_hosts, and preferred
+	// by the default algorithms of the client.
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecdsaSigner, err := ssh.NewSignerFromKey(ecdsaKey)
+	require.NoError(t, err)
+	config.AddHostKey(ecdsaSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSSHConn(conn, config)
+		}
+	}()
+
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	return &fakeSSHServer{
+		addr:       addr,
+		knownHosts: []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostSigner.PublicKey()) + "\n"),
+		clientKey:  pem.EncodeToMemory(block),
+	}
+}
+
+func serveFakeSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				_ = req.Reply(true, nil)
+				var exec struct{ Command string }
+				_ = ssh.Unmarshal(req.Payload, &exec)
+				status := uint32(0)
+				if exec.Command == "git-upload-pack '/"+conformanceOwner+"/"+conformanceName+".git'" {
+					_, _ = channel.Write([]byte(
+						pktLine(conformanceSHA+" HEAD\x00symref=HEAD:refs/heads/"+conformanceBranch+"\n") +
+							pktLine(conformanceSHA+" refs/heads/"+conformanceBranch+"\n") +
+							pktLine(conformanceTagSHA+" refs/tags/"+conformanceTag+"\n") +
+							pktLine(conformanceSHA+" refs/tags/"+conformanceTag+"^{}\n") + "0000"))
+					flush := make([]byte, 4)
+					_, _ = channel.Read(flush)
+				} else {
+					_, _ = channel.Stderr().Write([]byte("ERROR: Repository not found.\n"))
+					status = 1
+				}
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+func (s *fakeSSHServer) gitURL() string {
+	return "ssh://git@" + s.addr + "/" + conformanceOwner + "/" + conformanceName + ".git"
+}
+
+func TestSSHProviderConformance(t *testing.T) {
+	runProviderConformance(t, func(t *testing.T) providerFixture {
+		server := newFakeSSHServer(t)
+		provider, err := newSSHProvider(ProviderOptions{
+			Credentials: Credentials{SSHPrivateKey: server.clientKey, KnownHosts: server.knownHosts},
+			Logger:      testLogger,
+		})
+		require.NoError(t, err)
+		return providerFixture{provider: provider, gitURL: server.gitURL()}
+	})
+}
+
+func TestSSHProviderFailures(t *testing.T) {
+	server := newFakeSSHServer(t)
+	otherServer := newFakeSSHServer(t)
+	tests := []struct {
+		name        string
+		credentials Credentials
+		want        GitConditionReason
+	}{
+		{"Missing private key", Credentials{KnownHosts: server.knownHosts}, ReasonSSHKeyRequired},
+		{
+			"Invalid private key",
+			Credentials{SSHPrivateKey: []byte("not a key"), KnownHosts: server.knownHosts},
+			ReasonSSHKeyRequired,
+		},
+		{"Missing known_hosts", Credentials{SSHPrivateKey: server.clientKey}, ReasonHostKeyVerificationFailed},
+		{
+			"Unknown host key",
+			Credentials{SSHPrivateKey: server.clientKey, KnownHosts: otherServer.knownHosts},
+			ReasonHostKeyVerificationFailed,
+		},
+		{
+			"Unauthorized key",
+			Credentials{SSHPrivateKey: otherServer.clientKey, KnownHosts: server.knownHosts},
+			ReasonAuthenticationFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := New(server.gitURL(), conformanceBranch, tt.credentials, testLogger).IsRepoReachable(context.Background())
+			assert.Equal(t, metav1.ConditionFalse, status)
+			assert.Equal(t, tt.want, reason)
+		})
+	}
+}
+
+func TestSSHGitService(t *testing.T) {
+	server := newFakeSSHServer(t)
+	credentials := Credentials{SSHPrivateKey: server.clientKey, KnownHosts: server.knownHosts}
+
+	gs := New(server.gitURL(), conformanceBranch, credentials, testLogger)
+	assert.Equal(t, SSH, gs.gitType)
+	status, reason := gs.IsRepoReachable(context.Background())
+	assert.Equal(t, metav1.ConditionTrue, status)
+	assert.Equal(t, ReasonSucceeded, reason)
+}
+
+func TestHostKeyCallbackCache(t *testing.T) {
+	server := newFakeSSHServer(t)
+	provider := &sshProvider{knownHosts: server.knownHosts}
+	callback, err := provider.hostKeyCallback()
+	require.NoError(t, err)
+	_, err = provider.hostKeyCallback()
+	require.NoError(t, err)
+	digest := sha256.Sum256(server.knownHosts)
+	knownHostsMu.Lock()
+	_, cached := knownHostsCallbacks[hex.EncodeToString(digest[:])]
+	knownHostsMu.Unlock()
+	assert.True(t, cached)
+
+	assert.Equal(t, []string{ssh.KeyAlgoED25519}, hostKeyAlgorithms(callback, server.addr))
+	assert.Nil(t, hostKeyAlgorithms(callback, "unknown.example.com:22"), "unknown hosts use the default algorithms")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPub, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	provider = &sshProvider{knownHosts: []byte(knownhosts.Line([]string{"git.example.com"}, rsaPub) + "\n")}
+	callback, err = provider.hostKeyCallback()
+	require.NoError(t, err)
+	assert.Equal(t, []string{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSA},
+		hostKeyAlgorithms(callback, "git.example.com:22"))
+}
+
+func TestParseSSHURL(t *testing.T) {
+	provider, err := newSSHProvider(ProviderOptions{Logger: testLogger})
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		gitURL   string
+		owner    string
+		repo     string
+		cloneURL string
+	}{
+		{"scp-like", "git@github.com:org/repo.git", "org", "repo", "git@github.com:org/repo.git"},
+		{"scp-like without user", "github.com:org/repo", "org", "repo", "github.com:org/repo"},
+		{"ssh", "ssh://git@github.com/org/repo.git", "org", "repo", "ssh://git@github.com/org/repo.git"},
+		{"ssh with port", "ssh://git@git.example.com:2222/repo.git", "", "repo", "ssh://git@git.example.com:2222/repo.git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, SSH, identifyGitType(tt.gitURL))
+			repo, err := parseRepository(provider, tt.gitURL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.owner, repo.Owner)
+			assert.Equal(t, tt.repo, repo.Name)
+			assert.Equal(t, tt.cloneURL, repo.CloneURL)
+		})
+	}
+}
+
+func TestParseGitURLPortIsNotSCPLike(t *testing.T) {
+	u, err := parseGitURL("github.com:8080/hello/world")
+	require.NoError(t, err)
+	assert.Equal(t, "https", u.Scheme)
+	assert.Equal(t, Github, identifyGitType("github.com:8080/hello/world"))
+	assert.Equal(t, SSH, identifyGitType("git@github.com:org/repo.git"))
+}
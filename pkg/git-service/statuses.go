@@ -0,0 +1,64 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// maxStatusDescription is the longest commit status description, the limit of Github.
+const maxStatusDescription = 140
+
+// CommitState is the outcome reported by a commit status.
+type CommitState string
+
+const (
+	// CommitStatePending reports that the commit is being checked.
+	CommitStatePending CommitState = "pending"
+
+	// CommitStateSuccess reports that the commit passed the check of the status context.
+	CommitStateSuccess CommitState = "success"
+
+	// CommitStateFailure reports that the commit failed the check of the status context.
+	CommitStateFailure CommitState = "failure"
+)
+
+// CommitStatus is a status of a commit, shown next to it and on the pull
+// requests containing it.
+type CommitStatus struct {
+	// State is the outcome reported by the status.
+	State CommitState
+
+	// Context tells the statuses of different systems apart. A status replaces
+	// the previous one of the same commit and context.
+	Context string
+
+	// Description is a short summary of the outcome.
+	Description string
+
+	// TargetURL is the page the status links to, if any.
+	TargetURL string
+}
+
+// StatusProvider is implemented by the providers able to set the statuses of
+// the commits of a repository.
+type StatusProvider interface {
+	// SetCommitStatus sets the status of the commit with sha.
+	SetCommitStatus(ctx context.Context, repo *Repository, sha string, status CommitStatus) error
+}
+
+// SetCommitStatus sets the status of the commit of the repository with sha.
+// Descriptions are truncated to the length every provider accepts.
+func (g *GitService) SetCommitStatus(ctx context.Context, sha string, status CommitStatus) error {
+	if g.provider == nil {
+		return newError(g.reason, errors.New("the Git URL cannot be used"))
+	}
+	provider, ok := g.provider.(StatusProvider)
+	if !ok {
+		return newError(ReasonUnsupportedGitType, fmt.Errorf("%s repositories have no commit statuses", g.gitType))
+	}
+	if runes := []rune(status.Description); len(runes) > maxStatusDescription {
+		status.Description = string(runes[:maxStatusDescription-1]) + "…"
+	}
+	return provider.SetCommitStatus(ctx, g.repository, sha, status)
+}
@@ -0,0 +1,153 @@
+package gitservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const statusSHA = "0123456789abcdef0123456789abcdef01234567"
+
+// fakeStatuses is a fake of the commit statuses API of a repository, keeping
+// the last status posted to path.
+type fakeStatuses struct {
+	path   string
+	status map[string]any
+}
+
+func (f *fakeStatuses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != f.path {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"message": "Not Found"})
+		return
+	}
+	f.status = map[string]any{}
+	if err := json.NewDecoder(r.Body).Decode(&f.status); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, f.status)
+}
+
+func TestStatusProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses *fakeStatuses
+		// newProvider returns the provider under test talking to apiURL.
+		newProvider func(apiURL string) (Provider, error)
+		// wantState is the state of a successful status on the provider.
+		wantState string
+		// fields are the names of the state, context, description and URL of a status.
+		fields [4]string
+	}{
+		{
+			name:     "Github",
+			statuses: &fakeStatuses{path: "/api/v3/repos/hello/world/statuses/" + statusSHA},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGithubProvider(ProviderOptions{APIURL: apiURL + "/api/v3/", Credentials: testCredentials,
+					Logger: testLogger})
+			},
+			wantState: "success",
+			fields:    [4]string{"state", "context", "description", "target_url"},
+		},
+		{
+			name:     "Gitlab",
+			statuses: &fakeStatuses{path: "/api/v4/projects/hello/world/statuses/" + statusSHA},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGitlabProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials, Logger: testLogger})
+			},
+			wantState: "success",
+			fields:    [4]string{"state", "name", "description", "target_url"},
+		},
+		{
+			name:     "Bitbucket",
+			statuses: &fakeStatuses{path: "/repositories/hello/world/commit/" + statusSHA + "/statuses/build"},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newBitbucketProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials, Logger: testLogger})
+			},
+			wantState: "SUCCESSFUL",
+			fields:    [4]string{"state", "name", "description", "url"},
+		},
+		{
+			name:     "Bitbucket Data Center",
+			statuses: &fakeStatuses{path: "/projects/hello/repos/world/commits/" + statusSHA + "/builds"},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newBitbucketServerProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials,
+					Logger: testLogger})
+			},
+			wantState: "SUCCESSFUL",
+			fields:    [4]string{"state", "name", "description", "url"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.statuses)
+			t.Cleanup(server.Close)
+			p, err := tt.newProvider(server.URL)
+			require.NoError(t, err)
+			provider, ok := p.(StatusProvider)
+			require.True(t, ok)
+			repo := &Repository{Host: "git.example.com", Owner: conformanceOwner, Name: conformanceName}
+
+			err = provider.SetCommitStatus(context.Background(), repo, statusSHA, CommitStatus{
+				State:       CommitStateSuccess,
+				Context:     "console-application/default/hello",
+				Description: "Deployed",
+				TargetURL:   "https://hello.apps.example.com",
+			})
+			require.NoError(t, err)
+			status := tt.statuses.status
+			assert.Equal(t, tt.wantState, status[tt.fields[0]])
+			assert.Equal(t, "console-application/default/hello", status[tt.fields[1]])
+			assert.Equal(t, "Deployed", status[tt.fields[2]])
+			assert.Equal(t, "https://hello.apps.example.com", status[tt.fields[3]])
+
+			err = provider.SetCommitStatus(context.Background(), &Repository{Owner: "missing", Name: "repo"},
+				statusSHA, CommitStatus{State: CommitStatePending})
+			assert.Equal(t, ReasonRepoNotFound, ReasonForError(err))
+		})
+	}
+}
+
+func TestBitbucketStatus(t *testing.T) {
+	status := bitbucketStatus(CommitStatus{State: CommitStatePending, Context: "short"})
+	assert.Equal(t, "short", status["key"])
+	assert.Equal(t, "INPROGRESS", status["state"])
+
+	long := strings.Repeat("console-application/", 3)
+	status = bitbucketStatus(CommitStatus{State: CommitStateFailure, Context: long})
+	assert.Len(t, status["key"], 40)
+	assert.Equal(t, long, status["name"])
+	assert.Equal(t, "FAILED", status["state"])
+}
+
+func TestSetCommitStatus(t *testing.T) {
+	statuses := &fakeStatuses{path: "/api/v3/repos/hello/world/statuses/" + statusSHA}
+	server := httptest.NewServer(statuses)
+	t.Cleanup(server.Close)
+	registerTestHost(t, HostConfig{Host: "github.example.com", Type: Github, APIURL: server.URL + "/api/v3/"})
+	gs := New("https://github.example.com/hello/world", conformanceBranch, testCredentials, testLogger)
+
+	err := gs.SetCommitStatus(context.Background(), statusSHA, CommitStatus{
+		State:       CommitStateFailure,
+		Context:     "console-application/default/hello",
+		Description: strings.Repeat("é", 200),
+	})
+	require.NoError(t, err)
+	assert.Len(t, []rune(statuses.status["description"].(string)), maxStatusDescription)
+	assert.NotContains(t, statuses.status, "target_url")
+}
+
+func TestSetCommitStatusUnsupported(t *testing.T) {
+	server := newFakeSmartHTTPServer(t)
+	gs := New(server.URL+"/hello/world.git", conformanceBranch, testCredentials, testLogger)
+	err := gs.SetCommitStatus(context.Background(), statusSHA, CommitStatus{State: CommitStatePending})
+	assert.Equal(t, ReasonUnsupportedGitType, ReasonForError(err))
+}
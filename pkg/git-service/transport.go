@@ -0,0 +1,280 @@
+package gitservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	// DefaultRequestTimeout is the default timeout of each attempt of a Git request.
+	DefaultRequestTimeout = 10 * time.Second
+
+	// DefaultRequestRetries is the default number of retries of a Git request
+	// failing with a timeout, a connection error or an unavailable server.
+	DefaultRequestRetries = 2
+
+	// retryBaseDelay is the delay before the first retry, doubled for each
+	// following one and randomized to spread the retries of concurrent requests.
+	retryBaseDelay = 250 * time.Millisecond
+)
+
+// retryTransport bounds each attempt of a request with a timeout, and retries
+// idempotent requests after transient failures with an exponential backoff.
+type retryTransport struct {
+	timeout time.Duration
+	retries int
+	base    http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.roundTrip(req)
+		if attempt >= t.retries || !isIdempotent(req) || req.Context().Err() != nil || !isRetryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(retryDelay(attempt))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// roundTrip sends one attempt of req, canceled after the timeout unless its
+// response body is closed before.
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the timeout of an attempt once its body is read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// isIdempotent reports whether req can safely be sent again.
+func isIdempotent(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// isRetryable reports whether a request failed transiently: a timeout, a
+// connection error or an unavailable server. DNS and TLS failures are not
+// retried, as they come from the configuration rather than the network.
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		reason := ReasonForError(transportError(err))
+		return reason == ReasonTimeout || reason == ReasonRepoNotReachable
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryDelay returns the delay before the retry following attempt: half of an
+// exponential backoff, plus a random jitter up to the other half.
+func retryDelay(attempt int) time.Duration {
+	backoff := retryBaseDelay << attempt
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// ProxyConfig holds the proxy settings of Git requests, in the format of the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type ProxyConfig struct {
+	// HTTPProxy is the proxy URL of http requests.
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy is the proxy URL of https requests.
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy is a comma-separated list of hosts, domains and CIDRs reached
+	// without proxy.
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// isZero reports whether p holds no proxy setting.
+func (p ProxyConfig) isZero() bool {
+	return p == ProxyConfig{}
+}
+
+// TransportConfig holds the TLS material of the requests to a host, usually
+// read from the ConfigMap and Secret referenced by its HostConfig.
+type TransportConfig struct {
+	// CABundle holds PEM certificates trusted for the host, in addition to the
+	// certificates of the system.
+	CABundle []byte
+
+	// ClientCertificate and ClientKey are the PEM certificate and private key
+	// presented to hosts requiring mutual TLS.
+	ClientCertificate []byte
+	ClientKey         []byte
+}
+
+// equal reports whether c and other hold the same material.
+func (c TransportConfig) equal(other TransportConfig) bool {
+	return bytes.Equal(c.CABundle, other.CABundle) && bytes.Equal(c.ClientCertificate, other.ClientCertificate) &&
+		bytes.Equal(c.ClientKey, other.ClientKey)
+}
+
+// tlsConfig returns the TLS configuration of c, or nil if c holds no material.
+func (c TransportConfig) tlsConfig() (*tls.Config, error) {
+	if len(c.CABundle) == 0 && len(c.ClientCertificate) == 0 && len(c.ClientKey) == 0 {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(c.CABundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(c.CABundle) {
+			return nil, errors.New("the CA bundle holds no PEM certificate")
+		}
+		config.RootCAs = pool
+	}
+	if len(c.ClientCertificate) > 0 || len(c.ClientKey) > 0 {
+		certificate, err := tls.X509KeyPair(c.ClientCertificate, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// hostTLS is the TLS configuration of a host, built from its TransportConfig.
+type hostTLS struct {
+	source TransportConfig
+	config *tls.Config
+}
+
+var (
+	hostTransportsMu sync.RWMutex
+	hostTLSConfigs   = map[string]hostTLS{}
+	clusterProxy     ProxyConfig
+)
+
+// SetHostTransport sets the TLS material of the requests to host. The
+// transports of the host are recreated when the material changes, an empty
+// TransportConfig restoring the defaults.
+func SetHostTransport(host string, config TransportConfig) error {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return err
+	}
+	host = normalizeHost(host)
+	hostTransportsMu.Lock()
+	if hostTLSConfigs[host].source.equal(config) {
+		hostTransportsMu.Unlock()
+		return nil
+	}
+	if tlsConfig == nil {
+		delete(hostTLSConfigs, host)
+	} else {
+		hostTLSConfigs[host] = hostTLS{source: config, config: tlsConfig}
+	}
+	hostTransportsMu.Unlock()
+	dropTransports(host)
+	return nil
+}
+
+// SetClusterProxy sets the proxy of the requests to the hosts without a proxy
+// of their own, typically from the cluster-wide Proxy object of OpenShift. A
+// zero ProxyConfig restores the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables.
+func SetClusterProxy(proxy ProxyConfig) {
+	hostTransportsMu.Lock()
+	if clusterProxy == proxy {
+		hostTransportsMu.Unlock()
+		return
+	}
+	clusterProxy = proxy
+	hostTransportsMu.Unlock()
+	dropTransports("")
+}
+
+// dropTransports forgets the shared transports of host, or of every host if
+// host is empty, so that the next requests pick up new settings.
+func dropTransports(host string) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	for key := range transports {
+		if host == "" || strings.HasPrefix(key, host+"/") {
+			delete(transports, key)
+		}
+	}
+}
+
+// hostTransport returns the transport sending the requests to host, with its
+// TLS material and proxy.
+func hostTransport(host string) http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc(host)
+	hostTransportsMu.RLock()
+	if tlsConfig, ok := hostTLSConfigs[host]; ok {
+		t.TLSClientConfig = tlsConfig.config.Clone()
+	}
+	hostTransportsMu.RUnlock()
+	return t
+}
+
+// proxyFunc returns the proxy selection of the requests to host: the proxy of
+// its HostConfig, else the cluster proxy, else the environment.
+func proxyFunc(host string) func(*http.Request) (*url.URL, error) {
+	hostTransportsMu.RLock()
+	proxy := clusterProxy
+	hostTransportsMu.RUnlock()
+	if config, ok := hostConfigFor(host); ok && config.Proxy != nil {
+		proxy = *config.Proxy
+	}
+	var selectProxy func(*url.URL) (*url.URL, error)
+	if proxy.isZero() {
+		selectProxy = httpproxy.FromEnvironment().ProxyFunc()
+	} else {
+		selectProxy = (&httpproxy.Config{
+			HTTPProxy:  proxy.HTTPProxy,
+			HTTPSProxy: proxy.HTTPSProxy,
+			NoProxy:    proxy.NoProxy,
+		}).ProxyFunc()
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		return selectProxy(req.URL)
+	}
+}
@@ -0,0 +1,201 @@
+package gitservice
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingServer returns a server answering with the statuses in turn, then
+// 200 OK, and the number of requests it received.
+func newCountingServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := int(requests.Add(1)); n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetryTransportRetriesTransientFailures(t *testing.T) {
+	server, requests := newCountingServer(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	client := &http.Client{Transport: &retryTransport{timeout: time.Second, retries: 2, base: http.DefaultTransport}}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	server, requests := newCountingServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	client := &http.Client{Transport: &retryTransport{timeout: time.Second, retries: 1, base: http.DefaultTransport}}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRetryTransportDoesNotRetryPermanentFailures(t *testing.T) {
+	server, requests := newCountingServer(t, http.StatusNotFound)
+	client := &http.Client{Transport: &retryTransport{timeout: time.Second, retries: 2, base: http.DefaultTransport}}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+
+	server, requests = newCountingServer(t, http.StatusServiceUnavailable)
+	resp, err = client.Post(server.URL, "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), requests.Load(), "not idempotent")
+}
+
+func TestRetryTransportTimeout(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	client := newAPIClient(server.URL, &http.Client{
+		Transport: &retryTransport{timeout: 50 * time.Millisecond, retries: 1, base: http.DefaultTransport},
+	})
+
+	_, err := client.get(context.Background(), "/", nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, ReasonTimeout, ReasonForError(transportError(err)))
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRetryTransportStopsWhenCanceled(t *testing.T) {
+	server, requests := newCountingServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	client := &http.Client{Transport: &retryTransport{timeout: time.Second, retries: 5, base: http.DefaultTransport}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	time.AfterFunc(retryBaseDelay/4, cancel)
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt := 0; attempt < 4; attempt++ {
+		backoff := retryBaseDelay << attempt
+		delay := retryDelay(attempt)
+		assert.GreaterOrEqual(t, delay, backoff/2)
+		assert.Less(t, delay, backoff)
+	}
+}
+
+// newClientCertificate returns a self-signed PEM client certificate and key.
+func newClientCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "console-application-operator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// serverCABundle returns the certificate of a TLS test server as a PEM CA bundle.
+func serverCABundle(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func TestHostTransportTLS(t *testing.T) {
+	certPEM, keyPEM := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(certPEM))
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	// Test servers listen on 127.0.0.1, the port is not part of the host.
+	const host = "127.0.0.1"
+	t.Cleanup(func() { require.NoError(t, SetHostTransport(host, TransportConfig{})) })
+	get := func() error {
+		client := newAPIClient(server.URL, newHTTPClient(ProviderOptions{Host: host, Timeout: time.Second}))
+		_, err := client.get(context.Background(), "/", nil, nil)
+		return err
+	}
+
+	assert.Equal(t, ReasonTLSError, ReasonForError(transportError(get())), "untrusted server")
+
+	require.NoError(t, SetHostTransport(host, TransportConfig{CABundle: serverCABundle(server)}))
+	assert.Error(t, get(), "missing client certificate")
+
+	require.NoError(t, SetHostTransport(host, TransportConfig{
+		CABundle:          serverCABundle(server),
+		ClientCertificate: certPEM,
+		ClientKey:         keyPEM,
+	}))
+	assert.NoError(t, get())
+}
+
+func TestSetHostTransportRejectsInvalidMaterial(t *testing.T) {
+	assert.Error(t, SetHostTransport("git.example.com", TransportConfig{CABundle: []byte("not a certificate")}))
+	assert.Error(t, SetHostTransport("git.example.com", TransportConfig{ClientCertificate: []byte("nope")}))
+}
+
+func TestProxyFunc(t *testing.T) {
+	registerTestHost(t, HostConfig{Host: "gitlab.proxy.example.com", Type: Gitlab, Proxy: &ProxyConfig{
+		HTTPSProxy: "http://gitlab-proxy.example.com:3128",
+	}})
+	t.Cleanup(func() { SetClusterProxy(ProxyConfig{}) })
+	SetClusterProxy(ProxyConfig{
+		HTTPSProxy: "http://proxy.example.com:3128",
+		NoProxy:    ".internal.example.com",
+	})
+
+	proxyFor := func(host, rawURL string) string {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		require.NoError(t, err)
+		proxy, err := proxyFunc(host)(req)
+		require.NoError(t, err)
+		if proxy == nil {
+			return ""
+		}
+		return proxy.String()
+	}
+	assert.Equal(t, "http://proxy.example.com:3128", proxyFor("github.com", "https://api.github.com/repos"))
+	assert.Equal(t, "", proxyFor("git.internal.example.com", "https://git.internal.example.com/api/v1"))
+	assert.Equal(t, "http://gitlab-proxy.example.com:3128",
+		proxyFor("gitlab.proxy.example.com", "https://gitlab.proxy.example.com/api/v4"))
+}
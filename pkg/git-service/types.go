@@ -16,6 +16,27 @@ const (
 	// Gitlab is the Gitlab provider
 	Gitlab GitProvider = "gitlab"
 
+	// Bitbucket is the Bitbucket Cloud provider
+	Bitbucket GitProvider = "bitbucket"
+
+	// BitbucketServer is the Bitbucket Data Center (formerly Bitbucket Server) provider
+	BitbucketServer GitProvider = "bitbucket-server"
+
+	// Gitea is the Gitea provider
+	Gitea GitProvider = "gitea"
+
+	// Forgejo is the Forgejo provider, which serves the Gitea API
+	Forgejo GitProvider = "forgejo"
+
+	// AzureDevOps is the Azure DevOps Repos provider
+	AzureDevOps GitProvider = "azure-devops"
+
+	// SmartHTTP is the fallback provider for any Git server speaking the Git HTTP protocol
+	SmartHTTP GitProvider = "git"
+
+	// SSH is the provider for Git SSH URLs, which speaks the Git protocol over SSH
+	SSH GitProvider = "ssh"
+
 	// Unknown is the unknown provider
 	Unknown GitProvider = "unknown"
 
@@ -25,6 +46,9 @@ const (
 	// ReasonSucceeded indicates the condition is succeeded
 	ReasonSucceeded GitConditionReason = "Succeeded"
 
+	// ReasonCommitNotVerified indicates the repository is reachable but the commit cannot be checked to exist
+	ReasonCommitNotVerified GitConditionReason = "CommitNotVerified"
+
 	// ReasonRepoNotFound indicates the repository was not found
 	ReasonRepoNotFound GitConditionReason = "RepoNotFound"
 
@@ -42,6 +66,63 @@ const (
 
 	// ReasonAccessTokenRequired indicates the Gitlab URL is not reachable because it requires an access token
 	ReasonAccessTokenRequired GitConditionReason = "AccessTokenRequired"
+
+	// ReasonSSHKeyRequired indicates the Git SSH URL is not reachable because it requires an SSH private key
+	ReasonSSHKeyRequired GitConditionReason = "SSHKeyRequired"
+
+	// ReasonHostKeyVerificationFailed indicates the SSH host key is not listed in the known_hosts entries
+	ReasonHostKeyVerificationFailed GitConditionReason = "HostKeyVerificationFailed"
+
+	// ReasonAuthenticationFailed indicates the Git server rejected the provided credentials
+	ReasonAuthenticationFailed GitConditionReason = "AuthenticationFailed"
+
+	// ReasonSecretKeyMissing indicates the source secret lacks the key holding the credentials
+	ReasonSecretKeyMissing GitConditionReason = "SecretKeyMissing"
+
+	// ReasonUnsupportedSecretType indicates the source secret type cannot hold Git credentials
+	ReasonUnsupportedSecretType GitConditionReason = "UnsupportedSecretType"
+
+	// ReasonInsecureCredentials indicates the credentials would be sent over plain HTTP
+	ReasonInsecureCredentials GitConditionReason = "InsecureCredentials"
+
+	// ReasonInvalidSecretKey indicates a key of the source secret holds a malformed value
+	ReasonInvalidSecretKey GitConditionReason = "InvalidSecretKey"
+
+	// ReasonContextDirFound indicates the context directory exists in the repository
+	ReasonContextDirFound GitConditionReason = "ContextDirFound"
+
+	// ReasonContextDirNotFound indicates the context directory does not exist in the repository
+	ReasonContextDirNotFound GitConditionReason = "ContextDirNotFound"
+
+	// ReasonFileNotFound indicates the requested file does not exist in the repository
+	ReasonFileNotFound GitConditionReason = "FileNotFound"
+
+	// ReasonPrivateRepo indicates the Git server refused to show the private repository without credentials
+	ReasonPrivateRepo GitConditionReason = "PrivateRepo"
+
+	// ReasonRepoArchived indicates the repository is archived and read-only
+	ReasonRepoArchived GitConditionReason = "RepoArchived"
+
+	// ReasonForbidden indicates the credentials are valid but not allowed to access the repository
+	ReasonForbidden GitConditionReason = "Forbidden"
+
+	// ReasonServerError indicates the Git server failed to answer the request
+	ReasonServerError GitConditionReason = "ServerError"
+
+	// ReasonDNSLookupFailed indicates the Git host name cannot be resolved
+	ReasonDNSLookupFailed GitConditionReason = "DNSLookupFailed"
+
+	// ReasonTLSError indicates the TLS certificate of the Git host cannot be verified
+	ReasonTLSError GitConditionReason = "TLSError"
+
+	// ReasonTimeout indicates the Git host did not answer in time
+	ReasonTimeout GitConditionReason = "Timeout"
+
+	// ReasonWebhookNotFound indicates the webhook of the repository was deleted on the Git provider
+	ReasonWebhookNotFound GitConditionReason = "WebhookNotFound"
+
+	// ReasonDeploymentNotFound indicates the deployment record was deleted on the Git provider
+	ReasonDeploymentNotFound GitConditionReason = "DeploymentNotFound"
 )
 
 // String casts the value to string.
@@ -0,0 +1,91 @@
+package gitservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// webhookName names the webhooks created by the operator, on the providers
+// asking for one.
+const webhookName = "console-application-operator"
+
+// WebhookEventsVersion identifies the events the webhooks are created with:
+// 1 for the push events, 2 for the push and pull request events. It is bumped
+// when the events change, so that the webhooks already registered are updated.
+const WebhookEventsVersion = "2"
+
+// Webhook is a repository webhook delivering the push and pull request events
+// to the operator.
+type Webhook struct {
+	// URL is the endpoint the events are delivered to.
+	URL string
+
+	// Secret signs the deliveries, or is sent with them on Gitlab.
+	Secret string
+}
+
+// WebhookProvider is implemented by the providers able to manage the webhooks
+// of a repository. Webhook IDs are opaque strings, whatever the provider uses.
+type WebhookProvider interface {
+	// CreateWebhook creates a webhook delivering the push and pull request
+	// events of the repository and returns its ID.
+	CreateWebhook(ctx context.Context, repo *Repository, hook Webhook) (string, error)
+
+	// UpdateWebhook updates the webhook with id. A missing webhook is reported
+	// with ReasonWebhookNotFound.
+	UpdateWebhook(ctx context.Context, repo *Repository, id string, hook Webhook) error
+
+	// DeleteWebhook deletes the webhook with id. A missing webhook is reported
+	// with ReasonWebhookNotFound.
+	DeleteWebhook(ctx context.Context, repo *Repository, id string) error
+}
+
+// webhookProvider returns the provider of the repository when it manages webhooks.
+func (g *GitService) webhookProvider() (WebhookProvider, error) {
+	if g.provider == nil {
+		return nil, newError(g.reason, errors.New("the Git URL cannot be used"))
+	}
+	provider, ok := g.provider.(WebhookProvider)
+	if !ok {
+		return nil, newError(ReasonUnsupportedGitType, fmt.Errorf("%s repositories have no webhooks", g.gitType))
+	}
+	return provider, nil
+}
+
+// SyncWebhook updates the webhook of the repository with id, or creates it
+// when id is empty or the webhook was deleted on the provider. It returns the
+// ID of the webhook.
+func (g *GitService) SyncWebhook(ctx context.Context, id string, hook Webhook) (string, error) {
+	provider, err := g.webhookProvider()
+	if err != nil {
+		return "", err
+	}
+	if id != "" {
+		err := provider.UpdateWebhook(ctx, g.repository, id, hook)
+		if err == nil || ReasonForError(err) != ReasonWebhookNotFound {
+			return id, err
+		}
+		g.logger.Info("Webhook deleted on the Git provider, creating it again", "id", id)
+	}
+	id, err = provider.CreateWebhook(ctx, g.repository, hook)
+	if err != nil {
+		return "", err
+	}
+	g.logger.Info("Created webhook", "id", id)
+	return id, nil
+}
+
+// DeleteWebhook deletes the webhook of the repository with id. A webhook
+// already deleted on the provider is not an error.
+func (g *GitService) DeleteWebhook(ctx context.Context, id string) error {
+	provider, err := g.webhookProvider()
+	if err != nil {
+		return err
+	}
+	err = provider.DeleteWebhook(ctx, g.repository, id)
+	if ReasonForError(err) == ReasonWebhookNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+package gitservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHooks is a fake of the webhooks API of a repository, serving the hooks
+// below collection. Hooks are updated with updateMethod.
+type fakeHooks struct {
+	collection   string
+	updateMethod string
+	// uuid identifies the hooks by a string "uuid", as Bitbucket Cloud does.
+	uuid bool
+
+	mu    sync.Mutex
+	next  int
+	hooks map[string]map[string]any
+}
+
+func (f *fakeHooks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rest, ok := strings.CutPrefix(r.URL.Path, f.collection)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id := strings.Trim(rest, "/")
+	hook := map[string]any{}
+	if r.Body != nil && (r.Method == http.MethodPost || r.Method == f.updateMethod) {
+		if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		f.next++
+		id = strconv.Itoa(f.next)
+		if f.uuid {
+			hook["uuid"] = id
+		} else {
+			hook["id"] = f.next
+		}
+		f.hooks[id] = hook
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, hook)
+	case f.hooks[id] == nil:
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"message": "Not Found"})
+	case r.Method == http.MethodDelete:
+		delete(f.hooks, id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == f.updateMethod:
+		f.hooks[id] = hook
+		writeJSON(w, hook)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebhookProviders(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks *fakeHooks
+		// newProvider returns the provider under test talking to apiURL.
+		newProvider func(apiURL string) (Provider, error)
+		// config returns the URL and secret of a hook as stored by the fake.
+		config func(hook map[string]any) (any, any)
+	}{
+		{
+			name:  "Github",
+			hooks: &fakeHooks{collection: "/api/v3/repos/hello/world/hooks", updateMethod: http.MethodPatch},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGithubProvider(ProviderOptions{APIURL: apiURL + "/api/v3/", Credentials: testCredentials,
+					Logger: testLogger})
+			},
+			config: func(hook map[string]any) (any, any) {
+				config := hook["config"].(map[string]any)
+				return config["url"], config["secret"]
+			},
+		},
+		{
+			name:  "Gitlab",
+			hooks: &fakeHooks{collection: "/api/v4/projects/hello/world/hooks", updateMethod: http.MethodPut},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newGitlabProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials, Logger: testLogger})
+			},
+			config: func(hook map[string]any) (any, any) {
+				return hook["url"], hook["token"]
+			},
+		},
+		{
+			name:  "Bitbucket",
+			hooks: &fakeHooks{collection: "/repositories/hello/world/hooks", updateMethod: http.MethodPut, uuid: true},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newBitbucketProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials, Logger: testLogger})
+			},
+			config: func(hook map[string]any) (any, any) {
+				return hook["url"], hook["secret"]
+			},
+		},
+		{
+			name:  "Bitbucket Data Center",
+			hooks: &fakeHooks{collection: "/projects/hello/repos/world/webhooks", updateMethod: http.MethodPut},
+			newProvider: func(apiURL string) (Provider, error) {
+				return newBitbucketServerProvider(ProviderOptions{APIURL: apiURL, Credentials: testCredentials,
+					Logger: testLogger})
+			},
+			config: func(hook map[string]any) (any, any) {
+				return hook["url"], hook["configuration"].(map[string]any)["secret"]
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tt.hooks.hooks = map[string]map[string]any{}
+			server := httptest.NewServer(tt.hooks)
+			t.Cleanup(server.Close)
+			p, err := tt.newProvider(server.URL)
+			require.NoError(t, err)
+			provider, ok := p.(WebhookProvider)
+			require.True(t, ok)
+			repo := &Repository{Owner: conformanceOwner, Name: conformanceName}
+
+			id, err := provider.CreateWebhook(ctx, repo, Webhook{URL: "https://hooks.example.com/", Secret: "one"})
+			require.NoError(t, err)
+			require.Contains(t, tt.hooks.hooks, id)
+			hookURL, secret := tt.config(tt.hooks.hooks[id])
+			assert.Equal(t, "https://hooks.example.com/", hookURL)
+			assert.Equal(t, "one", secret)
+
+			require.NoError(t, provider.UpdateWebhook(ctx, repo, id, Webhook{URL: "https://hooks.example.com/", Secret: "two"}))
+			_, secret = tt.config(tt.hooks.hooks[id])
+			assert.Equal(t, "two", secret)
+
+			require.NoError(t, provider.DeleteWebhook(ctx, repo, id))
+			assert.Empty(t, tt.hooks.hooks)
+
+			err = provider.UpdateWebhook(ctx, repo, id, Webhook{URL: "https://hooks.example.com/", Secret: "two"})
+			assert.Equal(t, ReasonWebhookNotFound, ReasonForError(err))
+			err = provider.DeleteWebhook(ctx, repo, id)
+			assert.Equal(t, ReasonWebhookNotFound, ReasonForError(err))
+		})
+	}
+}
+
+func TestSyncWebhook(t *testing.T) {
+	ctx := context.Background()
+	hooks := &fakeHooks{
+		collection:   "/api/v3/repos/hello/world/hooks",
+		updateMethod: http.MethodPatch,
+		hooks:        map[string]map[string]any{},
+	}
+	server := httptest.NewServer(hooks)
+	t.Cleanup(server.Close)
+	registerTestHost(t, HostConfig{Host: "github.example.com", Type: Github, APIURL: server.URL + "/api/v3/"})
+	gs := New("https://github.example.com/hello/world", conformanceBranch, testCredentials, testLogger)
+	hook := Webhook{URL: "https://hooks.example.com/", Secret: "s3cr3t"}
+
+	id, err := gs.SyncWebhook(ctx, "", hook)
+	require.NoError(t, err)
+	same, err := gs.SyncWebhook(ctx, id, hook)
+	require.NoError(t, err)
+	assert.Equal(t, id, same, "updated")
+
+	delete(hooks.hooks, id)
+	recreated, err := gs.SyncWebhook(ctx, id, hook)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, recreated, "created again")
+
+	require.NoError(t, gs.DeleteWebhook(ctx, recreated))
+	require.NoError(t, gs.DeleteWebhook(ctx, recreated), "already deleted")
+}
+
+func TestSyncWebhookUnsupported(t *testing.T) {
+	server := newFakeSmartHTTPServer(t)
+	gs := New(server.URL+"/hello/world.git", conformanceBranch, testCredentials, testLogger)
+	_, err := gs.SyncWebhook(context.Background(), "", Webhook{URL: "https://hooks.example.com/"})
+	assert.Equal(t, ReasonUnsupportedGitType, ReasonForError(err))
+}
@@ -0,0 +1,415 @@
+package gitwebhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Provider is the Git provider sending a webhook delivery.
+type Provider string
+
+const (
+	// Github sends push events with a X-Hub-Signature-256 HMAC.
+	Github Provider = "github"
+
+	// Gitlab sends push events with the secret token in X-Gitlab-Token.
+	Gitlab Provider = "gitlab"
+
+	// Bitbucket, Cloud or Data Center, sends push events with a X-Hub-Signature HMAC.
+	Bitbucket Provider = "bitbucket"
+
+	// Unknown is any other sender.
+	Unknown Provider = "unknown"
+)
+
+// zeroSHA is the commit of a deleted reference in push events.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// errIgnoredEvent is returned for deliveries that are neither pushes nor pull
+// request events, like the ping sent when a webhook is created.
+var errIgnoredEvent = errors.New("not a push or pull request event")
+
+// errUnknownProvider is returned for deliveries of an unknown sender.
+var errUnknownProvider = errors.New("the delivery is not from Github, Gitlab or Bitbucket")
+
+// PushEvent is a push to a repository, reported by a webhook delivery.
+type PushEvent struct {
+	// Provider is the Git provider sending the delivery.
+	Provider Provider
+
+	// DeliveryID identifies the delivery. Deliveries without an ID are
+	// identified by the digest of their payload.
+	DeliveryID string
+
+	// RepositoryURLs are the clone and web URLs of the repository.
+	RepositoryURLs []string
+
+	// Updates are the references moved by the push.
+	Updates []RefUpdate
+}
+
+// PullRequestEvent is a pull request, or Gitlab merge request, opened, updated
+// or closed, reported by a webhook delivery.
+type PullRequestEvent struct {
+	// Provider is the Git provider sending the delivery.
+	Provider Provider
+
+	// DeliveryID identifies the delivery. Deliveries without an ID are
+	// identified by the digest of their payload.
+	DeliveryID string
+
+	// RepositoryURLs are the clone and web URLs of the repository the pull
+	// request targets.
+	RepositoryURLs []string
+
+	// Number identifies the pull request in the repository.
+	Number int
+
+	// Action is what happened to the pull request, as named by the provider,
+	// e.g. "opened" or "closed".
+	Action string
+}
+
+// RefUpdate is a reference moved to a new commit.
+type RefUpdate struct {
+	// Ref is the full name of the reference, e.g. "refs/heads/main".
+	Ref string
+
+	// CommitSHA is the commit the reference now points at.
+	CommitSHA string
+}
+
+// providerOf returns the provider sending a delivery with header.
+func providerOf(header http.Header) Provider {
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		return Github
+	case header.Get("X-Gitlab-Event") != "":
+		return Gitlab
+	case header.Get("X-Event-Key") != "":
+		return Bitbucket
+	default:
+		return Unknown
+	}
+}
+
+// ParseEvent parses a webhook delivery of Github, Gitlab, Bitbucket Cloud or
+// Bitbucket Data Center. Deliveries that are not pushes return errIgnoredEvent.
+func ParseEvent(header http.Header, body []byte) (*PushEvent, error) {
+	event := &PushEvent{Provider: providerOf(header)}
+	var err error
+	switch event.Provider {
+	case Github:
+		err = parseGithubPush(header.Get("X-GitHub-Event"), body, event)
+	case Gitlab:
+		err = parseGitlabPush(header.Get("X-Gitlab-Event"), body, event)
+	case Bitbucket:
+		err = parseBitbucketPush(header.Get("X-Event-Key"), body, event)
+	default:
+		return nil, errUnknownProvider
+	}
+	if err != nil {
+		return nil, err
+	}
+	event.DeliveryID = deliveryID(event.Provider, header, body)
+	return event, nil
+}
+
+// ParsePullRequestEvent parses a pull request delivery of Github, Gitlab,
+// Bitbucket Cloud or Bitbucket Data Center. Deliveries that are not about
+// pull requests return errIgnoredEvent.
+func ParsePullRequestEvent(header http.Header, body []byte) (*PullRequestEvent, error) {
+	event := &PullRequestEvent{Provider: providerOf(header)}
+	var err error
+	switch event.Provider {
+	case Github:
+		err = parseGithubPullRequest(header.Get("X-GitHub-Event"), body, event)
+	case Gitlab:
+		err = parseGitlabMergeRequest(header.Get("X-Gitlab-Event"), body, event)
+	case Bitbucket:
+		err = parseBitbucketPullRequest(header.Get("X-Event-Key"), body, event)
+	default:
+		return nil, errUnknownProvider
+	}
+	if err != nil {
+		return nil, err
+	}
+	event.DeliveryID = deliveryID(event.Provider, header, body)
+	return event, nil
+}
+
+// deliveryID returns the ID of a delivery of provider, or the digest of its
+// payload when it has none.
+func deliveryID(provider Provider, header http.Header, body []byte) string {
+	var id string
+	switch provider {
+	case Github:
+		id = header.Get("X-GitHub-Delivery")
+	case Gitlab:
+		id = header.Get("X-Gitlab-Event-UUID")
+	case Bitbucket:
+		// Bitbucket Cloud sends X-Request-UUID, Bitbucket Data Center X-Request-Id.
+		id = header.Get("X-Request-UUID")
+		if id == "" {
+			id = header.Get("X-Request-Id")
+		}
+	}
+	if id == "" {
+		digest := sha256.Sum256(body)
+		id = "sha256:" + hex.EncodeToString(digest[:])
+	}
+	return id
+}
+
+func parseGithubPush(eventType string, body []byte, event *PushEvent) error {
+	if eventType != "push" {
+		return errIgnoredEvent
+	}
+	payload := struct {
+		Ref        string `json:"ref"`
+		After      string `json:"after"`
+		Deleted    bool   `json:"deleted"`
+		HeadCommit *struct {
+			ID string `json:"id"`
+		} `json:"head_commit"`
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+			HTMLURL  string `json:"html_url"`
+			SSHURL   string `json:"ssh_url"`
+		} `json:"repository"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("malformed Github push event: %w", err)
+	}
+	event.RepositoryURLs = nonEmpty(payload.Repository.CloneURL, payload.Repository.HTMLURL,
+		payload.Repository.SSHURL)
+	if payload.Deleted {
+		return nil
+	}
+	sha := payload.After
+	if strings.HasPrefix(payload.Ref, "refs/tags/") && payload.HeadCommit != nil && payload.HeadCommit.ID != "" {
+		// After is the annotated tag object, the head commit is the tagged commit
+		sha = payload.HeadCommit.ID
+	}
+	event.addUpdate(payload.Ref, sha)
+	return nil
+}
+
+func parseGitlabPush(eventType string, body []byte, event *PushEvent) error {
+	if eventType != "Push Hook" && eventType != "Tag Push Hook" {
+		return errIgnoredEvent
+	}
+	payload := struct {
+		Ref         string `json:"ref"`
+		After       string `json:"after"`
+		CheckoutSHA string `json:"checkout_sha"`
+		Project     struct {
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+			WebURL     string `json:"web_url"`
+		} `json:"project"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("malformed Gitlab push event: %w", err)
+	}
+	event.RepositoryURLs = nonEmpty(payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL)
+	sha := payload.After
+	if strings.HasPrefix(payload.Ref, "refs/tags/") && payload.CheckoutSHA != "" {
+		// After is the annotated tag object, the checkout SHA is the tagged commit
+		sha = payload.CheckoutSHA
+	}
+	event.addUpdate(payload.Ref, sha)
+	return nil
+}
+
+// bitbucketLink is a link of the Bitbucket APIs.
+type bitbucketLink struct {
+	Href string `json:"href"`
+}
+
+func parseBitbucketPush(eventType string, body []byte, event *PushEvent) error {
+	switch eventType {
+	case "repo:push":
+		// Bitbucket Cloud
+		payload := struct {
+			Push struct {
+				Changes []struct {
+					New *struct {
+						Type   string `json:"type"`
+						Name   string `json:"name"`
+						Target struct {
+							Hash string `json:"hash"`
+						} `json:"target"`
+					} `json:"new"`
+				} `json:"changes"`
+			} `json:"push"`
+			Repository struct {
+				Links struct {
+					HTML bitbucketLink `json:"html"`
+				} `json:"links"`
+			} `json:"repository"`
+		}{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fmt.Errorf("malformed Bitbucket push event: %w", err)
+		}
+		event.RepositoryURLs = nonEmpty(payload.Repository.Links.HTML.Href)
+		for _, change := range payload.Push.Changes {
+			// Deleted references have no new state.
+			if change.New == nil {
+				continue
+			}
+			prefix := "refs/heads/"
+			if change.New.Type == "tag" {
+				prefix = "refs/tags/"
+			}
+			event.addUpdate(prefix+change.New.Name, change.New.Target.Hash)
+		}
+		return nil
+	case "repo:refs_changed":
+		// Bitbucket Data Center
+		payload := struct {
+			Changes []struct {
+				RefID  string `json:"refId"`
+				ToHash string `json:"toHash"`
+				Type   string `json:"type"`
+			} `json:"changes"`
+			Repository struct {
+				Links struct {
+					Clone []bitbucketLink `json:"clone"`
+					Self  []bitbucketLink `json:"self"`
+				} `json:"links"`
+			} `json:"repository"`
+		}{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fmt.Errorf("malformed Bitbucket push event: %w", err)
+		}
+		for _, link := range append(payload.Repository.Links.Clone, payload.Repository.Links.Self...) {
+			event.RepositoryURLs = append(event.RepositoryURLs, nonEmpty(link.Href)...)
+		}
+		for _, change := range payload.Changes {
+			if change.Type != "DELETE" {
+				event.addUpdate(change.RefID, change.ToHash)
+			}
+		}
+		return nil
+	default:
+		return errIgnoredEvent
+	}
+}
+
+func parseGithubPullRequest(eventType string, body []byte, event *PullRequestEvent) error {
+	if eventType != "pull_request" {
+		return errIgnoredEvent
+	}
+	payload := struct {
+		Action     string `json:"action"`
+		Number     int    `json:"number"`
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+			HTMLURL  string `json:"html_url"`
+			SSHURL   string `json:"ssh_url"`
+		} `json:"repository"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("malformed Github pull request event: %w", err)
+	}
+	event.RepositoryURLs = nonEmpty(payload.Repository.CloneURL, payload.Repository.HTMLURL,
+		payload.Repository.SSHURL)
+	event.Number, event.Action = payload.Number, payload.Action
+	return nil
+}
+
+func parseGitlabMergeRequest(eventType string, body []byte, event *PullRequestEvent) error {
+	if eventType != "Merge Request Hook" {
+		return errIgnoredEvent
+	}
+	payload := struct {
+		ObjectAttributes struct {
+			IID    int    `json:"iid"`
+			Action string `json:"action"`
+		} `json:"object_attributes"`
+		Project struct {
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+			WebURL     string `json:"web_url"`
+		} `json:"project"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("malformed Gitlab merge request event: %w", err)
+	}
+	event.RepositoryURLs = nonEmpty(payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL)
+	event.Number, event.Action = payload.ObjectAttributes.IID, payload.ObjectAttributes.Action
+	return nil
+}
+
+func parseBitbucketPullRequest(eventType string, body []byte, event *PullRequestEvent) error {
+	switch {
+	case strings.HasPrefix(eventType, "pullrequest:"):
+		// Bitbucket Cloud
+		payload := struct {
+			PullRequest struct {
+				ID int `json:"id"`
+			} `json:"pullrequest"`
+			Repository struct {
+				Links struct {
+					HTML bitbucketLink `json:"html"`
+				} `json:"links"`
+			} `json:"repository"`
+		}{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fmt.Errorf("malformed Bitbucket pull request event: %w", err)
+		}
+		event.RepositoryURLs = nonEmpty(payload.Repository.Links.HTML.Href)
+		event.Number, event.Action = payload.PullRequest.ID, strings.TrimPrefix(eventType, "pullrequest:")
+		return nil
+	case strings.HasPrefix(eventType, "pr:"):
+		// Bitbucket Data Center
+		payload := struct {
+			PullRequest struct {
+				ID    int `json:"id"`
+				ToRef struct {
+					Repository struct {
+						Links struct {
+							Clone []bitbucketLink `json:"clone"`
+							Self  []bitbucketLink `json:"self"`
+						} `json:"links"`
+					} `json:"repository"`
+				} `json:"toRef"`
+			} `json:"pullRequest"`
+		}{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fmt.Errorf("malformed Bitbucket pull request event: %w", err)
+		}
+		links := payload.PullRequest.ToRef.Repository.Links
+		for _, link := range append(links.Clone, links.Self...) {
+			event.RepositoryURLs = append(event.RepositoryURLs, nonEmpty(link.Href)...)
+		}
+		event.Number, event.Action = payload.PullRequest.ID, strings.TrimPrefix(eventType, "pr:")
+		return nil
+	default:
+		return errIgnoredEvent
+	}
+}
+
+// addUpdate records that ref moved to sha, unless the reference was deleted.
+func (e *PushEvent) addUpdate(ref, sha string) {
+	if ref != "" && sha != "" && sha != zeroSHA {
+		e.Updates = append(e.Updates, RefUpdate{Ref: ref, CommitSHA: sha})
+	}
+}
+
+// nonEmpty returns the non-empty values.
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,212 @@
+package gitwebhook
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	oldSHA = "1111111111111111111111111111111111111111"
+	newSHA = "2222222222222222222222222222222222222222"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   *PushEvent
+	}{
+		{
+			name:   "Github push",
+			header: http.Header{"X-Github-Event": {"push"}, "X-Github-Delivery": {"72d3162e"}},
+			body: `{"ref": "refs/heads/main", "before": "` + oldSHA + `", "after": "` + newSHA + `",
+				"repository": {"clone_url": "https://github.com/hello/world.git",
+				"html_url": "https://github.com/hello/world", "ssh_url": "git@github.com:hello/world.git"}}`,
+			want: &PushEvent{
+				Provider:   Github,
+				DeliveryID: "72d3162e",
+				RepositoryURLs: []string{"https://github.com/hello/world.git", "https://github.com/hello/world",
+					"git@github.com:hello/world.git"},
+				Updates: []RefUpdate{{Ref: "refs/heads/main", CommitSHA: newSHA}},
+			},
+		},
+		{
+			name:   "Github branch deletion",
+			header: http.Header{"X-Github-Event": {"push"}, "X-Github-Delivery": {"72d3162f"}},
+			body: `{"ref": "refs/heads/feature", "after": "` + zeroSHA + `", "deleted": true,
+				"repository": {"clone_url": "https://github.com/hello/world.git"}}`,
+			want: &PushEvent{
+				Provider:       Github,
+				DeliveryID:     "72d3162f",
+				RepositoryURLs: []string{"https://github.com/hello/world.git"},
+			},
+		},
+		{
+			name:   "Github annotated tag push",
+			header: http.Header{"X-Github-Event": {"push"}, "X-Github-Delivery": {"72d31630"}},
+			body: `{"ref": "refs/tags/v1.0.0", "after": "` + oldSHA + `", "head_commit": {"id": "` + newSHA + `"},
+				"repository": {"clone_url": "https://github.com/hello/world.git"}}`,
+			want: &PushEvent{
+				Provider:       Github,
+				DeliveryID:     "72d31630",
+				RepositoryURLs: []string{"https://github.com/hello/world.git"},
+				Updates:        []RefUpdate{{Ref: "refs/tags/v1.0.0", CommitSHA: newSHA}},
+			},
+		},
+		{
+			name:   "Gitlab tag push",
+			header: http.Header{"X-Gitlab-Event": {"Tag Push Hook"}, "X-Gitlab-Event-Uuid": {"13792a34"}},
+			body: `{"ref": "refs/tags/v1.0.0", "after": "` + oldSHA + `", "checkout_sha": "` + newSHA + `",
+				"project": {"git_http_url": "https://gitlab.com/hello/world.git",
+				"git_ssh_url": "git@gitlab.com:hello/world.git", "web_url": "https://gitlab.com/hello/world"}}`,
+			want: &PushEvent{
+				Provider:   Gitlab,
+				DeliveryID: "13792a34",
+				RepositoryURLs: []string{"https://gitlab.com/hello/world.git", "git@gitlab.com:hello/world.git",
+					"https://gitlab.com/hello/world"},
+				Updates: []RefUpdate{{Ref: "refs/tags/v1.0.0", CommitSHA: newSHA}},
+			},
+		},
+		{
+			name:   "Bitbucket Cloud push",
+			header: http.Header{"X-Event-Key": {"repo:push"}, "X-Request-Uuid": {"afe3a2b4"}},
+			body: `{"push": {"changes": [
+				{"new": {"type": "branch", "name": "main", "target": {"hash": "` + newSHA + `"}}},
+				{"new": null, "old": {"type": "branch", "name": "feature"}}]},
+				"repository": {"links": {"html": {"href": "https://bitbucket.org/hello/world"}}}}`,
+			want: &PushEvent{
+				Provider:       Bitbucket,
+				DeliveryID:     "afe3a2b4",
+				RepositoryURLs: []string{"https://bitbucket.org/hello/world"},
+				Updates:        []RefUpdate{{Ref: "refs/heads/main", CommitSHA: newSHA}},
+			},
+		},
+		{
+			name:   "Bitbucket Data Center push",
+			header: http.Header{"X-Event-Key": {"repo:refs_changed"}, "X-Request-Id": {"d1d5a4f6"}},
+			body: `{"changes": [
+				{"refId": "refs/tags/v1.0.0", "toHash": "` + newSHA + `", "type": "ADD"},
+				{"refId": "refs/heads/old", "toHash": "` + zeroSHA + `", "type": "DELETE"}],
+				"repository": {"links": {
+				"clone": [{"href": "https://bitbucket.example.com/scm/hello/world.git", "name": "http"}],
+				"self": [{"href": "https://bitbucket.example.com/projects/HELLO/repos/world/browse"}]}}}`,
+			want: &PushEvent{
+				Provider:   Bitbucket,
+				DeliveryID: "d1d5a4f6",
+				RepositoryURLs: []string{"https://bitbucket.example.com/scm/hello/world.git",
+					"https://bitbucket.example.com/projects/HELLO/repos/world/browse"},
+				Updates: []RefUpdate{{Ref: "refs/tags/v1.0.0", CommitSHA: newSHA}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEvent(tt.header, []byte(tt.body))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseEventDeliveryDigest(t *testing.T) {
+	body := []byte(`{"ref": "refs/heads/main", "after": "` + newSHA + `"}`)
+	event, err := ParseEvent(http.Header{"X-Github-Event": {"push"}}, body)
+	require.NoError(t, err)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", event.DeliveryID)
+
+	again, err := ParseEvent(http.Header{"X-Github-Event": {"push"}}, body)
+	require.NoError(t, err)
+	assert.Equal(t, event.DeliveryID, again.DeliveryID)
+}
+
+func TestParseEventErrors(t *testing.T) {
+	_, err := ParseEvent(http.Header{"X-Github-Event": {"ping"}}, []byte(`{"zen": "Keep it simple."}`))
+	assert.ErrorIs(t, err, errIgnoredEvent)
+
+	_, err = ParseEvent(http.Header{"X-Gitlab-Event": {"Merge Request Hook"}}, []byte(`{}`))
+	assert.ErrorIs(t, err, errIgnoredEvent)
+
+	_, err = ParseEvent(http.Header{"X-Github-Event": {"push"}}, []byte(`{"ref":`))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errIgnoredEvent)
+
+	_, err = ParseEvent(http.Header{}, []byte(`{}`))
+	assert.Error(t, err, "unknown provider")
+}
+
+func TestParsePullRequestEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   *PullRequestEvent
+	}{
+		{
+			name:   "Github pull request",
+			header: http.Header{"X-Github-Event": {"pull_request"}, "X-Github-Delivery": {"72d3162e"}},
+			body: `{"action": "synchronize", "number": 42,
+				"repository": {"clone_url": "https://github.com/hello/world.git"}}`,
+			want: &PullRequestEvent{
+				Provider:       Github,
+				DeliveryID:     "72d3162e",
+				RepositoryURLs: []string{"https://github.com/hello/world.git"},
+				Number:         42,
+				Action:         "synchronize",
+			},
+		},
+		{
+			name:   "Gitlab merge request",
+			header: http.Header{"X-Gitlab-Event": {"Merge Request Hook"}, "X-Gitlab-Event-Uuid": {"13792a34"}},
+			body: `{"object_attributes": {"iid": 7, "action": "merge"},
+				"project": {"git_http_url": "https://gitlab.com/hello/world.git",
+				"web_url": "https://gitlab.com/hello/world"}}`,
+			want: &PullRequestEvent{
+				Provider:       Gitlab,
+				DeliveryID:     "13792a34",
+				RepositoryURLs: []string{"https://gitlab.com/hello/world.git", "https://gitlab.com/hello/world"},
+				Number:         7,
+				Action:         "merge",
+			},
+		},
+		{
+			name:   "Bitbucket Cloud pull request",
+			header: http.Header{"X-Event-Key": {"pullrequest:fulfilled"}, "X-Request-Uuid": {"afe3a2b4"}},
+			body: `{"pullrequest": {"id": 3},
+				"repository": {"links": {"html": {"href": "https://bitbucket.org/hello/world"}}}}`,
+			want: &PullRequestEvent{
+				Provider:       Bitbucket,
+				DeliveryID:     "afe3a2b4",
+				RepositoryURLs: []string{"https://bitbucket.org/hello/world"},
+				Number:         3,
+				Action:         "fulfilled",
+			},
+		},
+		{
+			name:   "Bitbucket Data Center pull request",
+			header: http.Header{"X-Event-Key": {"pr:opened"}, "X-Request-Id": {"d1d5a4f6"}},
+			body: `{"pullRequest": {"id": 5, "toRef": {"repository": {"links": {
+				"clone": [{"href": "https://bitbucket.example.com/scm/hello/world.git", "name": "http"}]}}}}}`,
+			want: &PullRequestEvent{
+				Provider:       Bitbucket,
+				DeliveryID:     "d1d5a4f6",
+				RepositoryURLs: []string{"https://bitbucket.example.com/scm/hello/world.git"},
+				Number:         5,
+				Action:         "opened",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePullRequestEvent(tt.header, []byte(tt.body))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := ParsePullRequestEvent(http.Header{"X-Github-Event": {"push"}}, []byte(`{}`))
+	assert.ErrorIs(t, err, errIgnoredEvent)
+}
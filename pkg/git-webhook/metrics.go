@@ -0,0 +1,36 @@
+package gitwebhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Results of a webhook delivery.
+const (
+	resultTriggered        = "triggered"
+	resultDuplicate        = "duplicate"
+	resultIgnored          = "ignored"
+	resultNoMatch          = "no_match"
+	resultInvalidSignature = "invalid_signature"
+	resultMalformed        = "malformed"
+	resultError            = "error"
+)
+
+var (
+	// deliveries counts the webhook deliveries by provider and result.
+	deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consoleapplication_git_webhook_deliveries_total",
+		Help: "Number of Git webhook deliveries by provider and result: triggered, duplicate, ignored, " +
+			"no_match, invalid_signature, malformed or error.",
+	}, []string{"provider", "result"})
+
+	// triggers counts the ConsoleApplications triggered by push events.
+	triggers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consoleapplication_git_webhook_triggers_total",
+		Help: "Number of ConsoleApplications reconciled again after a push event, by provider.",
+	}, []string{"provider"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(deliveries, triggers)
+}
@@ -0,0 +1,385 @@
+package gitwebhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+	gitservice "github.com/openshift-console/console-application-operator/pkg/git-service"
+)
+
+// RepositoryKeyField indexes the ConsoleApplications by the repository key of
+// their Git URL, see gitservice.RepositoryKey.
+const RepositoryKeyField = "spec.git.repositoryKey"
+
+// maxPayloadBytes is the largest payload accepted, the limit of Github.
+const maxPayloadBytes = 25 << 20
+
+// Receiver serves the push events of Github, Gitlab and Bitbucket. Each push
+// authenticated by the webhook secret of a ConsoleApplication tracking the
+// pushed reference is recorded in its status, which reconciles it again. Pull
+// request events are recorded likewise in the ConsoleApplications creating
+// previews.
+type Receiver struct {
+	client client.Client
+	// secrets reads the webhook secrets, without a cache of every Secret.
+	secrets    client.Reader
+	addr       string
+	log        logr.Logger
+	deliveries *deliveryCache
+	now        func() time.Time
+}
+
+// NewReceiver returns a Receiver serving on addr, reading the
+// ConsoleApplications with c and their webhook secrets with secrets, usually
+// the API reader of the manager.
+func NewReceiver(c client.Client, secrets client.Reader, addr string) *Receiver {
+	return &Receiver{
+		client:     c,
+		secrets:    secrets,
+		addr:       addr,
+		log:        ctrl.Log.WithName("git-webhook"),
+		deliveries: newDeliveryCache(DefaultReplayWindow),
+		now:        time.Now,
+	}
+}
+
+//+kubebuilder:rbac:groups=apps.console.dev,resources=consoleapplications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+// SetupWithManager indexes the ConsoleApplications by repository and serves
+// the webhooks while the manager runs.
+func (r *Receiver) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &appsv1alpha1.ConsoleApplication{},
+		RepositoryKeyField, IndexRepositoryKey)
+	if err != nil {
+		return err
+	}
+	return mgr.Add(r)
+}
+
+// IndexRepositoryKey returns the repository key of a ConsoleApplication.
+func IndexRepositoryKey(obj client.Object) []string {
+	app, ok := obj.(*appsv1alpha1.ConsoleApplication)
+	if !ok {
+		return nil
+	}
+	key, err := gitservice.RepositoryKey(app.Spec.Git.Url)
+	if err != nil {
+		return nil
+	}
+	return []string{key}
+}
+
+// Start serves the webhooks until ctx is done.
+func (r *Receiver) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              r.addr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			r.log.Error(err, "Cannot shut down the webhook server")
+		}
+	}()
+	r.log.Info("Serving Git webhooks", "addr", r.addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection is false so that every replica serves the webhooks.
+func (r *Receiver) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	provider := providerOf(req.Header)
+	if req.Method != http.MethodPost {
+		r.respond(w, provider, resultMalformed, http.StatusMethodNotAllowed, "only POST is allowed")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxPayloadBytes))
+	if err != nil {
+		r.respond(w, provider, resultMalformed, http.StatusBadRequest, err.Error())
+		return
+	}
+	event, err := ParseEvent(req.Header, body)
+	if errors.Is(err, errIgnoredEvent) {
+		pullRequest, err := ParsePullRequestEvent(req.Header, body)
+		switch {
+		case errors.Is(err, errIgnoredEvent):
+			r.respond(w, provider, resultIgnored, http.StatusOK, err.Error())
+		case err != nil:
+			r.respond(w, provider, resultMalformed, http.StatusBadRequest, err.Error())
+		default:
+			r.servePullRequest(w, req, body, pullRequest)
+		}
+		return
+	}
+	if err != nil {
+		r.respond(w, provider, resultMalformed, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := req.Context()
+	log := r.log.WithValues("provider", event.Provider, "delivery", event.DeliveryID)
+	matches, err := r.matchingApplications(ctx, event)
+	if err != nil {
+		log.Error(err, "Cannot list the ConsoleApplications of the repository")
+		r.respond(w, provider, resultError, http.StatusInternalServerError, "cannot list the ConsoleApplications")
+		return
+	}
+	if len(matches) == 0 {
+		r.respond(w, provider, resultNoMatch, http.StatusOK, "no ConsoleApplication tracks the pushed references")
+		return
+	}
+
+	// Each ConsoleApplication only trusts the deliveries signed with its own secret.
+	var verified []match
+	for _, m := range matches {
+		if r.verify(ctx, log, m.app, req.Header, body) {
+			verified = append(verified, m)
+		}
+	}
+	if len(verified) == 0 {
+		r.respond(w, provider, resultInvalidSignature, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	key := replayKey(event.Provider, body)
+	if !r.deliveries.addIfAbsent(key) {
+		r.respond(w, provider, resultDuplicate, http.StatusOK, "delivery already handled")
+		return
+	}
+	var errs []error
+	for _, m := range verified {
+		triggered, err := r.trigger(ctx, m, event.DeliveryID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if triggered {
+			triggers.WithLabelValues(string(event.Provider)).Inc()
+			log.Info("Push event recorded", "consoleApplication", client.ObjectKeyFromObject(m.app),
+				"commitSHA", m.commitSHA)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		// The provider delivers again, the delivery is not remembered.
+		r.deliveries.remove(key)
+		log.Error(err, "Cannot record the push event")
+		r.respond(w, provider, resultError, http.StatusInternalServerError, "cannot record the push event")
+		return
+	}
+	r.respond(w, provider, resultTriggered, http.StatusAccepted,
+		fmt.Sprintf("%d ConsoleApplication(s) triggered", len(verified)))
+}
+
+// servePullRequest records a pull request event in the status of the
+// ConsoleApplications of the repository creating previews, which syncs their
+// previews again.
+func (r *Receiver) servePullRequest(w http.ResponseWriter, req *http.Request, body []byte, event *PullRequestEvent) {
+	ctx := req.Context()
+	log := r.log.WithValues("provider", event.Provider, "delivery", event.DeliveryID)
+	apps, err := r.repositoryApplications(ctx, event.RepositoryURLs)
+	if err != nil {
+		log.Error(err, "Cannot list the ConsoleApplications of the repository")
+		r.respond(w, event.Provider, resultError, http.StatusInternalServerError, "cannot list the ConsoleApplications")
+		return
+	}
+	var verified []*appsv1alpha1.ConsoleApplication
+	for _, app := range apps {
+		if app.Spec.Preview.Enabled && r.verify(ctx, log, app, req.Header, body) {
+			verified = append(verified, app)
+		}
+	}
+	if len(verified) == 0 {
+		// Not telling apart the ConsoleApplications without previews from the invalid signatures
+		r.respond(w, event.Provider, resultNoMatch, http.StatusOK, "no ConsoleApplication creates previews")
+		return
+	}
+
+	key := replayKey(event.Provider, body)
+	if !r.deliveries.addIfAbsent(key) {
+		r.respond(w, event.Provider, resultDuplicate, http.StatusOK, "delivery already handled")
+		return
+	}
+	var errs []error
+	for _, app := range verified {
+		original := app.DeepCopy()
+		app.Status.LastPullRequestEvent = &appsv1alpha1.PullRequestEvent{
+			Number:     event.Number,
+			Action:     event.Action,
+			DeliveryID: event.DeliveryID,
+			Time:       metav1.NewTime(r.now()),
+		}
+		if err := r.client.Status().Patch(ctx, app, client.MergeFrom(original)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.ObjectKeyFromObject(app), err))
+			continue
+		}
+		triggers.WithLabelValues(string(event.Provider)).Inc()
+		log.Info("Pull request event recorded", "consoleApplication", client.ObjectKeyFromObject(app),
+			"number", event.Number, "action", event.Action)
+	}
+	if err := errors.Join(errs...); err != nil {
+		r.deliveries.remove(key)
+		log.Error(err, "Cannot record the pull request event")
+		r.respond(w, event.Provider, resultError, http.StatusInternalServerError,
+			"cannot record the pull request event")
+		return
+	}
+	r.respond(w, event.Provider, resultTriggered, http.StatusAccepted,
+		fmt.Sprintf("%d ConsoleApplication(s) triggered", len(verified)))
+}
+
+// verify reports whether the delivery is signed with the webhook secret of app.
+func (r *Receiver) verify(
+	ctx context.Context, log logr.Logger, app *appsv1alpha1.ConsoleApplication, header http.Header, body []byte,
+) bool {
+	secret, err := r.webhookSecret(ctx, app)
+	if err != nil {
+		log.Info("Cannot read the webhook secret", "consoleApplication", client.ObjectKeyFromObject(app),
+			"error", err.Error())
+		return false
+	}
+	return Verify(providerOf(header), header, body, secret)
+}
+
+// respond counts the delivery and writes its result.
+func (r *Receiver) respond(w http.ResponseWriter, provider Provider, result string, status int, message string) {
+	deliveries.WithLabelValues(string(provider), result).Inc()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, message)
+}
+
+// match is a ConsoleApplication tracking a reference moved by a push.
+type match struct {
+	app       *appsv1alpha1.ConsoleApplication
+	commitSHA string
+}
+
+// matchingApplications returns the ConsoleApplications of the pushed
+// repository tracking one of the pushed references.
+func (r *Receiver) matchingApplications(ctx context.Context, event *PushEvent) ([]match, error) {
+	apps, err := r.repositoryApplications(ctx, event.RepositoryURLs)
+	if err != nil {
+		return nil, err
+	}
+	var matches []match
+	for _, app := range apps {
+		for _, update := range event.Updates {
+			if tracksRef(app, update.Ref) {
+				matches = append(matches, match{app: app, commitSHA: update.CommitSHA})
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// repositoryApplications returns the ConsoleApplications of the repository
+// with one of repoURLs.
+func (r *Receiver) repositoryApplications(
+	ctx context.Context, repoURLs []string,
+) ([]*appsv1alpha1.ConsoleApplication, error) {
+	seen := map[string]bool{}
+	var result []*appsv1alpha1.ConsoleApplication
+	for _, repoURL := range repoURLs {
+		key, err := gitservice.RepositoryKey(repoURL)
+		if err != nil || seen[key] {
+			continue
+		}
+		seen[key] = true
+		apps := &appsv1alpha1.ConsoleApplicationList{}
+		if err := r.client.List(ctx, apps, client.MatchingFields{RepositoryKeyField: key}); err != nil {
+			return nil, err
+		}
+		for i := range apps.Items {
+			result = append(result, &apps.Items[i])
+		}
+	}
+	return result, nil
+}
+
+// tracksRef reports whether app builds from ref, a full reference name. The
+// reference of the spec, or the default branch it resolved to, may be a short
+// branch or tag name. Commits never move.
+func tracksRef(app *appsv1alpha1.ConsoleApplication, ref string) bool {
+	reference := app.Spec.Git.Reference
+	if reference == "" {
+		reference = app.Status.Git.Reference
+	}
+	if reference == "" || app.Status.Git.ReferenceType == gitservice.RefTypeCommit.String() {
+		return false
+	}
+	return ref == reference || ref == "refs/heads/"+reference || ref == "refs/tags/"+reference
+}
+
+// SecretName returns the name of the webhook secret of app: the secret of the
+// spec, else the one generated for a registered webhook, if any.
+func SecretName(app *appsv1alpha1.ConsoleApplication) string {
+	switch {
+	case app.Spec.Git.WebhookSecretRef != "":
+		return app.Spec.Git.WebhookSecretRef
+	case app.Spec.Git.RegisterWebhook:
+		return app.Name + "-git-webhook"
+	default:
+		return ""
+	}
+}
+
+// webhookSecret returns the webhook secret of app.
+func (r *Receiver) webhookSecret(ctx context.Context, app *appsv1alpha1.ConsoleApplication) ([]byte, error) {
+	name := SecretName(app)
+	if name == "" {
+		return nil, errors.New("the ConsoleApplication has no webhook secret")
+	}
+	secret := &corev1.Secret{}
+	if err := r.secrets.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: name}, secret); err != nil {
+		return nil, err
+	}
+	value := secret.Data[appsv1alpha1.WebhookSecretKey]
+	if len(value) == 0 {
+		return nil, fmt.Errorf("secret %q has no %q key", name, appsv1alpha1.WebhookSecretKey)
+	}
+	return []byte(strings.TrimSpace(string(value))), nil
+}
+
+// trigger records the pushed commit in the status of the ConsoleApplication,
+// unless it already builds from it or already recorded it.
+func (r *Receiver) trigger(ctx context.Context, m match, deliveryID string) (bool, error) {
+	app := m.app
+	if app.Status.Git.CommitSHA == m.commitSHA ||
+		(app.Status.LastTrigger != nil && app.Status.LastTrigger.CommitSHA == m.commitSHA) {
+		return false, nil
+	}
+	original := app.DeepCopy()
+	app.Status.LastTrigger = &appsv1alpha1.GitTrigger{
+		Source:     appsv1alpha1.TriggerSourceWebhook,
+		CommitSHA:  m.commitSHA,
+		DeliveryID: deliveryID,
+		Time:       metav1.NewTime(r.now()),
+	}
+	if err := r.client.Status().Patch(ctx, app, client.MergeFrom(original)); err != nil {
+		return false, fmt.Errorf("%s: %w", client.ObjectKeyFromObject(app), err)
+	}
+	return true, nil
+}
@@ -0,0 +1,205 @@
+package gitwebhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/openshift-console/console-application-operator/api/v1alpha1"
+)
+
+var webhookSecret = []byte("s3cr3t")
+
+func newTestReceiver(t *testing.T, objs ...client.Object) (*Receiver, client.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, appsv1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&appsv1alpha1.ConsoleApplication{}).
+		WithIndex(&appsv1alpha1.ConsoleApplication{}, RepositoryKeyField, IndexRepositoryKey).
+		Build()
+	r := NewReceiver(c, c, ":0")
+	r.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return r, c
+}
+
+func newApplication(name, gitURL, reference string) *appsv1alpha1.ConsoleApplication {
+	return &appsv1alpha1.ConsoleApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1alpha1.ConsoleApplicationSpec{
+			Git: appsv1alpha1.Git{Url: gitURL, Reference: reference, WebhookSecretRef: "webhook"},
+		},
+		Status: appsv1alpha1.ConsoleApplicationStatus{
+			Git: appsv1alpha1.GitStatus{Reference: "main", ReferenceType: "Branch", CommitSHA: oldSHA},
+		},
+	}
+}
+
+func githubPush(t *testing.T, r *Receiver, delivery, ref string, secret []byte) *httptest.ResponseRecorder {
+	body := []byte(`{"ref": "` + ref + `", "after": "` + newSHA + `",
+		"repository": {"clone_url": "https://github.com/Hello/World.git"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-GitHub-Delivery", delivery)
+	req.Header.Set("X-Hub-Signature-256", Sign(body, secret))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestReceiver(t *testing.T) {
+	r, c := newTestReceiver(t,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+			Data:       map[string][]byte{appsv1alpha1.WebhookSecretKey: webhookSecret},
+		},
+		newApplication("main", "https://github.com/hello/world", ""),
+		newApplication("feature", "git@github.com:hello/world.git", "feature"),
+		newApplication("other", "https://github.com/hello/other", "main"),
+	)
+
+	w := githubPush(t, r, "delivery-1", "refs/heads/main", []byte("wrong"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = githubPush(t, r, "delivery-1", "refs/heads/release", webhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "no ConsoleApplication")
+
+	w = githubPush(t, r, "delivery-1", "refs/heads/main", webhookSecret)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	app := &appsv1alpha1.ConsoleApplication{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "main"}, app))
+	assert.Equal(t, &appsv1alpha1.GitTrigger{
+		Source:     appsv1alpha1.TriggerSourceWebhook,
+		CommitSHA:  newSHA,
+		DeliveryID: "delivery-1",
+		Time:       metav1.NewTime(time.Unix(1700000000, 0)),
+	}, app.Status.LastTrigger)
+	for _, name := range []string{"feature", "other"} {
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, app))
+		assert.Nil(t, app.Status.LastTrigger, name)
+	}
+
+	w = githubPush(t, r, "delivery-1", "refs/heads/main", webhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "already handled")
+
+	w = githubPush(t, r, "delivery-2", "refs/heads/main", webhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "already handled", "replay with another delivery ID")
+}
+
+func TestReceiverWithoutSecret(t *testing.T) {
+	app := newApplication("main", "https://github.com/hello/world", "main")
+	app.Spec.Git.WebhookSecretRef = ""
+	r, _ := newTestReceiver(t, app)
+
+	w := githubPush(t, r, "delivery-1", "refs/heads/main", webhookSecret)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestReceiverIgnoredEvents(t *testing.T) {
+	r, _ := newTestReceiver(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"zen": "Keep it simple."}`)))
+	req.Header.Set("X-GitHub-Event", "ping")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestTracksRef(t *testing.T) {
+	app := newApplication("main", "https://github.com/hello/world", "")
+	assert.True(t, tracksRef(app, "refs/heads/main"), "default branch")
+	assert.False(t, tracksRef(app, "refs/heads/feature"))
+
+	app.Spec.Git.Reference = "v1.0.0"
+	assert.True(t, tracksRef(app, "refs/tags/v1.0.0"))
+
+	app.Spec.Git.Reference = oldSHA
+	app.Status.Git.ReferenceType = "Commit"
+	assert.False(t, tracksRef(app, "refs/heads/main"), "commits never move")
+}
+
+func TestDeliveryCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newDeliveryCache(time.Hour)
+	c.now = func() time.Time { return now }
+
+	assert.True(t, c.addIfAbsent("github/1"))
+	assert.False(t, c.addIfAbsent("github/1"))
+	assert.True(t, c.addIfAbsent("gitlab/1"))
+
+	now = now.Add(time.Hour)
+	assert.True(t, c.addIfAbsent("github/1"), "expired")
+
+	c.remove("github/1")
+	assert.True(t, c.addIfAbsent("github/1"), "removed")
+}
+
+func TestReceiverPullRequest(t *testing.T) {
+	previews := newApplication("previews", "https://github.com/hello/world", "main")
+	previews.Spec.Preview.Enabled = true
+	r, c := newTestReceiver(t,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+			Data:       map[string][]byte{appsv1alpha1.WebhookSecretKey: webhookSecret},
+		},
+		previews,
+		newApplication("main", "https://github.com/hello/world", "main"),
+	)
+	pullRequest := func(delivery string, secret []byte) *httptest.ResponseRecorder {
+		body := []byte(`{"action": "closed", "number": 42,
+			"repository": {"clone_url": "https://github.com/hello/world.git"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", delivery)
+		req.Header.Set("X-Hub-Signature-256", Sign(body, secret))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := pullRequest("delivery-1", []byte("wrong"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "no ConsoleApplication")
+
+	w = pullRequest("delivery-1", webhookSecret)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	app := &appsv1alpha1.ConsoleApplication{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "previews"}, app))
+	assert.Equal(t, &appsv1alpha1.PullRequestEvent{
+		Number:     42,
+		Action:     "closed",
+		DeliveryID: "delivery-1",
+		Time:       metav1.NewTime(time.Unix(1700000000, 0)),
+	}, app.Status.LastPullRequestEvent)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "main"}, app))
+	assert.Nil(t, app.Status.LastPullRequestEvent, "no previews")
+	assert.Nil(t, app.Status.LastTrigger)
+
+	w = pullRequest("delivery-1", webhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "already handled")
+}
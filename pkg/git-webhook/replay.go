@@ -0,0 +1,78 @@
+package gitwebhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReplayWindow is how long deliveries are remembered to reject replays.
+	DefaultReplayWindow = 24 * time.Hour
+
+	// maxDeliveries bounds the number of deliveries remembered.
+	maxDeliveries = 10000
+)
+
+// deliveryCache remembers the deliveries already handled, so that a delivery
+// replayed within the window is not acted on twice. The cache is held in
+// memory by each replica of the operator: a delivery replayed to another
+// replica, or after a restart, is acted on again. It then at most resolves
+// the Git reference once more, as a commit already recorded is not triggered
+// again.
+type deliveryCache struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	now    func() time.Time
+}
+
+func newDeliveryCache(window time.Duration) *deliveryCache {
+	return &deliveryCache{window: window, seen: map[string]time.Time{}, now: time.Now}
+}
+
+// replayKey identifies a delivery of provider by the digest of its payload.
+// Unlike the delivery headers, the payload is covered by the signature, so a
+// replay cannot pass for a new delivery. The payloads of distinct events
+// differ, e.g. by the commit the reference moved from or by a timestamp.
+func replayKey(provider Provider, body []byte) string {
+	digest := sha256.Sum256(body)
+	return string(provider) + "/sha256:" + hex.EncodeToString(digest[:])
+}
+
+// addIfAbsent remembers key for the window and reports whether it was not
+// already remembered, forgetting expired keys, or the oldest ones once the
+// cache is full. Checking and remembering at once lets a single one of
+// concurrent identical deliveries through.
+func (c *deliveryCache) addIfAbsent(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if expires, ok := c.seen[key]; ok && now.Before(expires) {
+		return false
+	}
+	if len(c.seen) >= maxDeliveries {
+		oldest, oldestExpiry := "", time.Time{}
+		for seen, expires := range c.seen {
+			if !now.Before(expires) {
+				delete(c.seen, seen)
+			} else if oldest == "" || expires.Before(oldestExpiry) {
+				oldest, oldestExpiry = seen, expires
+			}
+		}
+		if len(c.seen) >= maxDeliveries {
+			delete(c.seen, oldest)
+		}
+	}
+	c.seen[key] = now.Add(c.window)
+	return true
+}
+
+// remove forgets key, so that a delivery that failed is handled again when
+// the provider delivers it again.
+func (c *deliveryCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, key)
+}
@@ -0,0 +1,54 @@
+package gitwebhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Verify reports whether a delivery of provider was sent with secret: Github
+// and Bitbucket sign the payload with an HMAC-SHA256 of the secret, while
+// Gitlab sends the secret itself.
+func Verify(provider Provider, header http.Header, body, secret []byte) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	switch provider {
+	case Github:
+		return verifyHMAC(header.Get("X-Hub-Signature-256"), body, secret)
+	case Bitbucket:
+		return verifyHMAC(header.Get("X-Hub-Signature"), body, secret)
+	case Gitlab:
+		token := header.Get("X-Gitlab-Token")
+		return token != "" && subtle.ConstantTimeCompare([]byte(token), secret) == 1
+	default:
+		return false
+	}
+}
+
+// verifyHMAC reports whether signature, "sha256=<hex digest>", is the
+// HMAC-SHA256 of body with secret.
+func verifyHMAC(signature string, body, secret []byte) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Sign returns the signature of body with secret, as sent by Github in
+// X-Hub-Signature-256 and by Bitbucket in X-Hub-Signature.
+func Sign(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
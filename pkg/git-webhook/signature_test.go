@@ -0,0 +1,37 @@
+package gitwebhook
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"ref": "refs/heads/main"}`)
+	secret := []byte("s3cr3t")
+	signature := Sign(body, secret)
+
+	tests := []struct {
+		name     string
+		provider Provider
+		header   http.Header
+		secret   []byte
+		want     bool
+	}{
+		{"Github", Github, http.Header{"X-Hub-Signature-256": {signature}}, secret, true},
+		{"Github wrong secret", Github, http.Header{"X-Hub-Signature-256": {signature}}, []byte("other"), false},
+		{"Github SHA-1 signature", Github, http.Header{"X-Hub-Signature": {"sha1=0123"}}, secret, false},
+		{"Github malformed signature", Github, http.Header{"X-Hub-Signature-256": {"sha256=zz"}}, secret, false},
+		{"Bitbucket", Bitbucket, http.Header{"X-Hub-Signature": {signature}}, secret, true},
+		{"Gitlab", Gitlab, http.Header{"X-Gitlab-Token": {"s3cr3t"}}, secret, true},
+		{"Gitlab wrong token", Gitlab, http.Header{"X-Gitlab-Token": {"s3cr3"}}, secret, false},
+		{"Gitlab without token", Gitlab, http.Header{}, secret, false},
+		{"No secret", Gitlab, http.Header{"X-Gitlab-Token": {""}}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Verify(tt.provider, tt.header, body, tt.secret))
+		})
+	}
+}